@@ -7,9 +7,16 @@ type UserLogin struct {
 	Email          string `json:"email"`
 	Password       []byte `json:"-"`
 	Name           string `json:"name"`
-	GithubID       int64  `gorm:"uniqueIndex" json:"github_id"`
+	GithubID       *int64 `gorm:"uniqueIndex" json:"github_id"`
 	InstallationID int64  `json:"installation_id"`
 	Enabled        bool   `json:"enabled"`
+	PersonaID      *int64 `gorm:"default:null" json:"persona_id,omitempty"`
+	IsAdmin        bool   `gorm:"default:false" json:"is_admin"`
+
+	// Nil until they follow the link emailed at signup; password logins are
+	// refused until then
+	EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -27,6 +34,15 @@ type Repository struct {
 	Owner   string `json:"owner"`
 	OwnerID int64  `json:"owner_id"`
 	Enabled bool   `gorm:"default:true" json:"enabled"`
+	// InstallationID is the installation of the GitHub App that gave us access
+	InstallationID int64 `gorm:"index" json:"installation_id"`
+
+	// Optional overrides of the deployment-wide AI provider and model
+	AiProvider string `json:"ai_provider"`
+	AiModel    string `json:"ai_model"`
+	PersonaID  *int64 `gorm:"default:null" json:"persona_id,omitempty"`
+	// CommentMode is either CommentModeSticky or CommentModeNew
+	CommentMode string `gorm:"default:sticky" json:"comment_mode"`
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -39,8 +55,17 @@ type Repository struct {
 
 	// AI Roasts
 	AiRoasts []AiRoast `gorm:"foreignKey:RepoID" json:"ai_roasts"`
+
+	PullRequests []PullRequest `gorm:"foreignKey:RepoID" json:"pull_requests"`
 }
 
+const (
+	// CommentModeSticky keeps a single roast comment up to date on each pull request
+	CommentModeSticky = "sticky"
+	// CommentModeNew posts a new roast for every push
+	CommentModeNew = "new"
+)
+
 type UserRepositoryCollaborator struct {
 	ID           int64 `gorm:"primaryKey;autoIncrement" json:"id"`
 	RepositoryID int64 `json:"repository_id"`
@@ -64,13 +89,285 @@ type AiRoast struct {
 	RepoID            int64     `json:"repo_id"`
 	PullRequestNumber int       `json:"pull_request_number"`
 	Content           string    `json:"content"`
-	IsOpen            bool      `json:"is_open"`
-	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Provider          string    `json:"provider"`
+	Model             string    `json:"model"`
+	ChunkPlan         ChunkPlan `gorm:"type:jsonb" json:"chunk_plan"`
+	HeadSHA           string    `json:"head_sha"`
+	BaseSHA           string    `json:"base_sha"`
+	ReviewedFromSHA   string    `json:"reviewed_from_sha"`
+	PersonaID         *int64    `gorm:"default:null" json:"persona_id,omitempty"`
+	PersonaName       string    `json:"persona_name"`
+	PersonaVersion    int       `json:"persona_version"`
+	PromptVersion     int       `json:"prompt_version"`
+	CommentID         int64     `json:"comment_id"`
+	CheckRunID        int64     `json:"check_run_id"`
+	Conclusion        string    `json:"conclusion"`
+	PullRequestID     *int64    `gorm:"default:null;index" json:"pull_request_id,omitempty"`
+	InstallationID    int64     `gorm:"index" json:"installation_id"`
+
+	// Usage across every model call of the roast, and what it cost going by
+	// the pricing table at the time
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CandidateTokens  int64   `json:"candidate_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	LatencyMs        int64   `json:"latency_ms"`
+	EstimatedCostUSD float64 `gorm:"column:estimated_cost_usd" json:"estimated_cost_usd"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Repository  Repository     `gorm:"foreignKey:RepoID" json:"-"`
+	PullRequest *PullRequest   `gorm:"foreignKey:PullRequestID" json:"pull_request,omitempty"`
+	Findings    []RoastFinding `gorm:"foreignKey:RoastID" json:"findings"`
+
+	Conversation []ConversationTurn `gorm:"foreignKey:RoastID" json:"conversation"`
+}
+
+const (
+	PullRequestOpen   = "open"
+	PullRequestClosed = "closed"
+	PullRequestMerged = "merged"
+)
+
+type PullRequest struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RepoID         int64      `gorm:"uniqueIndex:idx_pull_request_repo_number" json:"repo_id"`
+	Number         int        `gorm:"uniqueIndex:idx_pull_request_repo_number" json:"number"`
+	Title          string     `json:"title"`
+	AuthorGithubID int64      `gorm:"index" json:"author_github_id"`
+	AuthorLogin    string     `json:"author_login"`
+	BaseRef        string     `json:"base_ref"`
+	BaseSHA        string     `json:"base_sha"`
+	HeadRef        string     `json:"head_ref"`
+	HeadSHA        string     `json:"head_sha"`
+	Draft          bool       `json:"draft"`
+	State          string     `gorm:"index" json:"state"`
+	OpenedAt       time.Time  `json:"opened_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	MergedAt       *time.Time `json:"merged_at,omitempty"`
+
+	// Set from slash commands on the pull request
+	Ignored   bool   `json:"ignored"`
+	PersonaID *int64 `gorm:"default:null" json:"persona_id,omitempty"`
+	// Month in which the author was last told the installation is out of quota
+	QuotaNoticePeriod string `json:"-"`
+	// Why the latest push was not roasted, cleared by the next roast
+	SkipReason string     `json:"skip_reason,omitempty"`
+	SkippedAt  *time.Time `json:"skipped_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
 	Repository Repository `gorm:"foreignKey:RepoID" json:"-"`
 }
 
+type RoastFinding struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	RoastID    int64  `gorm:"index" json:"roast_id"`
+	File       string `json:"file"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Severity   string `gorm:"index" json:"severity"`
+	Category   string `gorm:"index" json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+	// GithubCommentID is the inline review comment the finding was posted as
+	GithubCommentID int64 `gorm:"index" json:"github_comment_id"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ConversationTurn is one message in a conversation about a roast, either a
+// reply from a person or GoodCode's answer to it.
+type ConversationTurn struct {
+	ID                int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	RoastID           int64  `gorm:"index" json:"roast_id"`
+	RepoID            int64  `gorm:"index:idx_conversation_pull_request" json:"repo_id"`
+	PullRequestNumber int    `gorm:"index:idx_conversation_pull_request" json:"pull_request_number"`
+	FindingID         *int64 `gorm:"default:null;index" json:"finding_id,omitempty"`
+	// ThreadID is the inline comment the thread hangs off, or 0 for the pull
+	// request conversation
+	ThreadID        int64  `json:"thread_id"`
+	GithubCommentID int64  `json:"github_comment_id"`
+	Role            string `json:"role"`
+	AuthorLogin     string `json:"author_login"`
+	Body            string `json:"body"`
+	Model           string `json:"model,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Kinds of model calls made outside a roast.
+const (
+	ModelCallAnswer  = "answer"
+	ModelCallExplain = "explain"
+	// A roast whose every chunk failed, kept for the tokens it still used
+	ModelCallFailedRoast = "failed_roast"
+)
+
+// ModelCall is a call to the model made outside a roast, such as an answer to
+// a comment or an explanation of a file, kept so usage reports count it too.
+type ModelCall struct {
+	ID                int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind              string `gorm:"index" json:"kind"`
+	RepoID            int64  `gorm:"index" json:"repo_id"`
+	PullRequestNumber int    `json:"pull_request_number"`
+	InstallationID    int64  `gorm:"index" json:"installation_id"`
+	Provider          string `json:"provider"`
+	Model             string `json:"model"`
+
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CandidateTokens  int64   `json:"candidate_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	LatencyMs        int64   `json:"latency_ms"`
+	EstimatedCostUSD float64 `gorm:"column:estimated_cost_usd" json:"estimated_cost_usd"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type Persona struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string `gorm:"index" json:"name"`
+	Description  string `json:"description"`
+	Instructions string `json:"instructions"`
+	// Version is bumped every time the instructions change
+	Version int  `gorm:"default:1" json:"version"`
+	BuiltIn bool `json:"built_in"`
+
+	// Custom personas belong to the user who created them
+	OwnerID *int64 `gorm:"default:null;index" json:"owner_id,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	// JobDead jobs failed too often and wait for someone to look at them
+	JobDead = "dead"
+)
+
+type Job struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind        string `gorm:"index" json:"kind"`
+	Payload     JSON   `gorm:"type:jsonb" json:"payload"`
+	Status      string `gorm:"index:idx_job_claim,priority:1;default:queued" json:"status"`
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"max_attempts"`
+	LastError   string `json:"last_error"`
+
+	RunAt       time.Time  `gorm:"index:idx_job_claim,priority:2" json:"run_at"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	LockedBy    string     `json:"locked_by"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Installation is a GitHub App installation and the plan its quotas come from.
+type Installation struct {
+	ID           int64  `gorm:"primaryKey" json:"id"`
+	AccountLogin string `json:"account_login"`
+	Plan         string `json:"plan"`
+
+	// Overrides of the plan's limits for this installation only
+	RoastsPerMonth    *int   `gorm:"default:null" json:"roasts_per_month,omitempty"`
+	MaxDiffBytes      *int   `gorm:"default:null" json:"max_diff_bytes,omitempty"`
+	TokensPerMonth    *int64 `gorm:"default:null" json:"tokens_per_month,omitempty"`
+	MaxTokensPerRoast *int64 `gorm:"default:null" json:"max_tokens_per_roast,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// QuotaUsage counts what an installation used in a calendar month, written to
+// with single atomic statements so concurrent roasts cannot overshoot.
+type QuotaUsage struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	InstallationID int64  `gorm:"uniqueIndex:idx_quota_usage_period" json:"installation_id"`
+	Period         string `gorm:"uniqueIndex:idx_quota_usage_period" json:"period"`
+	Roasts         int    `json:"roasts"`
+	Tokens         int64  `json:"tokens"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// QuotaReservation remembers which job a quota reservation was made for, so a
+// retried job reuses it instead of counting against the quota again.
+type QuotaReservation struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key            string `gorm:"uniqueIndex" json:"key"`
+	InstallationID int64  `json:"installation_id"`
+	Period         string `json:"period"`
+	Roasts         int    `json:"roasts"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+const (
+	DeliveryReceived  = "received"
+	DeliveryProcessed = "processed"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the raw log of a webhook GitHub sent us, keyed by its
+// X-GitHub-Delivery ID so redeliveries are only processed once.
+type WebhookDelivery struct {
+	ID             int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeliveryID     string `gorm:"uniqueIndex;not null" json:"delivery_id"`
+	Event          string `gorm:"index" json:"event"`
+	Action         string `json:"action"`
+	InstallationID int64  `gorm:"index" json:"installation_id"`
+	Payload        JSON   `gorm:"type:jsonb" json:"payload"`
+
+	Status       string     `gorm:"index;default:received" json:"status"`
+	ResponseCode int        `json:"response_code"`
+	Error        string     `json:"error"`
+	Attempts     int        `json:"attempts"`
+	Duplicates   int        `json:"duplicates"`
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// AccountToken is a single-use secret emailed to a user to prove they own the
+// address. Only its SHA-256 is stored.
+type AccountToken struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserLoginID int64      `gorm:"index;not null" json:"user_login_id"`
+	Purpose     string     `gorm:"index;not null" json:"purpose"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Session is a signed in browser. The auth cookie carries its ID as the JWT
+// ID, so revoking the session signs that browser out even though the token
+// itself has not expired.
+type Session struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	UserLoginID int64  `gorm:"index;not null" json:"user_login_id"`
+	UserAgent   string `json:"user_agent"`
+	IP          string `json:"ip"`
+
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
 type InstallationEvent struct {
 	InstallationID int64  `json:"installation_id"`
 	SetupAction    string `json:"setup_action"`
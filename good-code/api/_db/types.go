@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ChunkPlan records how a diff was split up for review. It is stored as a
+// JSON column on the roast it belongs to.
+type ChunkPlan struct {
+	Chunks       [][]string    `json:"chunks"`
+	FailedChunks int           `json:"failed_chunks"`
+	SkippedFiles []SkippedFile `json:"skipped_files"`
+}
+
+func (p ChunkPlan) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+func (p *ChunkPlan) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*p = ChunkPlan{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("cannot scan %T into ChunkPlan", value)
+	}
+}
+
+// JSON is a raw JSON document stored in a jsonb column.
+type JSON json.RawMessage
+
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+func (j *JSON) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+		return nil
+	case string:
+		*j = JSON(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", value)
+	}
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
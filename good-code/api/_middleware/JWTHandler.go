@@ -3,41 +3,120 @@ package middleware
 import (
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	db "github.com/chopstickleg/good-code/api/_db"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+	keyring "github.com/chopstickleg/good-code/api/_utils/keyring"
 )
 
-func GetUserIDFromJWT(tokenString string) (int64, error) {
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		log.Printf("Error: JWT_SECRET_KEY environment variable not set")
-		return 0, fmt.Errorf("JWT_SECRET_KEY environment variable not set")
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+// touchInterval is how stale a session's last seen time may get before a
+// request updates it.
+const touchInterval = 5 * time.Minute
 
+// Authenticate verifies the token of a request to an authenticated endpoint
+// and slides its session along, so any activity keeps the user signed in.
+func Authenticate(w http.ResponseWriter, r *http.Request, tokenString string) (int64, error) {
+	session, err := AuthenticateSession(w, r, tokenString)
 	if err != nil {
 		return 0, err
 	}
+	return session.UserLoginID, nil
+}
 
-	if !token.Valid {
+// AuthenticateSession is Authenticate for endpoints that need the session
+// itself.
+func AuthenticateSession(w http.ResponseWriter, r *http.Request, tokenString string) (*db.Session, error) {
+	session, err := GetSessionFromJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	// The cookie is still good for a while, so failing to extend it is not
+	// worth failing the request over
+	if err := authentication.RefreshSession(w, r, conn, session); err != nil {
+		log.Printf("Error refreshing session of user %d: %v", session.UserLoginID, err)
+	}
+	return session, nil
+}
 
-		return 0, fmt.Errorf("invalid token")
+func GetUserIDFromJWT(tokenString string) (int64, error) {
+	session, err := GetSessionFromJWT(tokenString)
+	if err != nil {
+		return 0, err
 	}
+	return session.UserLoginID, nil
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, fmt.Errorf("could not parse claims")
+// GetSessionFromJWT verifies the token and loads the session it belongs to,
+// rejecting sessions that were revoked or have expired.
+func GetSessionFromJWT(tokenString string) (*db.Session, error) {
+	ring, err := keyring.Load()
+	if err != nil {
+		log.Printf("Error loading JWT keys: %v", err)
+		return nil, err
+	}
+	claims, err := ring.Parse(tokenString)
+	if err != nil {
+		return nil, err
 	}
 
 	userIDFloat, ok := claims["id"].(float64)
 	if !ok {
-		return 0, fmt.Errorf("user ID not found in token or wrong type")
+		return nil, fmt.Errorf("user ID not found in token or wrong type")
 	}
-
 	userID := int64(userIDFloat)
-	return userID, nil
+
+	conn, err := db.GetDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	// Changing the password signs the user out of tokens issued before it
+	var user db.UserLogin
+	err = conn.Select("id", "password_changed_at").Where(&db.UserLogin{ID: userID}).First(&user).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	issuedAt, _ := claims["iat"].(float64)
+	if user.PasswordChangedAt != nil && int64(issuedAt) < user.PasswordChangedAt.Unix() {
+		return nil, fmt.Errorf("token issued before the password changed")
+	}
+
+	var session *db.Session
+	if sessionID, _ := claims["jti"].(string); sessionID != "" {
+		session = &db.Session{}
+		err = conn.Where(&db.Session{ID: sessionID, UserLoginID: userID}).First(session).Error
+	} else {
+		// Tokens from before sessions existed have no ID. They keep working
+		// until they expire, through a session of their own that can be
+		// revoked
+		expiresAt, _ := claims["exp"].(float64)
+		session, err = authentication.LegacySession(conn, tokenString, userID, time.Unix(int64(expiresAt), 0))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	now := time.Now()
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("session revoked")
+	}
+	if !session.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("session expired")
+	}
+
+	if now.Sub(session.LastSeenAt) > touchInterval {
+		session.LastSeenAt = now
+		err = conn.Model(&db.Session{}).
+			Where(&db.Session{ID: session.ID}).
+			Update("last_seen_at", now).
+			Error
+		if err != nil {
+			log.Printf("Failed to update last seen time of session: %v", err)
+		}
+	}
+	return session, nil
 }
@@ -0,0 +1,23 @@
+package authentication
+
+import (
+	"testing"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// testDB is an in-memory database with the tables accounts live in.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&db.Session{}, &db.UserLogin{}, &db.AccountToken{}, &db.UserRepositoryCollaborator{}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
@@ -0,0 +1,164 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v72/github"
+)
+
+const (
+	oauthStateCookie = "github_oauth"
+	oauthStatePath   = "/api/auth/github"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// Modes of the OAuth flow: signing in, or linking GitHub to the account that
+// is already signed in.
+const (
+	OAuthLogin = "login"
+	OAuthLink  = "link"
+)
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// StartGitHubOAuth remembers a random state and the mode in a short-lived
+// cookie and returns the URL of GitHub's authorization page. Signing in goes
+// through the App's user-to-server flow, so it uses the App's client ID and
+// GITHUB_APP_CLIENT_SECRET.
+func StartGitHubOAuth(w http.ResponseWriter, mode string) (string, error) {
+	clientID := os.Getenv("GITHUB_APP_CLIENT_ID")
+	if clientID == "" {
+		return "", errors.New("GITHUB_APP_CLIENT_ID environment variable not set")
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate OAuth state: %w", err)
+	}
+	state := hex.EncodeToString(raw)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    mode + "." + state,
+		Path:     oauthStatePath,
+		Expires:  time.Now().Add(oauthStateTTL),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	params := url.Values{"client_id": {clientID}, "state": {state}}
+	if redirect := os.Getenv("GITHUB_OAUTH_REDIRECT_URL"); redirect != "" {
+		params.Set("redirect_uri", redirect)
+	}
+	return "https://github.com/login/oauth/authorize?" + params.Encode(), nil
+}
+
+// FinishGitHubOAuth checks the state GitHub sent back against the cookie set
+// by StartGitHubOAuth, which it clears, and returns the mode the flow was
+// started in.
+func FinishGitHubOAuth(w http.ResponseWriter, r *http.Request) (string, error) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     oauthStatePath,
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		return "", errors.New("OAuth state cookie missing or expired")
+	}
+	mode, state, ok := strings.Cut(cookie.Value, ".")
+	if !ok || (mode != OAuthLogin && mode != OAuthLink) {
+		return "", errors.New("malformed OAuth state cookie")
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(r.URL.Query().Get("state"))) != 1 {
+		return "", errors.New("OAuth state mismatch")
+	}
+	return mode, nil
+}
+
+// ExchangeGitHubCode trades the code GitHub redirected back with for a
+// user-to-server token.
+func ExchangeGitHubCode(ctx context.Context, code string) (string, error) {
+	clientID := os.Getenv("GITHUB_APP_CLIENT_ID")
+	clientSecret := os.Getenv("GITHUB_APP_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		return "", errors.New("GITHUB_APP_CLIENT_ID and GITHUB_APP_CLIENT_SECRET environment variables must be set")
+	}
+	params := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"code":          {code},
+	}
+	if redirect := os.Getenv("GITHUB_OAUTH_REDIRECT_URL"); redirect != "" {
+		params.Set("redirect_uri", redirect)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://github.com/login/oauth/access_token", strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange OAuth code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// GitHub reports errors such as an expired code with a 200 and an "error"
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode OAuth token response (status %d): %w", resp.StatusCode, err)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("GitHub refused the OAuth code: %s: %s", body.Error, body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("GitHub returned no access token (status %d)", resp.StatusCode)
+	}
+	return body.AccessToken, nil
+}
+
+// GitHubUser reads the user a user-to-server token belongs to. When their
+// profile hides their email, the primary verified one is used if the App may
+// read email addresses.
+func GitHubUser(ctx context.Context, token string) (*github.User, error) {
+	client := github.NewClient(nil).WithAuthToken(token)
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authenticated GitHub user: %w", err)
+	}
+	if user.GetEmail() != "" {
+		return user, nil
+	}
+	emails, _, err := client.Users.ListEmails(ctx, nil)
+	if err != nil {
+		return user, nil
+	}
+	for _, email := range emails {
+		if email.GetPrimary() && email.GetVerified() {
+			user.Email = email.Email
+			break
+		}
+	}
+	return user, nil
+}
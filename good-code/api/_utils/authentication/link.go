@@ -0,0 +1,90 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrGitHubTaken is returned when the GitHub account is already linked to
+	// another Good Code account.
+	ErrGitHubTaken = errors.New("GitHub account is linked to another account")
+	// ErrGitHubLinked is returned when the account is already linked to a
+	// different GitHub account.
+	ErrGitHubLinked = errors.New("account is already linked to a different GitHub account")
+	// ErrNoPassword is returned when unlinking GitHub would leave the account
+	// without any way to sign in.
+	ErrNoPassword = errors.New("account has no password")
+)
+
+// LinkGitHub records the GitHub identity of the user and attaches the
+// collaborator records webhooks created for that identity before the user
+// signed up.
+func LinkGitHub(conn *gorm.DB, user *db.UserLogin, githubID int64) error {
+	if user.GithubID != nil {
+		if *user.GithubID == githubID {
+			return attachCollaborators(conn, user.ID, githubID)
+		}
+		return ErrGitHubLinked
+	}
+	var owner db.UserLogin
+	err := conn.Where(&db.UserLogin{GithubID: &githubID}).First(&owner).Error
+	if err == nil {
+		return ErrGitHubTaken
+	}
+	if err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to look up GitHub user %d: %w", githubID, err)
+	}
+
+	err = conn.Model(&db.UserLogin{}).
+		Where(&db.UserLogin{ID: user.ID}).
+		Update("github_id", githubID).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to link GitHub user %d: %w", githubID, err)
+	}
+	user.GithubID = &githubID
+	return attachCollaborators(conn, user.ID, githubID)
+}
+
+// UnlinkGitHub forgets the GitHub identity of the user, as long as they can
+// still sign in with a password.
+func UnlinkGitHub(conn *gorm.DB, user *db.UserLogin) error {
+	if len(user.Password) == 0 {
+		return ErrNoPassword
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		// NULL rather than 0 so the unique index allows many unlinked accounts
+		err := tx.Model(&db.UserLogin{}).
+			Where(&db.UserLogin{ID: user.ID}).
+			Update("github_id", gorm.Expr("NULL")).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to unlink GitHub user: %w", err)
+		}
+		err = tx.Model(&db.UserRepositoryCollaborator{}).
+			Where(&db.UserRepositoryCollaborator{UserLoginID: &user.ID}).
+			Updates(map[string]any{"user_login_id": nil, "is_good_code_user": false}).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to detach collaborator records: %w", err)
+		}
+		user.GithubID = nil
+		return nil
+	})
+}
+
+func attachCollaborators(conn *gorm.DB, userID int64, githubID int64) error {
+	err := conn.Model(&db.UserRepositoryCollaborator{}).
+		Where(&db.UserRepositoryCollaborator{GithubUserID: githubID}).
+		Updates(map[string]any{"user_login_id": userID, "is_good_code_user": true}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to attach collaborator records: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package authentication
+
+import (
+	"errors"
+	"testing"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+)
+
+func TestLinkGitHub(t *testing.T) {
+	conn := testDB(t)
+	// Accounts that signed up with a password have no GitHub ID, and the
+	// unique index must allow more than one of them
+	alice := db.UserLogin{Email: "alice@example.com", Password: []byte("hash"), Enabled: true}
+	bob := db.UserLogin{Email: "bob@example.com", Password: []byte("hash"), Enabled: true}
+	for _, user := range []*db.UserLogin{&alice, &bob} {
+		if err := conn.Create(user).Error; err != nil {
+			t.Fatalf("creating %s: %v", user.Email, err)
+		}
+	}
+	collaborator := db.UserRepositoryCollaborator{RepositoryID: 1, GithubUserID: 42, GithubLogin: "alice"}
+	if err := conn.Create(&collaborator).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LinkGitHub(conn, &alice, 42); err != nil {
+		t.Fatalf("LinkGitHub() error = %v", err)
+	}
+	if alice.GithubID == nil || *alice.GithubID != 42 {
+		t.Errorf("GithubID = %v, want 42", alice.GithubID)
+	}
+	conn.First(&collaborator, collaborator.ID)
+	if collaborator.UserLoginID == nil || *collaborator.UserLoginID != alice.ID || !collaborator.IsGoodCodeUser {
+		t.Errorf("collaborator = %+v, want it attached to alice", collaborator)
+	}
+
+	if err := LinkGitHub(conn, &alice, 42); err != nil {
+		t.Errorf("linking the same account again: %v", err)
+	}
+	if err := LinkGitHub(conn, &alice, 7); !errors.Is(err, ErrGitHubLinked) {
+		t.Errorf("LinkGitHub() error = %v, want ErrGitHubLinked", err)
+	}
+	if err := LinkGitHub(conn, &bob, 42); !errors.Is(err, ErrGitHubTaken) {
+		t.Errorf("LinkGitHub() error = %v, want ErrGitHubTaken", err)
+	}
+
+	if err := UnlinkGitHub(conn, &alice); err != nil {
+		t.Fatalf("UnlinkGitHub() error = %v", err)
+	}
+	var stored db.UserLogin
+	conn.First(&stored, alice.ID)
+	if alice.GithubID != nil || stored.GithubID != nil {
+		t.Errorf("GithubID = %v, stored %v, want NULL", alice.GithubID, stored.GithubID)
+	}
+	if err := LinkGitHub(conn, &bob, 42); err != nil {
+		t.Errorf("linking a GitHub account freed by unlinking: %v", err)
+	}
+
+	carolID := int64(9)
+	passwordless := db.UserLogin{Email: "carol@example.com", GithubID: &carolID, Enabled: true}
+	if err := conn.Create(&passwordless).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := UnlinkGitHub(conn, &passwordless); !errors.Is(err, ErrNoPassword) {
+		t.Errorf("UnlinkGitHub() error = %v, want ErrNoPassword", err)
+	}
+}
@@ -0,0 +1,124 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	mail "github.com/chopstickleg/good-code/api/_utils/mail"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	ResetTTL          = time.Hour
+	MinPasswordLength = 8
+)
+
+// SendPasswordReset emails the user a link to choose a new password.
+func SendPasswordReset(ctx context.Context, conn *gorm.DB, user db.UserLogin) error {
+	sender, err := mail.NewSender(mail.ConfigFromEnv())
+	if err != nil {
+		return err
+	}
+	token, err := IssueToken(conn, user.ID, db.TokenResetPassword, ResetTTL)
+	if err != nil {
+		return err
+	}
+	link := AppURL() + "/reset-password?" + url.Values{"token": {token}}.Encode()
+	return sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Good Code password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Good Code account. Choose a new one by opening the link below within %d minutes:\n\n%s\n\nIf it was not you, ignore this email; your password stays the same.\n",
+			user.Name, int(ResetTTL.Minutes()), link),
+	})
+}
+
+// ResetPassword consumes a reset token and sets the password of the user it
+// was issued to. Following the emailed link also proves they own the address.
+// It all happens in one transaction, so a token is only used up by a reset
+// that went through.
+func ResetPassword(conn *gorm.DB, token string, password string) (*db.UserLogin, error) {
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+	var user db.UserLogin
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		consumed, err := ConsumeToken(tx, token, db.TokenResetPassword)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where(&db.UserLogin{ID: consumed.UserLoginID}).First(&user).Error; err != nil {
+			return fmt.Errorf("failed to load user %d: %w", consumed.UserLoginID, err)
+		}
+		if err := SetPassword(tx, &user, password); err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		err = tx.Model(&db.UserLogin{}).
+			Where(&db.UserLogin{ID: user.ID}).
+			Update("email_verified_at", now).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to verify email of user %d: %w", user.ID, err)
+		}
+		user.EmailVerifiedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ValidatePassword returns an error meant for the user when the password is
+// too weak.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	// bcrypt ignores anything past 72 bytes
+	if len(password) > 72 {
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// SetPassword hashes and stores a new password, signs the user out
+// everywhere and makes unused reset links stop working.
+func SetPassword(conn *gorm.DB, user *db.UserLogin, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	now := time.Now()
+	return conn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&db.UserLogin{}).
+			Where(&db.UserLogin{ID: user.ID}).
+			Updates(map[string]any{"password": hash, "password_changed_at": now}).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to update password of user %d: %w", user.ID, err)
+		}
+		err = tx.Model(&db.AccountToken{}).
+			Where(&db.AccountToken{UserLoginID: user.ID, Purpose: db.TokenResetPassword}).
+			Where("used_at IS NULL").
+			Update("used_at", now).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to invalidate reset tokens of user %d: %w", user.ID, err)
+		}
+		if err := RevokeSessions(tx, user.ID, ""); err != nil {
+			return err
+		}
+		user.Password = hash
+		user.PasswordChangedAt = &now
+		return nil
+	})
+}
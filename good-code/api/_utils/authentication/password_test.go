@@ -0,0 +1,106 @@
+package authentication
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"", false},
+		{"short", false},
+		{"12345678", true},
+		{string(make([]byte, 72)), true},
+		{string(make([]byte, 73)), false},
+	}
+	for _, tt := range tests {
+		if err := ValidatePassword(tt.password); (err == nil) != tt.ok {
+			t.Errorf("ValidatePassword(%d bytes) = %v, want ok %v", len(tt.password), err, tt.ok)
+		}
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	conn := testDB(t)
+	user := db.UserLogin{Email: "someone@example.com", Name: "Someone", Enabled: true}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	session := db.Session{ID: "signed-in", UserLoginID: user.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := conn.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := IssueToken(conn, user.ID, db.TokenResetPassword, ResetTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("weak password", func(t *testing.T) {
+		if _, err := ResetPassword(conn, token, "short"); err == nil {
+			t.Fatal("ResetPassword() accepted a weak password")
+		}
+	})
+
+	t.Run("failed reset keeps the token", func(t *testing.T) {
+		// Signing out everywhere fails halfway through the reset
+		if err := conn.Migrator().RenameTable(&db.Session{}, "sessions_away"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ResetPassword(conn, token, "correct horse")
+		if err := conn.Migrator().RenameTable("sessions_away", &db.Session{}); err != nil {
+			t.Fatal(err)
+		}
+		if err == nil {
+			t.Fatal("ResetPassword() succeeded without the sessions table")
+		}
+		var stored db.UserLogin
+		conn.First(&stored, user.ID)
+		if stored.Password != nil || stored.EmailVerifiedAt != nil {
+			t.Errorf("user = %+v, want the failed reset rolled back", stored)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		reset, err := ResetPassword(conn, token, "correct horse")
+		if err != nil {
+			t.Fatalf("ResetPassword() error = %v", err)
+		}
+		var stored db.UserLogin
+		conn.First(&stored, user.ID)
+		if bcrypt.CompareHashAndPassword(stored.Password, []byte("correct horse")) != nil {
+			t.Error("password not changed")
+		}
+		if stored.EmailVerifiedAt == nil || reset.EmailVerifiedAt == nil || stored.PasswordChangedAt == nil {
+			t.Errorf("user = %+v, want the email verified and the password change recorded", stored)
+		}
+		var signedIn db.Session
+		conn.First(&signedIn, "id = ?", session.ID)
+		if signedIn.RevokedAt == nil {
+			t.Error("session survived the reset")
+		}
+	})
+
+	t.Run("token used up", func(t *testing.T) {
+		if _, err := ResetPassword(conn, token, "another password"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ResetPassword() error = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired, err := IssueToken(conn, user.ID, db.TokenResetPassword, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ResetPassword(conn, expired, "another password"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("ResetPassword() error = %v, want ErrInvalidToken", err)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	keyring "github.com/chopstickleg/good-code/api/_utils/keyring"
+	"github.com/dgrijalva/jwt-go"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SessionIdleTTL is how long a session lasts without being refreshed
+	SessionIdleTTL = 24 * time.Hour
+	// SessionMaxAge is how long a session lasts however active it is
+	SessionMaxAge = 30 * 24 * time.Hour
+	// RefreshAfter is how old a cookie gets before it is reissued with a
+	// later expiry
+	RefreshAfter = time.Hour
+
+	// sessionRetention is how long expired and revoked sessions are kept
+	sessionRetention = 7 * 24 * time.Hour
+)
+
+// StartSession records a new session for the user on this browser and sets
+// the "auth" cookie every authenticated endpoint reads.
+func StartSession(w http.ResponseWriter, r *http.Request, conn *gorm.DB, user db.UserLogin) (*db.Session, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	now := time.Now()
+	session := db.Session{
+		ID:          hex.EncodeToString(raw),
+		UserLoginID: user.ID,
+		UserAgent:   r.UserAgent(),
+		IP:          ClientIP(r),
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(SessionIdleTTL),
+	}
+	if err := conn.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	err := conn.Where(&db.Session{UserLoginID: user.ID}).
+		Where("expires_at < ? OR revoked_at < ?", now.Add(-sessionRetention), now.Add(-sessionRetention)).
+		Delete(&db.Session{}).
+		Error
+	if err != nil {
+		log.Printf("Failed to clean up old sessions of user %d: %v", user.ID, err)
+	}
+
+	if err := setAuthCookie(w, user, session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RefreshSession pushes back the expiry of an active session, and of its
+// cookie, so users who keep coming back are not signed out every day. It only
+// does so once the cookie is RefreshAfter old, and never past SessionMaxAge.
+func RefreshSession(w http.ResponseWriter, r *http.Request, conn *gorm.DB, session *db.Session) error {
+	now := time.Now()
+	expiresAt := now.Add(SessionIdleTTL)
+	if limit := session.CreatedAt.Add(SessionMaxAge); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	if !expiresAt.After(session.ExpiresAt.Add(RefreshAfter)) {
+		return nil
+	}
+
+	var user db.UserLogin
+	if err := conn.Where(&db.UserLogin{ID: session.UserLoginID}).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to load user %d: %w", session.UserLoginID, err)
+	}
+	session.ExpiresAt = expiresAt
+	session.LastSeenAt = now
+	session.UserAgent = r.UserAgent()
+	session.IP = ClientIP(r)
+	err := conn.Model(&db.Session{}).
+		Where(&db.Session{ID: session.ID}).
+		Where("revoked_at IS NULL").
+		Updates(map[string]any{
+			"expires_at":   session.ExpiresAt,
+			"last_seen_at": session.LastSeenAt,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+		}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+	return setAuthCookie(w, user, *session)
+}
+
+// LegacySession returns the session standing in for a token from before
+// sessions existed, recording it the first time the token is seen. Its ID is
+// derived from the token, so however often the token comes back it maps to
+// the same session, which can be revoked like any other and is reissued as a
+// proper cookie when it is next refreshed.
+func LegacySession(conn *gorm.DB, tokenString string, userID int64, expiresAt time.Time) (*db.Session, error) {
+	sum := sha256.Sum256([]byte(tokenString))
+	now := time.Now()
+	session := db.Session{
+		ID:          hex.EncodeToString(sum[:16]),
+		UserLoginID: userID,
+		LastSeenAt:  now,
+		ExpiresAt:   expiresAt,
+	}
+	err := conn.Clauses(clause.OnConflict{DoNothing: true}).Create(&session).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to record session of legacy token: %w", err)
+	}
+	if err := conn.Where(&db.Session{ID: session.ID, UserLoginID: userID}).First(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to load session of legacy token: %w", err)
+	}
+	return &session, nil
+}
+
+// RevokeSession signs one of the user's sessions out. It reports whether there
+// was such a session still active.
+func RevokeSession(conn *gorm.DB, userID int64, sessionID string) (bool, error) {
+	result := conn.Model(&db.Session{}).
+		Where(&db.Session{ID: sessionID, UserLoginID: userID}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeSessions signs the user out everywhere, except for the session with
+// the given ID when there is one.
+func RevokeSessions(conn *gorm.DB, userID int64, except string) error {
+	query := conn.Model(&db.Session{}).
+		Where(&db.Session{UserLoginID: userID}).
+		Where("revoked_at IS NULL")
+	if except != "" {
+		query = query.Where("id <> ?", except)
+	}
+	if err := query.Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke sessions of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// ClearAuthCookie removes the "auth" cookie from the browser.
+func ClearAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClientIP is the address the request came from, as reported by the proxy in
+// front of the functions when there is one.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	if realIP := r.Header.Get("X-Real-Ip"); realIP != "" {
+		return realIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func setAuthCookie(w http.ResponseWriter, user db.UserLogin, session db.Session) error {
+	ring, err := keyring.Load()
+	if err != nil {
+		return err
+	}
+	signedToken, err := ring.Sign(jwt.MapClaims{
+		"iss":   keyring.Issuer,
+		"jti":   session.ID,
+		"id":    user.ID,
+		"email": user.Email,
+		"name":  user.Name,
+		"iat":   time.Now().Unix(),
+		"exp":   session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	//oven
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth",
+		Value:    signedToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
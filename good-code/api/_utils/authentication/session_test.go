@@ -0,0 +1,84 @@
+package authentication
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+)
+
+func TestLegacySession(t *testing.T) {
+	conn := testDB(t)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	first, err := LegacySession(conn, "token", 1, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == "" || first.UserLoginID != 1 || !first.ExpiresAt.Equal(expiresAt) || first.RevokedAt != nil {
+		t.Fatalf("LegacySession() = %+v, want an active session of user 1 expiring with the token", first)
+	}
+
+	t.Run("swapped once", func(t *testing.T) {
+		for range 3 {
+			again, err := LegacySession(conn, "token", 1, expiresAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.ID != first.ID {
+				t.Fatalf("LegacySession() ID = %q, want %q", again.ID, first.ID)
+			}
+		}
+		var count int64
+		conn.Model(&db.Session{}).Count(&count)
+		if count != 1 {
+			t.Errorf("%d sessions recorded, want 1", count)
+		}
+	})
+
+	t.Run("other token", func(t *testing.T) {
+		other, err := LegacySession(conn, "other token", 1, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other.ID == first.ID {
+			t.Errorf("two tokens share session %q", other.ID)
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		if _, err := LegacySession(conn, "token", 2, expiresAt); err == nil {
+			t.Error("LegacySession() handed user 2 the session of user 1")
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		revoked, err := RevokeSession(conn, 1, first.ID)
+		if err != nil || !revoked {
+			t.Fatalf("RevokeSession() = %v, %v", revoked, err)
+		}
+		again, err := LegacySession(conn, "token", 1, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != first.ID || again.RevokedAt == nil {
+			t.Errorf("LegacySession() = %+v, want the revoked session", again)
+		}
+	})
+
+	t.Run("revoked everywhere", func(t *testing.T) {
+		if _, err := LegacySession(conn, "third token", 1, expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		if err := RevokeSessions(conn, 1, ""); err != nil {
+			t.Fatal(err)
+		}
+		again, err := LegacySession(conn, "third token", 1, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.RevokedAt == nil {
+			t.Errorf("LegacySession() = %+v, want it revoked", again)
+		}
+	})
+}
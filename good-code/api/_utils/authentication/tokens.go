@@ -0,0 +1,96 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidToken is returned for tokens that are unknown, expired, already
+// used or meant for something else.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// IssueToken creates a single-use token for the user that expires after ttl,
+// invalidating any earlier unused token they had for the same purpose. The
+// token itself is returned to be emailed; only its hash is stored.
+func IssueToken(conn *gorm.DB, userID int64, purpose string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&db.AccountToken{}).
+			Where(&db.AccountToken{UserLoginID: userID, Purpose: purpose}).
+			Where("used_at IS NULL").
+			Update("used_at", time.Now()).
+			Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&db.AccountToken{
+			UserLoginID: userID,
+			Purpose:     purpose,
+			TokenHash:   hashToken(token),
+			ExpiresAt:   time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to issue %s token for user %d: %w", purpose, userID, err)
+	}
+	return token, nil
+}
+
+// ConsumeToken marks the token used and returns it, in a single update so the
+// same token can never be consumed twice.
+func ConsumeToken(conn *gorm.DB, token string, purpose string) (*db.AccountToken, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	result := conn.Model(&db.AccountToken{}).
+		Where(&db.AccountToken{TokenHash: hashToken(token), Purpose: purpose}).
+		Where("used_at IS NULL AND expires_at > ?", now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume %s token: %w", purpose, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+	var consumed db.AccountToken
+	if err := conn.Where(&db.AccountToken{TokenHash: hashToken(token)}).First(&consumed).Error; err != nil {
+		return nil, fmt.Errorf("failed to load %s token: %w", purpose, err)
+	}
+	return &consumed, nil
+}
+
+// LastIssued is when the user was last sent a token for the purpose, zero if
+// never.
+func LastIssued(conn *gorm.DB, userID int64, purpose string) (time.Time, error) {
+	var last db.AccountToken
+	err := conn.Where(&db.AccountToken{UserLoginID: userID, Purpose: purpose}).
+		Order("created_at DESC").
+		First(&last).
+		Error
+	if err == gorm.ErrRecordNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return last.CreatedAt, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package authentication
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+)
+
+func TestConsumeToken(t *testing.T) {
+	conn := testDB(t)
+	earlier, err := IssueToken(conn, 1, db.TokenVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := IssueToken(conn, 1, db.TokenVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := IssueToken(conn, 2, db.TokenVerifyEmail, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored db.AccountToken
+	conn.Where("user_login_id = ? AND used_at IS NULL", 1).First(&stored)
+	if stored.TokenHash == token || stored.TokenHash != hashToken(token) {
+		t.Errorf("stored %q, want only the hash of the token", stored.TokenHash)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		ok      bool
+	}{
+		{"empty", "", db.TokenVerifyEmail, false},
+		{"unknown", "nonsense", db.TokenVerifyEmail, false},
+		{"replaced by a newer token", earlier, db.TokenVerifyEmail, false},
+		{"expired", expired, db.TokenVerifyEmail, false},
+		{"other purpose", token, db.TokenResetPassword, false},
+		{"valid", token, db.TokenVerifyEmail, true},
+		{"already used", token, db.TokenVerifyEmail, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumed, err := ConsumeToken(conn, tt.token, tt.purpose)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("ConsumeToken() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConsumeToken() error = %v", err)
+			}
+			if consumed.UserLoginID != 1 || consumed.UsedAt == nil {
+				t.Errorf("ConsumeToken() = %+v, want the used token of user 1", consumed)
+			}
+		})
+	}
+}
+
+func TestLastIssued(t *testing.T) {
+	conn := testDB(t)
+	if last, err := LastIssued(conn, 1, db.TokenVerifyEmail); err != nil || !last.IsZero() {
+		t.Errorf("LastIssued() = %v, %v, want zero before any token", last, err)
+	}
+	before := time.Now().Add(-time.Second)
+	if _, err := IssueToken(conn, 1, db.TokenVerifyEmail, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := LastIssued(conn, 1, db.TokenVerifyEmail); err != nil || last.Before(before) {
+		t.Errorf("LastIssued() = %v, %v, want the token just issued", last, err)
+	}
+	if last, err := LastIssued(conn, 1, db.TokenResetPassword); err != nil || !last.IsZero() {
+		t.Errorf("LastIssued() for another purpose = %v, %v, want zero", last, err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	conn := testDB(t)
+	user := db.UserLogin{Email: "someone@example.com", Enabled: true}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := IssueToken(conn, user.ID, db.TokenVerifyEmail, VerificationTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := VerifyEmail(conn, token)
+	if err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if verified.ID != user.ID || verified.EmailVerifiedAt == nil {
+		t.Errorf("VerifyEmail() = %+v, want the user verified", verified)
+	}
+	var saved db.UserLogin
+	conn.First(&saved, user.ID)
+	if saved.EmailVerifiedAt == nil {
+		t.Error("VerifyEmail() did not save the verification")
+	}
+	if _, err := VerifyEmail(conn, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyEmail() twice error = %v, want ErrInvalidToken", err)
+	}
+}
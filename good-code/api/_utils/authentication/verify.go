@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	mail "github.com/chopstickleg/good-code/api/_utils/mail"
+
+	"gorm.io/gorm"
+)
+
+const (
+	VerificationTTL = 24 * time.Hour
+	// ResendInterval is how long a user waits before another verification
+	// email is sent
+	ResendInterval = time.Minute
+)
+
+// AppURL is where links in emails point to, APP_URL or the production site.
+func AppURL() string {
+	if v := os.Getenv("APP_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "https://www.good-code.net"
+}
+
+// SendVerification emails the user a link that verifies their address.
+func SendVerification(ctx context.Context, conn *gorm.DB, user db.UserLogin) error {
+	sender, err := mail.NewSender(mail.ConfigFromEnv())
+	if err != nil {
+		return err
+	}
+	token, err := IssueToken(conn, user.ID, db.TokenVerifyEmail, VerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := AppURL() + "/api/account/verify?" + url.Values{"token": {token}}.Encode()
+	return sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Good Code email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm this is your email address by opening the link below within %d hours:\n\n%s\n\nIf you did not sign up for Good Code, ignore this email.\n",
+			user.Name, int(VerificationTTL.Hours()), link),
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the address of the
+// user it was issued to as verified.
+func VerifyEmail(conn *gorm.DB, token string) (*db.UserLogin, error) {
+	consumed, err := ConsumeToken(conn, token, db.TokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	var user db.UserLogin
+	if err := conn.Where(&db.UserLogin{ID: consumed.UserLoginID}).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user %d: %w", consumed.UserLoginID, err)
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		err = conn.Model(&db.UserLogin{}).
+			Where(&db.UserLogin{ID: user.ID}).
+			Update("email_verified_at", now).
+			Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify email of user %d: %w", user.ID, err)
+		}
+		user.EmailVerifiedAt = &now
+	}
+	return &user, nil
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prefix starts every GoodCode command in a pull request comment.
+const Prefix = "/goodcode"
+
+const (
+	Roast   = "roast"
+	Explain = "explain"
+	Persona = "persona"
+	Ignore  = "ignore"
+	Help    = "help"
+)
+
+type Spec struct {
+	Name        string
+	Usage       string
+	Description string
+	// Role is the minimum repository role needed to run the command
+	Role string
+}
+
+// Specs lists the supported commands in the order they are documented.
+var Specs = []Spec{
+	{Name: Roast, Usage: "roast", Description: "Roast the pull request again from scratch, resuming reviews if they were ignored.", Role: "write"},
+	{Name: Explain, Usage: "explain <file>", Description: "Explain the changes made to a file.", Role: "write"},
+	{Name: Persona, Usage: "persona <name>", Description: "Use another persona for this pull request.", Role: "write"},
+	{Name: Ignore, Usage: "ignore", Description: "Stop reviewing this pull request.", Role: "write"},
+	{Name: Help, Usage: "help", Description: "Show this message.", Role: ""},
+}
+
+type Command struct {
+	Name string
+	Args []string
+}
+
+func Lookup(name string) (Spec, bool) {
+	for _, spec := range Specs {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Parse finds the first line of the comment that starts with the prefix. A
+// bare prefix is treated as a request for help.
+func Parse(body string) (*Command, bool) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != Prefix {
+			continue
+		}
+		if len(fields) == 1 {
+			return &Command{Name: Help}, true
+		}
+		return &Command{Name: strings.ToLower(fields[1]), Args: fields[2:]}, true
+	}
+	return nil, false
+}
+
+func HelpText() string {
+	var b strings.Builder
+	b.WriteString("Here's what I respond to:\n\n")
+	for _, spec := range Specs {
+		b.WriteString(fmt.Sprintf("- `%s %s`: %s\n", Prefix, spec.Usage, spec.Description))
+	}
+	return b.String()
+}
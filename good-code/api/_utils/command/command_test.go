@@ -0,0 +1,54 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *Command
+	}{
+		{"command", "/goodcode roast", &Command{Name: Roast, Args: []string{}}},
+		{"arguments", "/goodcode explain  api/main.go ", &Command{Name: Explain, Args: []string{"api/main.go"}}},
+		{"upper case", "/goodcode PERSONA mentor", &Command{Name: Persona, Args: []string{"mentor"}}},
+		{"bare prefix", "  /goodcode  ", &Command{Name: Help}},
+		{"first command wins", "Thanks!\n/goodcode ignore\n/goodcode roast", &Command{Name: Ignore, Args: []string{}}},
+		{"unknown command", "/goodcode dance", &Command{Name: "dance", Args: []string{}}},
+		{"not at the start of a line", "try /goodcode roast", nil},
+		{"prefix in a word", "/goodcoderoast", nil},
+		{"no command", "Looks good to me", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse(tt.body)
+			if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.body, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, spec := range Specs {
+		got, ok := Lookup(spec.Name)
+		if !ok || got != spec {
+			t.Errorf("Lookup(%q) = %+v, %v, want %+v", spec.Name, got, ok, spec)
+		}
+	}
+	if _, ok := Lookup("dance"); ok {
+		t.Error("Lookup() found an unknown command")
+	}
+}
+
+func TestHelpText(t *testing.T) {
+	text := HelpText()
+	for _, spec := range Specs {
+		if line := "- `/goodcode " + spec.Usage + "`: " + spec.Description + "\n"; !strings.Contains(text, line) {
+			t.Errorf("HelpText() = %q, want it to contain %q", text, line)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+	policy "github.com/chopstickleg/good-code/api/_utils/policy"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"github.com/google/go-github/v72/github"
+	"gopkg.in/yaml.v3"
+)
+
+const FileName = ".goodcode.yml"
+
+type RepoConfig struct {
+	Tone        string   `yaml:"tone" json:"tone"`
+	Provider    string   `yaml:"provider" json:"provider"`
+	Model       string   `yaml:"model" json:"model"`
+	Ignore      []string `yaml:"ignore" json:"ignore"`
+	MaxDiffSize int      `yaml:"max_diff_size" json:"max_diff_size"`
+	Language    string   `yaml:"language" json:"language"`
+	Checks      []string `yaml:"checks" json:"checks"`
+	// Conclusion sets the severities at which the check run fails or turns neutral
+	Conclusion ConclusionConfig `yaml:"conclusion" json:"conclusion"`
+	// Context controls how much of the surrounding code the model gets to see
+	Context ContextConfig `yaml:"context" json:"context"`
+	// Triggers decide which pull requests get roasted at all
+	Triggers policy.Rules `yaml:"triggers" json:"triggers"`
+}
+
+type ContextConfig struct {
+	// Lines around each hunk, taken from the file at the head of the pull
+	// request; 0 sends the diff alone
+	Lines int `yaml:"lines" json:"lines"`
+}
+
+type ConclusionConfig struct {
+	Failure string `yaml:"failure" json:"failure"`
+	Neutral string `yaml:"neutral" json:"neutral"`
+}
+
+// Resolved is the configuration actually in effect for a repository, along
+// with where it came from and anything wrong with the file.
+type Resolved struct {
+	Config RepoConfig `json:"config"`
+	Source string     `json:"source"`
+	Ref    string     `json:"ref"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+func Default() RepoConfig {
+	return RepoConfig{
+		Checks: []string{},
+		Ignore: []string{},
+		Conclusion: ConclusionConfig{
+			Failure: "critical",
+			Neutral: "high",
+		},
+		Context: ContextConfig{
+			Lines: 20,
+		},
+		Triggers: policy.Default(),
+	}
+}
+
+// Parse decodes and validates a .goodcode.yml file. Unknown keys and invalid
+// values are reported as errors and left at their defaults; every other
+// setting in the file still applies.
+func Parse(data []byte) (RepoConfig, []string) {
+	cfg := Default()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return cfg, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return cfg, []string{err.Error()}
+	}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+
+	target := reflect.ValueOf(&cfg).Elem()
+	errs := decodeFields(doc.Content[0], target, "")
+	defaults := reflect.ValueOf(Default())
+	for _, e := range cfg.Validate() {
+		key, _, _ := strings.Cut(e, ":")
+		resetField(target, defaults, strings.Split(key, "."))
+		errs = append(errs, e)
+	}
+	return cfg, errs
+}
+
+// decodeFields decodes a mapping into the struct one key at a time, so a bad
+// value only costs that one setting.
+func decodeFields(node *yaml.Node, target reflect.Value, prefix string) []string {
+	if node.Kind != yaml.MappingNode {
+		if prefix == "" {
+			return []string{fmt.Sprintf("line %d: expected a mapping of settings", node.Line)}
+		}
+		return []string{fmt.Sprintf("%s: line %d: expected a mapping", prefix, node.Line)}
+	}
+	var errs []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := key.Value
+		if prefix != "" {
+			name = prefix + "." + key.Value
+		}
+		index, ok := fieldIndex(target, key.Value)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: line %d: unknown setting", name, key.Line))
+			continue
+		}
+		field := target.Field(index)
+		if field.Kind() == reflect.Struct {
+			errs = append(errs, decodeFields(value, field, name)...)
+			continue
+		}
+		decoded := reflect.New(field.Type())
+		if err := value.Decode(decoded.Interface()); err != nil {
+			errs = append(errs, name+": "+strings.TrimSpace(strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:")))
+			continue
+		}
+		field.Set(decoded.Elem())
+	}
+	return errs
+}
+
+// resetField puts the setting at the given key path back to its default.
+func resetField(target reflect.Value, defaults reflect.Value, path []string) {
+	for _, key := range path {
+		index, ok := fieldIndex(target, key)
+		if !ok {
+			return
+		}
+		target, defaults = target.Field(index), defaults.Field(index)
+	}
+	target.Set(defaults)
+}
+
+// fieldIndex finds the struct field a YAML key decodes into.
+func fieldIndex(target reflect.Value, key string) (int, bool) {
+	if target.Kind() != reflect.Struct {
+		return 0, false
+	}
+	for i := range target.NumField() {
+		name, _, _ := strings.Cut(target.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (c RepoConfig) Validate() []string {
+	var errs []string
+	if c.Provider != "" && !llm.ValidProvider(c.Provider) {
+		errs = append(errs, fmt.Sprintf("provider: unknown provider %q", c.Provider))
+	}
+	for _, pattern := range c.Ignore {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			errs = append(errs, fmt.Sprintf("ignore: invalid glob %q", pattern))
+		}
+	}
+	if c.MaxDiffSize < 0 {
+		errs = append(errs, "max_diff_size: must not be negative")
+	}
+	for _, check := range c.Checks {
+		if _, ok := roast.Checks[check]; !ok {
+			errs = append(errs, fmt.Sprintf("checks: unknown check %q (expected any of %s)", check, keys(roast.Checks)))
+		}
+	}
+	if !validThreshold(c.Conclusion.Failure) {
+		errs = append(errs, fmt.Sprintf("conclusion.failure: unknown severity %q (expected any of %s, %s)", c.Conclusion.Failure, strings.Join(roast.Severities, ", "), roast.SeverityNever))
+	}
+	if !validThreshold(c.Conclusion.Neutral) {
+		errs = append(errs, fmt.Sprintf("conclusion.neutral: unknown severity %q (expected any of %s, %s)", c.Conclusion.Neutral, strings.Join(roast.Severities, ", "), roast.SeverityNever))
+	}
+	if c.Context.Lines < 0 {
+		errs = append(errs, "context.lines: must not be negative")
+	}
+	errs = append(errs, c.Triggers.Validate("triggers")...)
+	return errs
+}
+
+func (c RepoConfig) Instructions() roast.Instructions {
+	return roast.Instructions{
+		Language: c.Language,
+		Checks:   c.Checks,
+	}
+}
+
+// Fetch reads the configuration file at the given ref. A missing file is not
+// an error; the defaults are returned instead.
+func Fetch(ctx context.Context, client *github.Client, owner string, repo string, ref string) (*Resolved, error) {
+	resolved := &Resolved{Config: Default(), Source: "default", Ref: ref}
+
+	content, found, err := FetchFile(ctx, client, owner, repo, FileName, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return resolved, nil
+	}
+
+	cfg, errs := Parse(content)
+	if len(errs) > 0 {
+		log.Printf("Invalid settings in %s in %s/%s@%s: %v", FileName, owner, repo, ref, errs)
+		resolved.Errors = errs
+	}
+	resolved.Config = cfg
+	resolved.Source = FileName
+	return resolved, nil
+}
+
+// FetchFile reads a single file from the repository at the given ref,
+// reporting whether it exists.
+func FetchFile(ctx context.Context, client *github.Client, owner string, repo string, name string, ref string) ([]byte, bool, error) {
+	file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, name, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	if file == nil {
+		return nil, false, fmt.Errorf("%s is not a file", name)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return []byte(content), true, nil
+}
+
+func validThreshold(severity string) bool {
+	return severity == roast.SeverityNever || slices.Contains(roast.Severities, severity)
+}
+
+func keys(m map[string]string) string {
+	return strings.Join(slices.Sorted(maps.Keys(m)), ", ")
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   func(cfg *RepoConfig)
+		errors []string
+	}{
+		{
+			name: "empty file",
+			data: "  \n",
+			want: func(cfg *RepoConfig) {},
+		},
+		{
+			name: "every setting",
+			data: `tone: friendly
+provider: openai
+model: gpt-4o
+ignore: ["vendor/**", "*.lock"]
+max_diff_size: 5000
+language: Go
+checks: [bugs, security]
+conclusion:
+  failure: high
+  neutral: never
+context:
+  lines: 5
+triggers:
+  drafts: true
+`,
+			want: func(cfg *RepoConfig) {
+				cfg.Tone = "friendly"
+				cfg.Provider = "openai"
+				cfg.Model = "gpt-4o"
+				cfg.Ignore = []string{"vendor/**", "*.lock"}
+				cfg.MaxDiffSize = 5000
+				cfg.Language = "Go"
+				cfg.Checks = []string{"bugs", "security"}
+				cfg.Conclusion = ConclusionConfig{Failure: "high", Neutral: "never"}
+				cfg.Context.Lines = 5
+				cfg.Triggers.Drafts = true
+			},
+		},
+		{
+			name: "unknown settings",
+			data: "tone: friendly\ncolour: blue\nconclusion:\n  warning: low\n",
+			want: func(cfg *RepoConfig) {
+				cfg.Tone = "friendly"
+			},
+			errors: []string{"colour: line 2: unknown setting", "conclusion.warning: line 4: unknown setting"},
+		},
+		{
+			name: "wrong type keeps the other settings",
+			data: "max_diff_size: lots\nlanguage: Go\n",
+			want: func(cfg *RepoConfig) {
+				cfg.Language = "Go"
+			},
+			errors: []string{"max_diff_size: line 1: cannot unmarshal !!str `lots` into int"},
+		},
+		{
+			name: "invalid values fall back to their defaults",
+			data: "provider: clippy\nchecks: [bugs, vibes]\nconclusion:\n  failure: apocalyptic\n  neutral: low\ncontext:\n  lines: -1\n",
+			want: func(cfg *RepoConfig) {
+				cfg.Conclusion.Neutral = "low"
+			},
+			errors: []string{
+				`provider: unknown provider "clippy"`,
+				`checks: unknown check "vibes" (expected any of bugs, documentation, performance, security, style, tests)`,
+				`conclusion.failure: unknown severity "apocalyptic" (expected any of critical, high, medium, low, info, never)`,
+				"context.lines: must not be negative",
+			},
+		},
+		{
+			name: "invalid trigger",
+			data: "triggers:\n  drafts: true\n  max_changed_lines: -5\n",
+			want: func(cfg *RepoConfig) {
+				cfg.Triggers.Drafts = true
+			},
+			errors: []string{"triggers.max_changed_lines: must not be negative"},
+		},
+		{
+			name:   "not a mapping",
+			data:   "- tone\n- friendly\n",
+			want:   func(cfg *RepoConfig) {},
+			errors: []string{"line 1: expected a mapping of settings"},
+		},
+		{
+			name:   "nested setting not a mapping",
+			data:   "context: 5\n",
+			want:   func(cfg *RepoConfig) {},
+			errors: []string{"context: line 1: expected a mapping"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := Default()
+			tt.want(&want)
+
+			cfg, errs := Parse([]byte(tt.data))
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("Parse() = %+v, want %+v", cfg, want)
+			}
+			if !slices.Equal(errs, tt.errors) {
+				t.Errorf("Parse() errors = %q, want %q", errs, tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseInvalidYAML(t *testing.T) {
+	cfg, errs := Parse([]byte("tone: [friendly\n"))
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "yaml: ") {
+		t.Errorf("Parse() errors = %q, want the YAML error", errs)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Parse() = %+v, want the defaults", cfg)
+	}
+}
+
+func TestValidateDefault(t *testing.T) {
+	if errs := Default().Validate(); len(errs) != 0 {
+		t.Errorf("Default().Validate() = %q, want no errors", errs)
+	}
+}
+
+func TestValidateIgnore(t *testing.T) {
+	cfg := Default()
+	cfg.Ignore = []string{"vendor/**", "[bad"}
+	want := []string{`ignore: invalid glob "[bad"`}
+	if errs := cfg.Validate(); !slices.Equal(errs, want) {
+		t.Errorf("Validate() = %q, want %q", errs, want)
+	}
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"strings"
+)
+
+// AttrState is the state of an attribute for a path, as in gitattributes(5).
+type AttrState int
+
+const (
+	AttrUnspecified AttrState = iota
+	AttrSet
+	AttrUnset
+)
+
+type attributeRule struct {
+	pattern string
+	values  map[string]AttrState
+}
+
+// Attributes is the parsed subset of a .gitattributes file needed to decide
+// whether a path is set, unset or unspecified for boolean attributes.
+type Attributes struct {
+	rules []attributeRule
+}
+
+func ParseAttributes(data string) Attributes {
+	var attrs Attributes
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := attributeRule{pattern: fields[0], values: map[string]AttrState{}}
+		for _, attr := range fields[1:] {
+			switch {
+			case strings.HasPrefix(attr, "-"):
+				rule.values[attr[1:]] = AttrUnset
+			case strings.HasPrefix(attr, "!"):
+				// Resets the attribute to how it would be had no earlier line mentioned it
+				rule.values[attr[1:]] = AttrUnspecified
+			default:
+				// linguist reads "=false" and "=0" as unset too
+				name, value, found := strings.Cut(attr, "=")
+				if found && (value == "false" || value == "0") {
+					rule.values[name] = AttrUnset
+				} else {
+					rule.values[name] = AttrSet
+				}
+			}
+		}
+		attrs.rules = append(attrs.rules, rule)
+	}
+	return attrs
+}
+
+// State reports the state of the attribute for the path. As in git, the last
+// matching line wins.
+func (a Attributes) State(name string, attr string) AttrState {
+	state := AttrUnspecified
+	for _, rule := range a.rules {
+		value, ok := rule.values[attr]
+		if !ok || !MatchGlob(rule.pattern, name) {
+			continue
+		}
+		state = value
+	}
+	return state
+}
+
+// IsSet reports whether the attribute is set for the path.
+func (a Attributes) IsSet(name string, attr string) bool {
+	return a.State(name, attr) == AttrSet
+}
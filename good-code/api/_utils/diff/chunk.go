@@ -0,0 +1,97 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Chunk struct {
+	Files  []string
+	Text   string
+	Tokens int
+}
+
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// EstimateTokens is a rough, provider-agnostic token count. Around four
+// characters per token holds up well enough for source code.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Split packs the files into chunks that each stay under the token budget.
+// Whole files are kept together when they fit, otherwise they are split on
+// hunk boundaries with the file header repeated in every piece.
+func Split(files []File, budget int) ([]Chunk, []SkippedFile) {
+	var chunks []Chunk
+	var skipped []SkippedFile
+	var current strings.Builder
+	var currentFiles []string
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			Files:  currentFiles,
+			Text:   current.String(),
+			Tokens: EstimateTokens(current.String()),
+		})
+		current.Reset()
+		currentFiles = nil
+	}
+	add := func(path string, text string) {
+		if current.Len() > 0 && EstimateTokens(current.String())+EstimateTokens(text) > budget {
+			flush()
+		}
+		current.WriteString(text)
+		if len(currentFiles) == 0 || currentFiles[len(currentFiles)-1] != path {
+			currentFiles = append(currentFiles, path)
+		}
+	}
+
+	for i := range files {
+		file := &files[i]
+		if file.IsBinary {
+			skipped = append(skipped, SkippedFile{Path: file.Path(), Reason: "binary file"})
+			continue
+		}
+		if len(file.Hunks) == 0 {
+			// Pure renames and mode changes have nothing worth reviewing
+			continue
+		}
+
+		text := file.String()
+		if EstimateTokens(text) <= budget {
+			add(file.Path(), text)
+			continue
+		}
+
+		header := strings.Join(file.Header, "\n") + "\n"
+		piece := header
+		for _, hunk := range file.Hunks {
+			hunkText := hunk.String()
+			if EstimateTokens(header+hunkText) > budget {
+				skipped = append(skipped, SkippedFile{
+					Path:   file.Path(),
+					Reason: fmt.Sprintf("hunk at line %d exceeds the token budget", hunk.NewStart),
+				})
+				continue
+			}
+			if EstimateTokens(piece+hunkText) > budget {
+				add(file.Path(), piece)
+				piece = header
+			}
+			piece += hunkText
+		}
+		if piece != header {
+			add(file.Path(), piece)
+		}
+	}
+	flush()
+
+	return chunks, skipped
+}
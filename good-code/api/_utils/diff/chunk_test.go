@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fileDiff renders the diff of a file with a hunk of the given number of
+// added lines for each entry of hunks, ten lines apart.
+func fileDiff(path string, hunks ...int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+	start := 1
+	for _, lines := range hunks {
+		fmt.Fprintf(&b, "@@ -%d,0 +%d,%d @@\n", start, start, lines)
+		for i := range lines {
+			fmt.Fprintf(&b, "+line %04d of %s\n", start+i, path)
+		}
+		start += lines + 10
+	}
+	return b.String()
+}
+
+func parseAll(t *testing.T, diffs ...string) []File {
+	t.Helper()
+	files, err := Parse(strings.Join(diffs, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSplitPacksWholeFiles(t *testing.T) {
+	files := parseAll(t, fileDiff("a.go", 10), fileDiff("b.go", 10), fileDiff("c.go", 10))
+	size := EstimateTokens(files[0].String())
+
+	chunks, skipped := Split(files, 2*size+1)
+	if len(skipped) != 0 {
+		t.Errorf("skipped %v, want nothing", skipped)
+	}
+	var got [][]string
+	for _, chunk := range chunks {
+		got = append(got, chunk.Files)
+		if chunk.Tokens != EstimateTokens(chunk.Text) {
+			t.Errorf("chunk of %v counts %d tokens, its text %d", chunk.Files, chunk.Tokens, EstimateTokens(chunk.Text))
+		}
+	}
+	want := [][]string{{"a.go", "b.go"}, {"c.go"}}
+	if !slices.EqualFunc(got, want, slices.Equal[[]string]) {
+		t.Errorf("chunks = %v, want %v", got, want)
+	}
+	if chunks[0].Text != files[0].String()+files[1].String() {
+		t.Errorf("first chunk is not the two files back to back:\n%s", chunks[0].Text)
+	}
+
+	if chunks, _ := Split(files, 100*size); len(chunks) != 1 {
+		t.Errorf("got %d chunks with room for everything, want 1", len(chunks))
+	}
+}
+
+func TestSplitOnHunkBoundaries(t *testing.T) {
+	files := parseAll(t, fileDiff("big.go", 20, 20, 20))
+	file := &files[0]
+	header := strings.Join(file.Header, "\n") + "\n"
+	hunk := EstimateTokens(file.Hunks[0].String())
+	// Room for the header and two hunks, not three
+	budget := EstimateTokens(header) + 2*hunk + 5
+
+	chunks, skipped := Split(files, budget)
+	if len(skipped) != 0 {
+		t.Errorf("skipped %v, want nothing", skipped)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	wantHunks := [][]int{{0, 1}, {2}}
+	for i, chunk := range chunks {
+		if chunk.Tokens > budget {
+			t.Errorf("chunk %d has %d tokens, over the budget of %d", i, chunk.Tokens, budget)
+		}
+		want := header
+		for _, h := range wantHunks[i] {
+			want += file.Hunks[h].String()
+		}
+		if chunk.Text != want {
+			t.Errorf("chunk %d =\n%s\nwant the header and hunks %v", i, chunk.Text, wantHunks[i])
+		}
+		if !slices.Equal(chunk.Files, []string{"big.go"}) {
+			t.Errorf("chunk %d files = %v", i, chunk.Files)
+		}
+	}
+}
+
+func TestSplitSkipsOversizedHunks(t *testing.T) {
+	files := parseAll(t, fileDiff("big.go", 5, 200, 5), fileDiff("small.go", 5))
+	header := strings.Join(files[0].Header, "\n") + "\n"
+	budget := EstimateTokens(header) + 3*EstimateTokens(files[0].Hunks[0].String())
+
+	chunks, skipped := Split(files, budget)
+	want := []SkippedFile{{Path: "big.go", Reason: "hunk at line 16 exceeds the token budget"}}
+	if !slices.Equal(skipped, want) {
+		t.Errorf("skipped %v, want %v", skipped, want)
+	}
+	var text strings.Builder
+	for _, chunk := range chunks {
+		text.WriteString(chunk.Text)
+	}
+	if strings.Contains(text.String(), "line 0016 of big.go") {
+		t.Error("oversized hunk made it into a chunk")
+	}
+	for _, line := range []string{"line 0001 of big.go", "line 0226 of big.go", "line 0001 of small.go"} {
+		if !strings.Contains(text.String(), line) {
+			t.Errorf("chunks lost %q", line)
+		}
+	}
+}
+
+func TestSplitSkipsBinariesAndEmptyFiles(t *testing.T) {
+	files := parseAll(t,
+		"diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ\n",
+		"diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n",
+		fileDiff("main.go", 3),
+	)
+	chunks, skipped := Split(files, 1000)
+	if want := []SkippedFile{{Path: "logo.png", Reason: "binary file"}}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped %v, want %v", skipped, want)
+	}
+	if len(chunks) != 1 || !slices.Equal(chunks[0].Files, []string{"main.go"}) {
+		t.Errorf("chunks = %+v, want main.go alone", chunks)
+	}
+}
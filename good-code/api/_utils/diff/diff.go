@@ -0,0 +1,249 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	LineContext = ' '
+	LineAdded   = '+'
+	LineRemoved = '-'
+)
+
+type Line struct {
+	Kind    byte
+	Content string
+	OldLine int
+	NewLine int
+	// Position is GitHub's "diff position": the number of lines below the
+	// file's first hunk header, counting later hunk headers too.
+	Position int
+}
+
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+type File struct {
+	OldPath  string
+	NewPath  string
+	IsBinary bool
+	Header   []string
+	Hunks    []Hunk
+}
+
+// Path is the name GitHub uses to address the file in review comments.
+func (f *File) Path() string {
+	if f.NewPath == "" || f.NewPath == "/dev/null" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+func (f *File) IsDeleted() bool {
+	return f.NewPath == "/dev/null"
+}
+
+// Position returns the diff position of a line in the new version of the
+// file, or false when that line is not part of the diff.
+func (f *File) Position(newLine int) (int, bool) {
+	for _, h := range f.Hunks {
+		if newLine < h.NewStart || newLine >= h.NewStart+h.NewLines {
+			continue
+		}
+		for _, l := range h.Lines {
+			if l.Kind != LineRemoved && l.NewLine == newLine {
+				return l.Position, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// String re-renders the file section as unified diff text.
+func (f *File) String() string {
+	var b strings.Builder
+	for _, h := range f.Header {
+		b.WriteString(h)
+		b.WriteByte('\n')
+	}
+	for _, h := range f.Hunks {
+		b.WriteString(h.String())
+	}
+	return b.String()
+}
+
+func (h *Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.Header)
+	b.WriteByte('\n')
+	for _, l := range h.Lines {
+		b.WriteByte(l.Kind)
+		b.WriteString(l.Content)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Parse splits a unified diff as returned by PullRequests.GetRaw into its
+// per-file sections.
+func Parse(raw string) ([]File, error) {
+	var files []File
+	var file *File
+	var hunk *Hunk
+	position := 0
+	oldLine, newLine := 0, 0
+
+	flushHunk := func() {
+		if file != nil && hunk != nil {
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if file != nil {
+			files = append(files, *file)
+		}
+		file = nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(raw, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			file = &File{Header: []string{line}}
+			file.OldPath, file.NewPath = parseGitHeader(line)
+			position = 0
+		case file == nil:
+			// Preamble before the first file, e.g. from a patch-formatted diff
+			continue
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			file.Header = append(file.Header, line)
+			file.OldPath = trimPathPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			file.Header = append(file.Header, line)
+			file.NewPath = trimPathPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case hunk == nil && strings.HasPrefix(line, "rename from "):
+			file.Header = append(file.Header, line)
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case hunk == nil && strings.HasPrefix(line, "rename to "):
+			file.Header = append(file.Header, line)
+			file.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hunk header in %s: %w", file.Path(), err)
+			}
+			if len(file.Hunks) > 0 {
+				// Every hunk header after the first one occupies a position
+				position++
+			}
+			hunk = h
+			oldLine, newLine = h.OldStart, h.NewStart
+		case hunk == nil:
+			file.Header = append(file.Header, line)
+			if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+				file.IsBinary = true
+			}
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" still counts as a diff position
+			position++
+		default:
+			position++
+			l := Line{Kind: LineContext, Position: position}
+			if len(line) > 0 {
+				l.Kind = line[0]
+				l.Content = line[1:]
+			}
+			switch l.Kind {
+			case LineAdded:
+				l.NewLine = newLine
+				newLine++
+			case LineRemoved:
+				l.OldLine = oldLine
+				oldLine++
+			default:
+				l.Kind = LineContext
+				l.OldLine = oldLine
+				l.NewLine = newLine
+				oldLine++
+				newLine++
+			}
+			hunk.Lines = append(hunk.Lines, l)
+		}
+	}
+	flushFile()
+
+	return files, nil
+}
+
+// parseGitHeader takes the paths from "diff --git a/old b/new". Unless the
+// file was renamed both are the same, which settles where they split even when
+// the path itself contains " b/"; renames are taken from the lines that follow.
+func parseGitHeader(line string) (string, string) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if n := len(rest) / 2; len(rest)%2 == 1 && strings.HasPrefix(rest, "a/") && rest[n:n+3] == " b/" && rest[2:n] == rest[n+3:] {
+		return rest[2:n], rest[n+3:]
+	}
+	idx := strings.Index(rest, " b/")
+	if idx < 0 {
+		return "", ""
+	}
+	return trimPathPrefix(rest[:idx], "a/"), rest[idx+1+len("b/"):]
+}
+
+func trimPathPrefix(path string, prefix string) string {
+	path = strings.TrimSuffix(path, "\t")
+	if path == "/dev/null" {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+func parseHunkHeader(line string) (*Hunk, error) {
+	// @@ -oldStart[,oldLines] +newStart[,newLines] @@ optional section
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return nil, fmt.Errorf("malformed header %q", line)
+	}
+	oldStart, oldLines, err := parseRange(strings.TrimPrefix(fields[1], "-"))
+	if err != nil {
+		return nil, err
+	}
+	newStart, newLines, err := parseRange(strings.TrimPrefix(fields[2], "+"))
+	if err != nil {
+		return nil, err
+	}
+	return &Hunk{
+		Header:   line,
+		OldStart: oldStart,
+		OldLines: oldLines,
+		NewStart: newStart,
+		NewLines: newLines,
+	}, nil
+}
+
+func parseRange(r string) (int, int, error) {
+	start, count, found := strings.Cut(r, ",")
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %w", r, err)
+	}
+	if !found {
+		return s, 1, nil
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %w", r, err)
+	}
+	return s, c, nil
+}
@@ -0,0 +1,165 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const twoHunks = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++
+ import "fmt"
+ 
+@@ -10,3 +11,3 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
+ 	fmt.Println(a, b)
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-# Old
+\ No newline at end of file
++# New
+\ No newline at end of file
+`
+
+func TestParsePositions(t *testing.T) {
+	files, err := Parse(twoHunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Parse() returned %d files, want 2", len(files))
+	}
+
+	tests := []struct {
+		file     int
+		newLine  int
+		position int
+		ok       bool
+	}{
+		// The line below the first hunk header is position 1
+		{0, 1, 1, true},
+		{0, 2, 2, true},
+		{0, 3, 3, true},
+		{0, 4, 4, true},
+		// The second hunk header takes position 5
+		{0, 11, 6, true},
+		// The removed line takes position 7
+		{0, 12, 8, true},
+		{0, 13, 9, true},
+		// Between the hunks and past the end
+		{0, 5, 0, false},
+		{0, 14, 0, false},
+		// "\ No newline at end of file" after the removed line takes position 2
+		{1, 1, 3, true},
+	}
+	for _, tt := range tests {
+		file := &files[tt.file]
+		position, ok := file.Position(tt.newLine)
+		if position != tt.position || ok != tt.ok {
+			t.Errorf("%s: Position(%d) = %d, %v, want %d, %v", file.Path(), tt.newLine, position, ok, tt.position, tt.ok)
+		}
+	}
+
+	second := files[0].Hunks[1]
+	if second.OldStart != 10 || second.OldLines != 3 || second.NewStart != 11 || second.NewLines != 3 {
+		t.Errorf("second hunk = %+v", second)
+	}
+	if removed := second.Lines[1]; removed.Kind != LineRemoved || removed.OldLine != 11 || removed.NewLine != 0 {
+		t.Errorf("removed line = %+v", removed)
+	}
+	if single := files[1].Hunks[0]; single.OldLines != 1 || single.NewLines != 1 {
+		t.Errorf("hunk without counts = %+v, want one line each side", single)
+	}
+}
+
+func TestParsePaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		oldPath string
+		newPath string
+		path    string
+	}{
+		{
+			name:    "modified",
+			raw:     "diff --git a/cmd/main.go b/cmd/main.go\n--- a/cmd/main.go\n+++ b/cmd/main.go\n@@ -1 +1 @@\n-a\n+b\n",
+			oldPath: "cmd/main.go", newPath: "cmd/main.go", path: "cmd/main.go",
+		},
+		{
+			name:    "path containing b/",
+			raw:     "diff --git a/docs/a b/c.md b/docs/a b/c.md\nold mode 100644\nnew mode 100755\n",
+			oldPath: "docs/a b/c.md", newPath: "docs/a b/c.md", path: "docs/a b/c.md",
+		},
+		{
+			name:    "added",
+			raw:     "diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package new\n",
+			oldPath: "/dev/null", newPath: "new.go", path: "new.go",
+		},
+		{
+			name:    "deleted",
+			raw:     "diff --git a/old.go b/old.go\ndeleted file mode 100644\n--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package old\n",
+			oldPath: "old.go", newPath: "/dev/null", path: "old.go",
+		},
+		{
+			name:    "renamed",
+			raw:     "diff --git a/x b/y.go b/z.go\nsimilarity index 100%\nrename from x b/y.go\nrename to z.go\n",
+			oldPath: "x b/y.go", newPath: "z.go", path: "z.go",
+		},
+		{
+			name:    "renamed and changed",
+			raw:     "diff --git a/a b/b.go b/c.go\nsimilarity index 90%\nrename from a b/b.go\nrename to c.go\n--- a/a b/b.go\n+++ b/c.go\n@@ -1 +1 @@\n-a\n+b\n",
+			oldPath: "a b/b.go", newPath: "c.go", path: "c.go",
+		},
+		{
+			name:    "binary",
+			raw:     "diff --git a/logo.png b/logo.png\nindex 1111111..2222222 100644\nBinary files a/logo.png and b/logo.png differ\n",
+			oldPath: "logo.png", newPath: "logo.png", path: "logo.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("Parse() returned %d files, want 1", len(files))
+			}
+			file := files[0]
+			if file.OldPath != tt.oldPath || file.NewPath != tt.newPath || file.Path() != tt.path {
+				t.Errorf("paths = %q, %q, %q, want %q, %q, %q", file.OldPath, file.NewPath, file.Path(), tt.oldPath, tt.newPath, tt.path)
+			}
+			if file.IsBinary != (tt.name == "binary") {
+				t.Errorf("IsBinary = %v", file.IsBinary)
+			}
+			if file.IsDeleted() != (tt.name == "deleted") {
+				t.Errorf("IsDeleted() = %v", file.IsDeleted())
+			}
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	files, err := Parse(twoHunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := twoHunks[:strings.Index(twoHunks, "diff --git a/README.md")]
+	if got := files[0].String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseMalformedHunk(t *testing.T) {
+	if _, err := Parse("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -x +1 @@\n"); err == nil {
+		t.Error("Parse() accepted a malformed hunk header")
+	}
+}
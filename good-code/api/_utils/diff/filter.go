@@ -0,0 +1,161 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultIgnore covers files that are never worth sending to the model:
+// lockfiles, vendored dependencies, minified bundles and generated code.
+var DefaultIgnore = []string{
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"bun.lockb",
+	"go.sum",
+	"Cargo.lock",
+	"Gemfile.lock",
+	"composer.lock",
+	"poetry.lock",
+	"Pipfile.lock",
+	"vendor/",
+	"node_modules/",
+	"third_party/",
+	"*.min.js",
+	"*.min.css",
+	"*.map",
+	"*.pb.go",
+	"*_generated.go",
+	"*.generated.*",
+	"*.snap",
+}
+
+// minifiedLineLength is the length past which an added line is assumed to
+// come from a minified or otherwise machine-written file.
+const minifiedLineLength = 1000
+
+type PreprocessOptions struct {
+	// Ignore holds the repository's own glob patterns
+	Ignore       []string
+	IgnoreReason string
+	Attributes   Attributes
+}
+
+// Preprocess drops files that should not be reviewed, in order: binaries,
+// files the repository ignores, files marked linguist-generated or
+// linguist-vendored in .gitattributes, the built-in defaults and anything
+// that looks minified or generated from its contents. Files with either
+// attribute explicitly unset are only dropped by the first two.
+func Preprocess(files []File, opts PreprocessOptions) ([]File, []SkippedFile) {
+	kept := make([]File, 0, len(files))
+	var skipped []SkippedFile
+	for _, file := range files {
+		if reason := skipReason(&file, opts); reason != "" {
+			skipped = append(skipped, SkippedFile{Path: file.Path(), Reason: reason})
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, skipped
+}
+
+func skipReason(file *File, opts PreprocessOptions) string {
+	name := file.Path()
+	if file.IsBinary {
+		return "binary file"
+	}
+	if pattern, ok := MatchAny(opts.Ignore, name); ok {
+		return fmt.Sprintf("%s (%s)", opts.IgnoreReason, pattern)
+	}
+	generated := opts.Attributes.State(name, "linguist-generated")
+	vendored := opts.Attributes.State(name, "linguist-vendored")
+	if generated == AttrSet {
+		return "marked linguist-generated in .gitattributes"
+	}
+	if vendored == AttrSet {
+		return "marked linguist-vendored in .gitattributes"
+	}
+	// Explicitly unsetting either attribute opts the file back in, the way
+	// linguist treats it
+	if generated == AttrUnset || vendored == AttrUnset {
+		return ""
+	}
+	if pattern, ok := MatchAny(DefaultIgnore, name); ok {
+		return fmt.Sprintf("ignored by default (%s)", pattern)
+	}
+	if file.IsDeleted() {
+		return ""
+	}
+	for i, hunk := range file.Hunks {
+		for j, line := range hunk.Lines {
+			if line.Kind != LineAdded {
+				continue
+			}
+			if len(line.Content) > minifiedLineLength {
+				return "looks minified"
+			}
+			// Generated code announces itself in the first few lines of the file
+			if i == 0 && hunk.NewStart == 1 && j < 5 && isGeneratedMarker(line.Content) {
+				return "looks generated"
+			}
+		}
+	}
+	return ""
+}
+
+func isGeneratedMarker(line string) bool {
+	return (strings.Contains(line, "Code generated") && strings.Contains(line, "DO NOT EDIT")) ||
+		strings.Contains(line, "@generated")
+}
+
+// MatchAny reports the first pattern matching the path.
+func MatchAny(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, name) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// MatchGlob matches a slash-separated path against a gitignore-style glob.
+// "**" matches any number of directories, a trailing slash matches everything
+// below a directory and, unless a leading slash anchors it, a pattern with no
+// other slash matches at any depth.
+func MatchGlob(pattern string, name string) bool {
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !anchored && !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	if directory {
+		pattern += "/**"
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
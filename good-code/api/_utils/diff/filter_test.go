@@ -0,0 +1,120 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"go.sum", "go.sum", true},
+		{"go.sum", "tools/go.sum", true},
+		{"/go.sum", "go.sum", true},
+		{"/go.sum", "tools/go.sum", false},
+		{"*.min.js", "static/js/app.min.js", true},
+		{"*.min.js", "static/js/app.js", false},
+		{"vendor/", "vendor/github.com/lib/pq/conn.go", true},
+		{"vendor/", "internal/vendor/lib.go", true},
+		{"vendor/", "vendors/lib.go", false},
+		{"/vendor/", "internal/vendor/lib.go", false},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "site/docs/intro.md", false},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/**/*.md", "docs/guide/intro.md", true},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"**/testdata/**", "pkg/testdata/golden.txt", true},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	attrs := ParseAttributes(strings.Join([]string{
+		"# comment linguist-generated",
+		"*.pb.go linguist-generated",
+		"api/*.pb.go -linguist-generated",
+		"gen/** linguist-generated=true",
+		"gen/keep.go linguist-generated=false",
+		"third_party/ linguist-vendored",
+		"third_party/ours/ !linguist-vendored",
+	}, "\n"))
+	tests := []struct {
+		name string
+		attr string
+		want AttrState
+	}{
+		{"proto/user.pb.go", "linguist-generated", AttrSet},
+		{"api/user.pb.go", "linguist-generated", AttrUnset},
+		{"gen/client.go", "linguist-generated", AttrSet},
+		{"gen/keep.go", "linguist-generated", AttrUnset},
+		{"main.go", "linguist-generated", AttrUnspecified},
+		{"third_party/lib/lib.go", "linguist-vendored", AttrSet},
+		{"third_party/ours/lib.go", "linguist-vendored", AttrUnspecified},
+		{"proto/user.pb.go", "linguist-vendored", AttrUnspecified},
+	}
+	for _, tt := range tests {
+		if got := attrs.State(tt.name, tt.attr); got != tt.want {
+			t.Errorf("State(%q, %q) = %v, want %v", tt.name, tt.attr, got, tt.want)
+		}
+	}
+}
+
+// added builds a file adding the given lines at the top.
+func added(path string, lines ...string) File {
+	hunk := Hunk{NewStart: 1, NewLines: len(lines)}
+	for i, line := range lines {
+		hunk.Lines = append(hunk.Lines, Line{Kind: LineAdded, Content: line, NewLine: i + 1})
+	}
+	return File{OldPath: path, NewPath: path, Hunks: []Hunk{hunk}}
+}
+
+func TestPreprocess(t *testing.T) {
+	files := []File{
+		added("main.go", "package main"),
+		{OldPath: "logo.png", NewPath: "logo.png", IsBinary: true},
+		added("docs/notes.txt", "notes"),
+		added("proto/user.pb.go", "package proto"),
+		added("api/user.pb.go", "package api"),
+		added("web/yarn.lock", "lock"),
+		added("static/app.js", strings.Repeat("x", minifiedLineLength+1)),
+		added("client.go", "// Code generated by tool. DO NOT EDIT.", "package client"),
+		added("kept/client.go", "// Code generated by tool. DO NOT EDIT.", "package client"),
+		{OldPath: "vendor/lib.go", NewPath: "/dev/null"},
+	}
+	opts := PreprocessOptions{
+		Ignore:       []string{"docs/"},
+		IgnoreReason: "ignored in .goodcode.yml",
+		Attributes:   ParseAttributes("*.pb.go linguist-generated\napi/*.pb.go -linguist-generated\nkept/** linguist-generated=false"),
+	}
+
+	kept, skipped := Preprocess(files, opts)
+
+	var keptPaths []string
+	for _, file := range kept {
+		keptPaths = append(keptPaths, file.Path())
+	}
+	if want := []string{"main.go", "api/user.pb.go", "kept/client.go"}; !slices.Equal(keptPaths, want) {
+		t.Errorf("kept %v, want %v", keptPaths, want)
+	}
+	want := []SkippedFile{
+		{Path: "logo.png", Reason: "binary file"},
+		{Path: "docs/notes.txt", Reason: "ignored in .goodcode.yml (docs/)"},
+		{Path: "proto/user.pb.go", Reason: "marked linguist-generated in .gitattributes"},
+		{Path: "web/yarn.lock", Reason: "ignored by default (yarn.lock)"},
+		{Path: "static/app.js", Reason: "looks minified"},
+		{Path: "client.go", Reason: "looks generated"},
+		{Path: "vendor/lib.go", Reason: "ignored by default (vendor/)"},
+	}
+	if !slices.Equal(skipped, want) {
+		t.Errorf("skipped %v, want %v", skipped, want)
+	}
+}
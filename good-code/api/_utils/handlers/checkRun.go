@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"github.com/google/go-github/v72/github"
+)
+
+const (
+	CheckRunName = "GoodCode"
+	// GitHub accepts at most 50 annotations per request
+	maxAnnotationsPerRequest = 50
+	maxCheckRunTextLength    = 65535
+)
+
+// checkRun is the check GoodCode shows in the pull request's checks box while
+// it roasts the head commit. A nil checkRun, from a failed start, ignores
+// every update so a missing checks permission never blocks the roast itself.
+type checkRun struct {
+	// ctx outlives the roast's own cancellation, so a roast cut short by the
+	// worker deadline still gets its check run finished
+	ctx       context.Context
+	client    *github.Client
+	owner     string
+	repo      string
+	id        int64
+	completed bool
+}
+
+func startCheckRun(ctx context.Context, client *github.Client, body *github.PullRequestEvent) *checkRun {
+	owner, repo := body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName()
+	run, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:      CheckRunName,
+		HeadSHA:   body.GetPullRequest().GetHead().GetSHA(),
+		Status:    github.Ptr("in_progress"),
+		StartedAt: &github.Timestamp{Time: time.Now()},
+		Output: &github.CheckRunOutput{
+			Title:   github.Ptr("Roasting in progress"),
+			Summary: github.Ptr(fmt.Sprintf("Reviewing the changes in pull request #%d.", body.GetNumber())),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create check run for PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
+		return nil
+	}
+	return &checkRun{ctx: context.WithoutCancel(ctx), client: client, owner: owner, repo: repo, id: run.GetID()}
+}
+
+func (c *checkRun) ID() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.id
+}
+
+// complete marks the check run as finished. Annotations past the first 50
+// are sent in follow-up updates, which GitHub appends to the existing ones.
+func (c *checkRun) complete(conclusion string, title string, summary string, annotations []*github.CheckRunAnnotation) error {
+	if c == nil || c.completed {
+		return nil
+	}
+	c.completed = true
+
+	output := &github.CheckRunOutput{
+		Title:   github.Ptr(title),
+		Summary: github.Ptr(truncate(summary, maxCheckRunTextLength)),
+	}
+	batch := annotations[:min(len(annotations), maxAnnotationsPerRequest)]
+	output.Annotations = batch
+	_, _, err := c.client.Checks.UpdateCheckRun(c.ctx, c.owner, c.repo, c.id, github.UpdateCheckRunOptions{
+		Name:        CheckRunName,
+		Status:      github.Ptr("completed"),
+		Conclusion:  github.Ptr(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete check run %d: %w", c.id, err)
+	}
+
+	for start := len(batch); start < len(annotations); start += maxAnnotationsPerRequest {
+		output.Annotations = annotations[start:min(len(annotations), start+maxAnnotationsPerRequest)]
+		_, _, err := c.client.Checks.UpdateCheckRun(c.ctx, c.owner, c.repo, c.id, github.UpdateCheckRunOptions{
+			Name:   CheckRunName,
+			Output: output,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add annotations to check run %d: %w", c.id, err)
+		}
+	}
+	return nil
+}
+
+// fail finishes the check run after the roast itself failed. The conclusion
+// is neutral since a broken roast says nothing about the code.
+func (c *checkRun) fail(cause error) {
+	completeCheckRun(c, roast.ConclusionNeutral, "Roast failed", fmt.Sprintf("GoodCode could not roast this commit: %v", cause), nil)
+}
+
+// completeCheckRun finishes the check run, only logging failures since the
+// roast is still posted as a comment.
+func completeCheckRun(c *checkRun, conclusion string, title string, summary string, annotations []*github.CheckRunAnnotation) {
+	if err := c.complete(conclusion, title, summary, annotations); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+func checkRunTitle(review *roast.Review) string {
+	if len(review.Findings) == 0 {
+		return "No findings"
+	}
+	counts := map[string]int{}
+	for _, f := range review.Findings {
+		counts[f.Severity]++
+	}
+	var parts []string
+	for _, severity := range roast.Severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	return fmt.Sprintf("%d finding(s): %s", len(review.Findings), strings.Join(parts, ", "))
+}
+
+// annotations turns the findings into line annotations. Only files that
+// still exist at the head commit can be annotated; GitHub rejects the whole
+// update otherwise.
+func annotations(review *roast.Review, files []diff.File) []*github.CheckRunAnnotation {
+	present := map[string]bool{}
+	for _, file := range files {
+		if !file.IsDeleted() {
+			present[file.Path()] = true
+		}
+	}
+	var out []*github.CheckRunAnnotation
+	for _, f := range review.Findings {
+		if !present[f.File] || f.Line <= 0 {
+			continue
+		}
+		annotation := &github.CheckRunAnnotation{
+			Path:            github.Ptr(f.File),
+			StartLine:       github.Ptr(f.Line),
+			EndLine:         github.Ptr(max(f.EndLine, f.Line)),
+			AnnotationLevel: github.Ptr(annotationLevel(f.Severity)),
+			Title:           github.Ptr(fmt.Sprintf("%s %s", f.Severity, f.Category)),
+			Message:         github.Ptr(f.Message),
+		}
+		if f.Suggestion != "" {
+			annotation.RawDetails = github.Ptr(f.Suggestion)
+		}
+		out = append(out, annotation)
+	}
+	return out
+}
+
+func annotationLevel(severity string) string {
+	switch {
+	case roast.AtLeast(severity, "high"):
+		return "failure"
+	case roast.AtLeast(severity, "medium"):
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit-3] + "..."
+}
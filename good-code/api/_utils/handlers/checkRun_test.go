@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"github.com/google/go-github/v72/github"
+)
+
+func TestCheckRunTitle(t *testing.T) {
+	if got := checkRunTitle(&roast.Review{}); got != "No findings" {
+		t.Errorf("checkRunTitle() = %q, want %q", got, "No findings")
+	}
+	review := &roast.Review{Findings: []roast.Finding{{Severity: "low"}, {Severity: "critical"}, {Severity: "low"}}}
+	if got, want := checkRunTitle(review), "3 finding(s): 1 critical, 2 low"; got != want {
+		t.Errorf("checkRunTitle() = %q, want %q", got, want)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	files, err := diff.Parse("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/gone.go b/gone.go\ndeleted file mode 100644\n--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := &roast.Review{Findings: []roast.Finding{
+		{File: "main.go", Line: 1, Severity: "critical", Category: "bug", Message: "Broken", Suggestion: "Fix it"},
+		{File: "main.go", Line: 3, EndLine: 5, Severity: "medium", Category: "style", Message: "Meh"},
+		{File: "main.go", Line: 9, Severity: "info", Category: "style", Message: "Nit"},
+		{File: "main.go", Line: 0, Severity: "high", Category: "bug", Message: "No line"},
+		{File: "gone.go", Line: 1, Severity: "high", Category: "bug", Message: "Deleted"},
+		{File: "other.go", Line: 1, Severity: "high", Category: "bug", Message: "Not in the diff"},
+	}}
+
+	got := annotations(review, files)
+	if len(got) != 3 {
+		t.Fatalf("annotations() returned %d, want 3", len(got))
+	}
+	want := []struct {
+		start, end int
+		level      string
+	}{{1, 1, "failure"}, {3, 5, "warning"}, {9, 9, "notice"}}
+	for i, w := range want {
+		a := got[i]
+		if a.GetStartLine() != w.start || a.GetEndLine() != w.end || a.GetAnnotationLevel() != w.level {
+			t.Errorf("annotation %d = lines %d-%d %s, want %d-%d %s", i, a.GetStartLine(), a.GetEndLine(), a.GetAnnotationLevel(), w.start, w.end, w.level)
+		}
+	}
+	if got[0].GetTitle() != "critical bug" || got[0].GetRawDetails() != "Fix it" {
+		t.Errorf("annotation = %+v, want the title and suggestion", got[0])
+	}
+}
+
+func TestCheckRunComplete(t *testing.T) {
+	var batches []int
+	var conclusions []string
+	client := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/check-runs":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": 5}`)
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/owner/repo/check-runs/5":
+			var update github.UpdateCheckRunOptions
+			json.NewDecoder(r.Body).Decode(&update)
+			batches = append(batches, len(update.Output.Annotations))
+			conclusions = append(conclusions, update.GetConclusion())
+			if len(update.Output.GetSummary()) > maxCheckRunTextLength {
+				t.Errorf("summary is %d characters long", len(update.Output.GetSummary()))
+			}
+			fmt.Fprint(w, `{"id": 5}`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	run := startCheckRun(context.Background(), client, pullRequestEvent("opened", "head"))
+	if run.ID() != 5 {
+		t.Fatalf("startCheckRun() ID = %d, want 5", run.ID())
+	}
+	annotations := make([]*github.CheckRunAnnotation, 120)
+	for i := range annotations {
+		annotations[i] = &github.CheckRunAnnotation{Path: github.Ptr("main.go"), StartLine: github.Ptr(i + 1)}
+	}
+	summary := strings.Repeat("x", maxCheckRunTextLength+10)
+	if err := run.complete(roast.ConclusionFailure, "Findings", summary, annotations); err != nil {
+		t.Fatalf("complete() error = %v", err)
+	}
+	if err := run.complete(roast.ConclusionSuccess, "Again", "Again", nil); err != nil {
+		t.Fatalf("complete() error = %v", err)
+	}
+	if fmt.Sprint(batches) != "[50 50 20]" {
+		t.Errorf("annotations sent in batches of %v, want [50 50 20]", batches)
+	}
+	if conclusions[0] != roast.ConclusionFailure || conclusions[1] != "" || conclusions[2] != "" {
+		t.Errorf("conclusions = %q, want only the first update to conclude", conclusions)
+	}
+
+	var nilRun *checkRun
+	if err := nilRun.complete(roast.ConclusionSuccess, "Nothing", "Nothing", nil); err != nil || nilRun.ID() != 0 {
+		t.Errorf("nil check run complete() = %v, ID() = %d", err, nilRun.ID())
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	config "github.com/chopstickleg/good-code/api/_utils/config"
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+
+	"github.com/google/go-github/v72/github"
+)
+
+const (
+	// Past this many files the roast goes ahead with the diff alone for the rest
+	maxContextFiles = 50
+	// The contents API refuses files over 1 MB anyway
+	maxContextFileSize = 1 << 20
+	contextFetchers    = 4
+)
+
+// fetchContents reads the changed files as they are at the given commit, so
+// the model can see the code around each hunk. Files that cannot be read are
+// left out rather than failing the roast.
+func fetchContents(ctx context.Context, client *github.Client, repo *github.Repository, ref string, files []diff.File) map[string]string {
+	var paths []string
+	for i := range files {
+		if files[i].IsBinary || files[i].IsDeleted() || len(files[i].Hunks) == 0 {
+			continue
+		}
+		if len(paths) == maxContextFiles {
+			log.Printf("Only fetching the first %d changed files of %s@%s for context", maxContextFiles, repo.GetFullName(), ref)
+			break
+		}
+		paths = append(paths, files[i].Path())
+	}
+
+	contents := make(map[string]string, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, contextFetchers)
+	for _, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			content, found, err := config.FetchFile(ctx, client, repo.GetOwner().GetLogin(), repo.GetName(), path, ref)
+			if err != nil {
+				log.Printf("Failed to fetch %s@%s for context: %v", path, ref, err)
+				return
+			}
+			if !found || len(content) > maxContextFileSize {
+				return
+			}
+			mu.Lock()
+			contents[path] = string(content)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return contents
+}
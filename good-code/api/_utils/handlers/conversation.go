@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	config "github.com/chopstickleg/good-code/api/_utils/config"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+	usage "github.com/chopstickleg/good-code/api/_utils/usage"
+
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+const defaultAppSlug = "good-code-pr-monitoring"
+
+// newEngine sets up the model the way roasts of the pull request are set up:
+// repository and .goodcode.yml overrides, language and persona.
+func newEngine(ctx context.Context, conn *gorm.DB, client *github.Client, ghRepo *github.Repository, baseRef string, pullRequest db.PullRequest) (*roast.Engine, error) {
+	repoConfig, err := config.Fetch(ctx, client, ghRepo.GetOwner().GetLogin(), ghRepo.GetName(), baseRef)
+	if err != nil {
+		log.Printf("Failed to fetch %s for %s, using defaults: %v", config.FileName, ghRepo.GetFullName(), err)
+		repoConfig = &config.Resolved{Config: config.Default(), Source: "default"}
+	}
+	cfg := repoConfig.Config
+
+	var repo db.Repository
+	err = conn.Where(&db.Repository{ID: ghRepo.GetID()}).First(&repo).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to load repository: %w", err)
+	}
+	provider, err := llm.NewProvider(llm.ConfigFromEnv().
+		WithOverrides(repo.AiProvider, repo.AiModel).
+		WithOverrides(cfg.Provider, cfg.Model))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create AI provider: %w", err)
+	}
+
+	engine := &roast.Engine{Provider: provider, Instructions: cfg.Instructions()}
+	selected, err := persona.Resolve(conn, repo, pullRequest, cfg.Tone)
+	if err != nil {
+		log.Printf("Failed to resolve persona for PR #%d, using the default instructions: %v", pullRequest.Number, err)
+	} else {
+		engine.Instructions.Persona = selected.Instructions
+	}
+	return engine, nil
+}
+
+// reserveCall checks the installation still has tokens left for a model call
+// that is not a roast, returning quota.ErrExhausted when it has not.
+func reserveCall(ctx context.Context, conn *gorm.DB, installation *github.Installation, purpose string) (*quota.Reservation, error) {
+	_, limits, err := quota.LimitsFor(conn, installation.GetID(), installation.GetAccount().GetLogin())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota: %w", err)
+	}
+	return quota.ReserveCall(conn, installation.GetID(), limits, reservationKey(ctx, purpose))
+}
+
+// mention matches @goodcode or the GitHub App's own handle as a whole word.
+var mention = regexp.MustCompile(`(?i)(^|[^\w-])@(goodcode|` + regexp.QuoteMeta(appSlug()) + `)(\[bot\])?($|[^\w-])`)
+
+func appSlug() string {
+	if slug := os.Getenv("GITHUB_APP_SLUG"); slug != "" {
+		return slug
+	}
+	return defaultAppSlug
+}
+
+// mentionsGoodCode reports whether the comment addresses GoodCode by name,
+// either as @goodcode or by the GitHub App's own handle.
+func mentionsGoodCode(body string) bool {
+	return mention.MatchString(body)
+}
+
+// conversants are the author associations GoodCode answers besides the pull
+// request's own author.
+var conversants = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// mayConverse reports whether GoodCode answers the author of a comment: people
+// with a stake in the repository and the author of the pull request, never
+// bots, so not just anyone who can comment on a public pull request can run up
+// model calls.
+func mayConverse(association string, author *github.User, pullRequestAuthor *github.User) bool {
+	if author.GetType() == "Bot" {
+		return false
+	}
+	if slices.Contains(conversants, association) {
+		return true
+	}
+	return author.GetID() != 0 && author.GetID() == pullRequestAuthor.GetID()
+}
+
+// quotes returns the lines the comment quotes that are long enough to tell
+// what they quote.
+func quotes(body string) []string {
+	var quoted []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, ">") {
+			continue
+		}
+		if text := strings.TrimSpace(strings.TrimLeft(line, "> ")); len(text) >= 10 {
+			quoted = append(quoted, text)
+		}
+	}
+	return quoted
+}
+
+// quotesAny reports whether the comment quotes one of the given texts, which
+// is how GitHub's "Quote reply" answers a conversation comment.
+func quotesAny(body string, texts []string) bool {
+	for _, quoted := range quotes(body) {
+		for _, text := range texts {
+			if strings.Contains(text, quoted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toTurns(history []db.ConversationTurn) []roast.Turn {
+	turns := make([]roast.Turn, 0, len(history))
+	for _, turn := range history {
+		turns = append(turns, roast.Turn{Role: turn.Role, Author: turn.AuthorLogin, Body: turn.Body})
+	}
+	return turns
+}
+
+func saveTurns(conn *gorm.DB, turns ...db.ConversationTurn) {
+	if err := conn.Create(&turns).Error; err != nil {
+		log.Printf("Failed to save conversation turns: %v", err)
+	}
+}
+
+// answerPullRequestComment answers a conversation comment on the pull
+// request that mentions GoodCode or quotes one of its comments, using the
+// latest roast and the earlier conversation as context.
+func answerPullRequestComment(ctx context.Context, conn *gorm.DB, body *github.IssueCommentEvent) error {
+	repoID, number := body.GetRepo().GetID(), body.GetIssue().GetNumber()
+	if !mayConverse(body.GetComment().GetAuthorAssociation(), body.GetComment().GetUser(), body.GetIssue().GetUser()) {
+		log.Printf("Not answering %s (%s) on PR #%d in %s", body.GetComment().GetUser().GetLogin(), body.GetComment().GetAuthorAssociation(), number, body.GetRepo().GetFullName())
+		return nil
+	}
+
+	var latest db.AiRoast
+	err := conn.
+		Omit("Repository").
+		Where(&db.AiRoast{RepoID: repoID, PullRequestNumber: number}).
+		Order("created_at DESC").
+		First(&latest).
+		Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load latest roast of PR #%d in %s: %w", number, body.GetRepo().GetFullName(), err)
+	}
+
+	var history []db.ConversationTurn
+	err = conn.
+		Where(&db.ConversationTurn{RepoID: repoID, PullRequestNumber: number}).
+		Where("thread_id = 0").
+		Order("created_at ASC").
+		Find(&history).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to load conversation on PR #%d in %s: %w", number, body.GetRepo().GetFullName(), err)
+	}
+
+	said := []string{latest.Content}
+	for _, turn := range history {
+		if turn.Role == roast.RoleAssistant {
+			said = append(said, turn.Body)
+		}
+	}
+	question := body.GetComment().GetBody()
+	if !mentionsGoodCode(question) && !quotesAny(question, said) {
+		return nil
+	}
+	log.Printf("Answering %s on PR #%d in %s", body.GetSender().GetLogin(), number, body.GetRepo().GetFullName())
+
+	client, err := getAuthedClient(body.GetInstallation().GetID())
+	if err != nil {
+		return fmt.Errorf("failed to get authenticated GitHub client: %w", err)
+	}
+	event, err := loadPullRequestEvent(ctx, client, body.GetRepo(), body.GetInstallation(), body.GetSender(), number, "rerequested")
+	if err != nil {
+		return fmt.Errorf("failed to load PR #%d in %s: %w", number, body.GetRepo().GetFullName(), err)
+	}
+	pullRequest, err := upsertPullRequest(conn, event)
+	if err != nil {
+		return fmt.Errorf("failed to record PR #%d in %s: %w", number, body.GetRepo().GetFullName(), err)
+	}
+	engine, err := newEngine(ctx, conn, client, body.GetRepo(), event.GetPullRequest().GetBase().GetRef(), *pullRequest)
+	if err != nil {
+		return fmt.Errorf("failed to set up AI provider for PR #%d: %w", number, err)
+	}
+
+	userTurn := db.ConversationTurn{
+		RoastID:           latest.ID,
+		RepoID:            repoID,
+		PullRequestNumber: number,
+		GithubCommentID:   body.GetComment().GetID(),
+		Role:              roast.RoleUser,
+		AuthorLogin:       body.GetSender().GetLogin(),
+		Body:              question,
+	}
+	reservation, err := reserveCall(ctx, conn, body.GetInstallation(), fmt.Sprintf("answer:%d", body.GetComment().GetID()))
+	if errors.Is(err, quota.ErrExhausted) {
+		log.Printf("Installation %d is out of quota, not answering comment %d", body.GetInstallation().GetID(), body.GetComment().GetID())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := engine.Answer(ctx, roast.FollowUp{
+		Roast:    latest.Content,
+		History:  toTurns(history),
+		Question: roast.Turn{Role: roast.RoleUser, Author: userTurn.AuthorLogin, Body: question},
+	})
+	if resp != nil {
+		reservation.Record(conn, resp.Usage.TotalTokens)
+		usage.RecordCall(conn, db.ModelCall{
+			Kind:              db.ModelCallAnswer,
+			RepoID:            repoID,
+			PullRequestNumber: number,
+			InstallationID:    body.GetInstallation().GetID(),
+			Provider:          engine.Provider.Name(),
+		}, resp)
+	}
+	if err != nil {
+		reservation.Release(conn)
+		return fmt.Errorf("failed to answer comment %d on PR #%d: %w", body.GetComment().GetID(), number, err)
+	}
+	answer := resp.Text
+
+	posted, _, err := client.Issues.CreateComment(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), number, &github.IssueComment{
+		Body: github.Ptr(fmt.Sprintf("@%s %s", userTurn.AuthorLogin, answer)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to post answer on PR #%d: %w", number, err)
+	}
+	saveTurns(conn, userTurn, db.ConversationTurn{
+		RoastID:           latest.ID,
+		RepoID:            repoID,
+		PullRequestNumber: number,
+		GithubCommentID:   posted.GetID(),
+		Role:              roast.RoleAssistant,
+		Body:              answer,
+		Model:             resp.Model,
+	})
+	return nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-github/v72/github"
+)
+
+func TestQuotes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"quote reply", "> This function leaks a goroutine\n\nWhy?", []string{"This function leaks a goroutine"}},
+		{"nested and indented", "  >> This function leaks a goroutine", []string{"This function leaks a goroutine"}},
+		{"too short to match", "> yes\nsure", nil},
+		{"arrow in prose", "a -> b is faster than b -> a", nil},
+		{"comparison", "Only when len(x) > 10", nil},
+		{"generic type", "Use map[string]List<T>", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotes(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("quotes(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotesAny(t *testing.T) {
+	replies := []string{"Your error handling is a cry for help. Wrap the error."}
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"> Your error handling is a cry for help.\n\nIt's fine.", true},
+		{"> Wrap the error.\nDone", true},
+		{"> Something else entirely was said here", false},
+		{"Your error handling is a cry for help.", false},
+		{"> cry", false},
+	}
+	for _, tt := range tests {
+		if got := quotesAny(tt.body, replies); got != tt.want {
+			t.Errorf("quotesAny(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMentionsGoodCode(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"@goodcode what do you think?", true},
+		{"Thoughts, @GoodCode?", true},
+		{"cc @" + defaultAppSlug, true},
+		{"cc @" + defaultAppSlug + "[bot]", true},
+		{"@goodcoder please review", false},
+		{"mail me at someone@goodcode.dev", false},
+		{"goodcode is great", false},
+	}
+	for _, tt := range tests {
+		if got := mentionsGoodCode(tt.body); got != tt.want {
+			t.Errorf("mentionsGoodCode(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMayConverse(t *testing.T) {
+	author := &github.User{ID: github.Ptr(int64(1)), Type: github.Ptr("User")}
+	other := &github.User{ID: github.Ptr(int64(2)), Type: github.Ptr("User")}
+	bot := &github.User{ID: github.Ptr(int64(3)), Type: github.Ptr("Bot")}
+	tests := []struct {
+		name        string
+		association string
+		user        *github.User
+		want        bool
+	}{
+		{"pull request author", "CONTRIBUTOR", author, true},
+		{"member", "MEMBER", other, true},
+		{"collaborator", "COLLABORATOR", other, true},
+		{"drive-by commenter", "NONE", other, false},
+		{"bot member", "MEMBER", bot, false},
+		{"no user", "NONE", &github.User{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pullRequestAuthor := author
+			if tt.name == "no user" {
+				pullRequestAuthor = &github.User{}
+			}
+			if got := mayConverse(tt.association, tt.user, pullRequestAuthor); got != tt.want {
+				t.Errorf("mayConverse(%q) = %v, want %v", tt.association, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+// testDB is an in-memory database with the tables of the given models.
+func testDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// testClient is a GitHub client talking to the handler instead of GitHub.
+func testClient(t *testing.T, handler http.Handler) *github.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deliveryStaleAfter is how long a delivery may stay received before it is
+// taken to have been abandoned by a function that timed out or crashed. It is
+// well past the longest a function is allowed to run.
+const deliveryStaleAfter = 10 * time.Minute
+
+// RecordDelivery stores a webhook delivery before it is processed. It reports
+// a duplicate when GitHub redelivers one we already handled or are handling;
+// redeliveries of failed or abandoned ones are handed back to be processed
+// again.
+func RecordDelivery(conn *gorm.DB, deliveryID, eventType string, payload []byte) (*db.WebhookDelivery, bool, error) {
+	var meta struct {
+		Action       string `json:"action"`
+		Installation struct {
+			ID int64 `json:"id"`
+		} `json:"installation"`
+	}
+	if err := json.Unmarshal(payload, &meta); err != nil {
+		return nil, false, fmt.Errorf("failed to decode delivery %s: %w", deliveryID, err)
+	}
+
+	delivery := db.WebhookDelivery{
+		DeliveryID:     deliveryID,
+		Event:          eventType,
+		Action:         meta.Action,
+		InstallationID: meta.Installation.ID,
+		Payload:        db.JSON(payload),
+		Status:         db.DeliveryReceived,
+	}
+	result := conn.
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "delivery_id"}}, DoNothing: true}).
+		Create(&delivery)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to record delivery %s: %w", deliveryID, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &delivery, false, nil
+	}
+
+	var existing db.WebhookDelivery
+	if err := conn.Where(&db.WebhookDelivery{DeliveryID: deliveryID}).First(&existing).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to load delivery %s: %w", deliveryID, err)
+	}
+	// Only one of several concurrent redeliveries of a failed or abandoned
+	// delivery gets to process it again
+	retried := conn.Model(&db.WebhookDelivery{}).
+		Where("id = ?", existing.ID).
+		Where(
+			conn.Where("status = ?", db.DeliveryFailed).
+				Or("status = ? AND updated_at < ?", db.DeliveryReceived, time.Now().Add(-deliveryStaleAfter)),
+		).
+		Update("status", db.DeliveryReceived)
+	if retried.Error != nil {
+		return nil, false, fmt.Errorf("failed to reset delivery %s: %w", deliveryID, retried.Error)
+	}
+	if retried.RowsAffected > 0 {
+		existing.Status = db.DeliveryReceived
+		return &existing, false, nil
+	}
+
+	err := conn.Model(&db.WebhookDelivery{}).
+		Where(&db.WebhookDelivery{ID: existing.ID}).
+		UpdateColumn("duplicates", gorm.Expr("duplicates + 1")).
+		Error
+	if err != nil {
+		log.Printf("Failed to count duplicate of delivery %s: %v", deliveryID, err)
+	}
+	return &existing, true, nil
+}
+
+// ProcessDelivery dispatches a stored delivery and records how it went,
+// returning the response the dispatcher produced.
+func ProcessDelivery(conn *gorm.DB, delivery *db.WebhookDelivery) (int, []byte) {
+	recorder := &responseRecorder{header: http.Header{}}
+	Dispatch(recorder, delivery.Event, delivery.Payload)
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"status":        db.DeliveryProcessed,
+		"response_code": recorder.status,
+		"error":         "",
+		"attempts":      gorm.Expr("attempts + 1"),
+		"processed_at":  now,
+	}
+	if recorder.status >= http.StatusBadRequest {
+		updates["status"] = db.DeliveryFailed
+		updates["error"] = strings.TrimSpace(recorder.body.String())
+	}
+	err := conn.Model(&db.WebhookDelivery{}).
+		Where(&db.WebhookDelivery{ID: delivery.ID}).
+		Updates(updates).
+		Error
+	if err != nil {
+		log.Printf("Failed to record outcome of delivery %s: %v", delivery.DeliveryID, err)
+	}
+	return recorder.status, recorder.body.Bytes()
+}
+
+// responseRecorder captures what a handler writes so the outcome can be stored
+// with the delivery.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
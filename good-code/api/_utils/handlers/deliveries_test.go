@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+)
+
+func TestRecordDelivery(t *testing.T) {
+	conn := testDB(t, &db.WebhookDelivery{})
+	payload := []byte(`{"action": "opened", "installation": {"id": 9}}`)
+
+	delivery, duplicate, err := RecordDelivery(conn, "delivery", "pull_request", payload)
+	if err != nil || duplicate {
+		t.Fatalf("RecordDelivery() = %v, %v, want a new delivery", duplicate, err)
+	}
+	if delivery.Action != "opened" || delivery.InstallationID != 9 || delivery.Status != db.DeliveryReceived {
+		t.Errorf("RecordDelivery() = %+v", delivery)
+	}
+
+	setStatus := func(status string, updatedAt time.Time) {
+		t.Helper()
+		err := conn.Model(&db.WebhookDelivery{}).Where("id = ?", delivery.ID).
+			UpdateColumns(map[string]any{"status": status, "updated_at": updatedAt}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		status    string
+		updatedAt time.Time
+		duplicate bool
+	}{
+		{"being processed", db.DeliveryReceived, time.Now(), true},
+		{"processed", db.DeliveryProcessed, time.Now(), true},
+		{"failed", db.DeliveryFailed, time.Now(), false},
+		{"abandoned", db.DeliveryReceived, time.Now().Add(-2 * deliveryStaleAfter), false},
+	}
+	duplicates := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setStatus(tt.status, tt.updatedAt)
+			redelivery, duplicate, err := RecordDelivery(conn, "delivery", "pull_request", payload)
+			if err != nil {
+				t.Fatalf("RecordDelivery() error = %v", err)
+			}
+			if duplicate != tt.duplicate || redelivery.ID != delivery.ID {
+				t.Errorf("RecordDelivery() = delivery %d, duplicate %v, want delivery %d, duplicate %v", redelivery.ID, duplicate, delivery.ID, tt.duplicate)
+			}
+			if tt.duplicate {
+				duplicates++
+			} else if redelivery.Status != db.DeliveryReceived {
+				t.Errorf("redelivery status = %q, want it received again", redelivery.Status)
+			}
+			var saved db.WebhookDelivery
+			conn.First(&saved, delivery.ID)
+			if saved.Duplicates != duplicates {
+				t.Errorf("Duplicates = %d, want %d", saved.Duplicates, duplicates)
+			}
+		})
+	}
+
+	if _, _, err := RecordDelivery(conn, "other", "pull_request", []byte("not json")); err == nil {
+		t.Error("RecordDelivery() accepted a payload that is not JSON")
+	}
+}
+
+func TestProcessDelivery(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  string
+		code   int
+		status string
+		error  string
+	}{
+		{"handled", "issue_comment", http.StatusOK, db.DeliveryProcessed, ""},
+		{"unsupported", "ping", http.StatusBadRequest, db.DeliveryFailed, "Unsupported event type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t, &db.WebhookDelivery{})
+			delivery, _, err := RecordDelivery(conn, "delivery", tt.event, []byte(`{"action": "edited"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _ := ProcessDelivery(conn, delivery)
+			if code != tt.code {
+				t.Errorf("ProcessDelivery() = %d, want %d", code, tt.code)
+			}
+			var saved db.WebhookDelivery
+			conn.First(&saved, delivery.ID)
+			if saved.Status != tt.status || saved.ResponseCode != tt.code || saved.Error != tt.error || saved.Attempts != 1 || saved.ProcessedAt == nil {
+				t.Errorf("saved delivery = %+v, want status %q, code %d, error %q after one attempt", saved, tt.status, tt.code, tt.error)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/go-github/v72/github"
+)
+
+// Dispatch hands a webhook payload to the handler for its event type. It is
+// shared by the webhook endpoint and replays of stored deliveries.
+func Dispatch(w http.ResponseWriter, eventType string, payload []byte) {
+	eventBody, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		log.Printf("Failed to parse GitHub event %s: %v", eventType, err)
+		http.Error(w, "Unable to parse GitHub event", http.StatusBadRequest)
+		return
+	}
+	switch eventType {
+	case "pull_request":
+		if prEvent, ok := eventBody.(*github.PullRequestEvent); ok {
+			log.Printf("Processing pull request event for PR #%d", prEvent.GetNumber())
+			HandlePullRequestEvent(w, *prEvent)
+		} else {
+			log.Printf("Failed to cast pull request event")
+			http.Error(w, "Invalid pull request event", http.StatusBadRequest)
+			return
+		}
+	case "check_run":
+		if checkRunEvent, ok := eventBody.(*github.CheckRunEvent); ok {
+			log.Printf("Processing check run event: %s for check run %d", checkRunEvent.GetAction(), checkRunEvent.GetCheckRun().GetID())
+			HandleCheckRunEvent(w, *checkRunEvent)
+		} else {
+			log.Printf("Failed to cast check run event")
+			http.Error(w, "Invalid check run event", http.StatusBadRequest)
+			return
+		}
+	case "pull_request_review_comment":
+		if reviewCommentEvent, ok := eventBody.(*github.PullRequestReviewCommentEvent); ok {
+			log.Printf("Processing review comment event: %s on PR #%d", reviewCommentEvent.GetAction(), reviewCommentEvent.GetPullRequest().GetNumber())
+			HandlePullRequestReviewCommentEvent(w, *reviewCommentEvent)
+		} else {
+			log.Printf("Failed to cast review comment event")
+			http.Error(w, "Invalid review comment event", http.StatusBadRequest)
+			return
+		}
+	case "issue_comment":
+		if commentEvent, ok := eventBody.(*github.IssueCommentEvent); ok {
+			log.Printf("Processing issue comment event: %s on #%d", commentEvent.GetAction(), commentEvent.GetIssue().GetNumber())
+			HandleIssueCommentEvent(w, *commentEvent)
+		} else {
+			log.Printf("Failed to cast issue comment event")
+			http.Error(w, "Invalid issue comment event", http.StatusBadRequest)
+			return
+		}
+	case "installation":
+		if installEvent, ok := eventBody.(*github.InstallationEvent); ok {
+			log.Printf("Processing installation event: %s", installEvent.GetAction())
+			HandleInstallationEvent(w, *installEvent)
+		} else {
+			log.Printf("Failed to cast installation event")
+			http.Error(w, "Invalid installation event", http.StatusBadRequest)
+			return
+		}
+	case "installation_target":
+		if itEvent, ok := eventBody.(*github.InstallationTargetEvent); ok {
+			log.Printf("Processing installation target event")
+			HandleInstallationTargetEvent(w, *itEvent)
+		} else {
+			log.Printf("Failed to cast installation target event")
+			http.Error(w, "Invalid installation target event", http.StatusBadRequest)
+			return
+		}
+	case "repository":
+		if repoEvent, ok := eventBody.(*github.RepositoryEvent); ok {
+			log.Printf("Processing repository event: %s", repoEvent.GetAction())
+			HandleRepositoryEvent(w, *repoEvent)
+		} else {
+			log.Printf("Failed to cast repository event")
+			http.Error(w, "Invalid repository event", http.StatusBadRequest)
+			return
+		}
+	case "member":
+		if memberEvent, ok := eventBody.(*github.MemberEvent); ok {
+			log.Printf("Processing member event: %s for user %s", memberEvent.GetAction(), memberEvent.GetMember().GetLogin())
+			HandleMemberEvent(w, *memberEvent)
+		} else {
+			log.Printf("Failed to cast member event")
+			http.Error(w, "Invalid member event", http.StatusBadRequest)
+			return
+		}
+	case "installation_repositories":
+		if installRepoEvent, ok := eventBody.(*github.InstallationRepositoriesEvent); ok {
+			log.Printf("Processing installation repositories event: %s", installRepoEvent.GetAction())
+			HandleRepositoryInstallationEvent(w, *installRepoEvent)
+		} else {
+			log.Printf("Failed to cast installation repositories event")
+			http.Error(w, "Invalid installation repositories event", http.StatusBadRequest)
+			return
+		}
+	default:
+		log.Printf("Unsupported event type: %s", eventType)
+		http.Error(w, "Unsupported event type", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Successfully processed %s event", eventType)
+	w.Write([]byte("Event processed successfully"))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+// HandleCheckRunEvent queues a fresh roast of the pull requests of a GoodCode
+// check run when someone asks GitHub to re-run it.
+func HandleCheckRunEvent(w http.ResponseWriter, body github.CheckRunEvent) {
+	checkRun := body.GetCheckRun()
+	if body.GetAction() != "rerequested" || checkRun.GetName() != CheckRunName {
+		log.Printf("Ignoring check run event: %s for %q", body.GetAction(), checkRun.GetName())
+		return
+	}
+	if len(checkRun.PullRequests) == 0 {
+		log.Printf("Check run %d in %s is not attached to any pull request", checkRun.GetID(), body.GetRepo().GetFullName())
+		return
+	}
+
+	conn, err := db.GetDB()
+	if err != nil {
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		log.Printf("Failed to connect to database: %v", err)
+		return
+	}
+	enqueue(w, conn, JobRerunCheck, body)
+}
+
+func rerunCheck(ctx context.Context, conn *gorm.DB, body *github.CheckRunEvent) error {
+	checkRun := body.GetCheckRun()
+	client, err := getAuthedClient(body.GetInstallation().GetID())
+	if err != nil {
+		return fmt.Errorf("failed to get authenticated GitHub client: %w", err)
+	}
+
+	for _, ref := range checkRun.PullRequests {
+		event, err := loadPullRequestEvent(ctx, client, body.GetRepo(), body.GetInstallation(), body.GetSender(), ref.GetNumber(), "rerequested")
+		if err != nil {
+			return err
+		}
+		if event.GetPullRequest().GetHead().GetSHA() != checkRun.GetHeadSHA() {
+			log.Printf("PR #%d moved on from %s, not re-running its check", ref.GetNumber(), checkRun.GetHeadSHA())
+			continue
+		}
+
+		pullRequest, err := upsertPullRequest(conn, event)
+		if err != nil {
+			return err
+		}
+		log.Printf("Re-running roast of PR #%d in %s", ref.GetNumber(), body.GetRepo().GetFullName())
+		skipped, err := roastPullRequest(ctx, conn, event, pullRequest)
+		if err != nil {
+			return fmt.Errorf("failed to roast PR #%d: %w", ref.GetNumber(), err)
+		}
+		if skipped != "" {
+			log.Printf("Did not re-run roast of PR #%d: %s", ref.GetNumber(), skipped)
+		}
+	}
+	return nil
+}
@@ -26,7 +26,7 @@ func HandleInstallationEvent(w http.ResponseWriter, body github.InstallationEven
 
 	switch action {
 	case "deleted":
-		if err := handleAppUninstalled(conn, repositories); err != nil {
+		if err := handleAppUninstalled(conn, installation.GetID(), repositories); err != nil {
 			log.Printf("Error handling app uninstallation: %v", err)
 			http.Error(w, "Failed to process app uninstallation", http.StatusInternalServerError)
 			return
@@ -44,18 +44,15 @@ func HandleInstallationEvent(w http.ResponseWriter, body github.InstallationEven
 			return
 		}
 	case "new_permissions_accepted":
-		if err := HandleAppCreated(conn, installation, repositories); err != nil {
-			log.Printf("Error handling app creation: %v", err)
-			http.Error(w, "Failed to process app creation", http.StatusInternalServerError)
-			return
-		}
+		// Listing collaborators of every repository can take a while
+		enqueue(w, conn, JobSyncInstallation, body)
 	default:
 		// Yes I am ignoring create events
 		log.Printf("Unhandled installation action: %s", action)
 	}
 }
 
-func handleAppUninstalled(conn *gorm.DB, installation []*github.Repository) error {
+func handleAppUninstalled(conn *gorm.DB, installationID int64, installation []*github.Repository) error {
 	for _, repo := range installation {
 		if err := conn.Model(&db.UserRepositoryCollaborator{}).
 			Where(&db.UserRepositoryCollaborator{RepositoryID: repo.GetID()}).
@@ -70,12 +67,12 @@ func handleAppUninstalled(conn *gorm.DB, installation []*github.Repository) erro
 			log.Printf("failed to fetch repository IDs for installation %d: %v", repo.GetID(), err)
 			return err
 		}
-		if err := conn.Model(&db.UserLogin{}).
-			Where(&db.UserLogin{GithubID: repo.GetOwner().GetID()}).
-			Updates(&db.UserLogin{InstallationID: 0}).
-			Error; err != nil {
-			log.Printf("failed to update user login for repo %d: %v", repo.GetID(), err)
-		}
+	}
+	if err := conn.Model(&db.UserLogin{}).
+		Where("installation_id = ?", installationID).
+		Update("installation_id", 0).
+		Error; err != nil {
+		log.Printf("failed to update user logins of installation %d: %v", installationID, err)
 	}
 	return nil
 }
@@ -105,7 +102,7 @@ func handleAppUnsuspended(conn *gorm.DB, installation []*github.Repository) erro
 	}
 	return nil
 }
-func HandleAppCreated(conn *gorm.DB, installation *github.Installation, repos []*github.Repository) error {
+func HandleAppCreated(ctx context.Context, conn *gorm.DB, installation *github.Installation, repos []*github.Repository) error {
 	for _, repo := range repos {
 		var count int64
 		err := conn.Model(&db.Repository{}).
@@ -116,17 +113,29 @@ func HandleAppCreated(conn *gorm.DB, installation *github.Installation, repos []
 			log.Printf("Failed to check repository %s: %v", repo.GetFullName(), err)
 			return err
 		}
-		login, ownerID, collaborators, err := getRepoInfo(repo.GetID(), installation.GetID())
+		login, ownerID, collaborators, err := getRepoInfo(ctx, repo.GetID(), installation.GetID())
 		if err != nil {
 			log.Printf("Failed to get owner info for repo %s: %v", repo.GetFullName(), err)
 			return err
 		}
+		if count > 0 {
+			// Reinstalling the app gives it a new installation ID
+			err = conn.Model(&db.Repository{}).
+				Where(&db.Repository{ID: repo.GetID()}).
+				Update("installation_id", installation.GetID()).
+				Error
+			if err != nil {
+				log.Printf("Failed to update installation of repository %s: %v", repo.GetFullName(), err)
+				return err
+			}
+		}
 		if count == 0 {
 			newRepo := db.Repository{
-				ID:      repo.GetID(),
-				Name:    repo.GetName(),
-				Owner:   login,
-				OwnerID: ownerID,
+				ID:             repo.GetID(),
+				Name:           repo.GetName(),
+				Owner:          login,
+				OwnerID:        ownerID,
+				InstallationID: installation.GetID(),
 			}
 			if err := conn.Create(&newRepo).Error; err != nil {
 				log.Printf("Failed to create repository record for %s: %v", repo.GetFullName(), err)
@@ -135,7 +144,7 @@ func HandleAppCreated(conn *gorm.DB, installation *github.Installation, repos []
 			for _, collaborator := range collaborators {
 				var userLoginID *int64
 				var userLogin db.UserLogin
-				err = conn.Where(&db.UserLogin{GithubID: collaborator.GetID()}).First(&userLogin).Error
+				err = conn.Where(&db.UserLogin{GithubID: github.Ptr(collaborator.GetID())}).First(&userLogin).Error
 				if gorm.ErrRecordNotFound == err {
 					log.Printf("User login not found for collaborator %s in repo %s", collaborator.GetLogin(), repo.GetFullName())
 					userLoginID = nil
@@ -164,7 +173,7 @@ func HandleAppCreated(conn *gorm.DB, installation *github.Installation, repos []
 	return nil
 }
 
-func getRepoInfo(repoId int64, installationID int64) (string, int64, []*github.User, error) {
+func getRepoInfo(ctx context.Context, repoId int64, installationID int64) (string, int64, []*github.User, error) {
 	log.Printf("Using installation ID: %d", installationID)
 
 	installationToken, err := utils.GetGitHubInstallationToken(installationID)
@@ -175,13 +184,13 @@ func getRepoInfo(repoId int64, installationID int64) (string, int64, []*github.U
 
 	GHclient := github.NewClient(nil)
 	authedGHClient := GHclient.WithAuthToken(installationToken)
-	repo, _, err := authedGHClient.Repositories.GetByID(context.Background(), repoId)
+	repo, _, err := authedGHClient.Repositories.GetByID(ctx, repoId)
 	if err != nil {
 		log.Printf("Failed to get repo info for repo ID %d: %v", repoId, err)
 		return "", 0, nil, err
 	}
 
-	collaborators, _, err := authedGHClient.Repositories.ListCollaborators(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), nil)
+	collaborators, _, err := authedGHClient.Repositories.ListCollaborators(ctx, repo.GetOwner().GetLogin(), repo.GetName(), nil)
 	if err != nil {
 		log.Printf("Failed to list collaborators for repo %s: %v", repo.GetFullName(), err)
 		return "", 0, nil, err
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	command "github.com/chopstickleg/good-code/api/_utils/command"
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+	usage "github.com/chopstickleg/good-code/api/_utils/usage"
+
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+// HandleIssueCommentEvent queues new pull request comments that talk to
+// GoodCode: /goodcode commands, mentions and quote replies.
+func HandleIssueCommentEvent(w http.ResponseWriter, body github.IssueCommentEvent) {
+	if body.GetAction() != "created" || !body.GetIssue().IsPullRequest() {
+		log.Printf("Ignoring issue comment event: %s", body.GetAction())
+		return
+	}
+	// Never respond to bots, which includes GoodCode's own replies
+	if body.GetSender().GetType() == "Bot" {
+		return
+	}
+	comment := body.GetComment().GetBody()
+	if _, ok := command.Parse(comment); !ok && !mentionsGoodCode(comment) && len(quotes(comment)) == 0 {
+		return
+	}
+
+	conn, err := db.GetDB()
+	if err != nil {
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		log.Printf("Failed to connect to database: %v", err)
+		return
+	}
+	enqueue(w, conn, JobIssueComment, body)
+}
+
+// handleIssueComment runs the /goodcode command in the comment, if there is
+// one, and replies with the outcome. Other comments that talk to GoodCode get
+// an answer from the model.
+func handleIssueComment(ctx context.Context, conn *gorm.DB, body *github.IssueCommentEvent) error {
+	cmd, ok := command.Parse(body.GetComment().GetBody())
+	if !ok {
+		return answerPullRequestComment(ctx, conn, body)
+	}
+	log.Printf("Running command %q on PR #%d in %s from %s", cmd.Name, body.GetIssue().GetNumber(), body.GetRepo().GetFullName(), body.GetSender().GetLogin())
+
+	client, err := getAuthedClient(body.GetInstallation().GetID())
+	if err != nil {
+		return fmt.Errorf("failed to get authenticated GitHub client: %w", err)
+	}
+	react(ctx, client, body, "eyes")
+
+	spec, known := command.Lookup(cmd.Name)
+	if !known {
+		react(ctx, client, body, "confused")
+		reply(ctx, client, body, fmt.Sprintf("I don't know the `%s` command.\n\n%s", cmd.Name, command.HelpText()))
+		return nil
+	}
+	if spec.Role != "" {
+		allowed, err := repository.HasRole(body.GetRepo().GetID(), body.GetSender().GetID(), spec.Role, conn)
+		if err != nil {
+			return fmt.Errorf("failed to check role of %s: %w", body.GetSender().GetLogin(), err)
+		}
+		if !allowed {
+			react(ctx, client, body, "-1")
+			reply(ctx, client, body, fmt.Sprintf("Sorry @%s, you need at least %s access to this repository to use `%s %s`.", body.GetSender().GetLogin(), spec.Role, command.Prefix, spec.Name))
+			return nil
+		}
+	}
+
+	message, err := runCommand(ctx, conn, client, body, cmd)
+	if err != nil {
+		react(ctx, client, body, "confused")
+		return fmt.Errorf("failed to run command %q: %w", cmd.Name, err)
+	}
+	react(ctx, client, body, "+1")
+	reply(ctx, client, body, message)
+	return nil
+}
+
+func runCommand(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.IssueCommentEvent, cmd *command.Command) (string, error) {
+	if cmd.Name == command.Help {
+		return command.HelpText(), nil
+	}
+
+	event, err := loadPullRequestEvent(ctx, client, body.GetRepo(), body.GetInstallation(), body.GetSender(), body.GetIssue().GetNumber(), "rerequested")
+	if err != nil {
+		return "", err
+	}
+	pullRequest, err := upsertPullRequest(conn, event)
+	if err != nil {
+		return "", err
+	}
+
+	switch cmd.Name {
+	case command.Roast:
+		if pullRequest.Ignored {
+			if err := updatePullRequest(conn, pullRequest, map[string]any{"ignored": false}); err != nil {
+				return "", err
+			}
+			pullRequest.Ignored = false
+		}
+		skipped, err := roastPullRequest(ctx, conn, event, pullRequest)
+		if err != nil {
+			return "", err
+		}
+		if skipped != "" {
+			return fmt.Sprintf("No roast this time: %s.", skipped), nil
+		}
+		return "Fresh roast served.", nil
+
+	case command.Explain:
+		if len(cmd.Args) == 0 {
+			return fmt.Sprintf("Tell me which file to explain, e.g. `%s explain path/to/file.go`.", command.Prefix), nil
+		}
+		return explainFile(ctx, conn, client, event, pullRequest, strings.Join(cmd.Args, " "))
+
+	case command.Persona:
+		if len(cmd.Args) == 0 {
+			return fmt.Sprintf("Tell me which persona to use, e.g. `%s persona mentor`.", command.Prefix), nil
+		}
+		var repo db.Repository
+		err := conn.Where(&db.Repository{ID: pullRequest.RepoID}).First(&repo).Error
+		if err != nil {
+			return "", fmt.Errorf("failed to load repository: %w", err)
+		}
+		name := strings.Join(cmd.Args, " ")
+		selected, err := persona.FindForRepo(conn, repo, name)
+		if errors.Is(err, persona.ErrNotFound) {
+			return fmt.Sprintf("I don't know a persona called %q.", name), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := updatePullRequest(conn, pullRequest, map[string]any{"persona_id": selected.ID}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("From now on this pull request gets the **%s** treatment.", selected.Name), nil
+
+	case command.Ignore:
+		if err := updatePullRequest(conn, pullRequest, map[string]any{"ignored": true}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("I'll leave this pull request alone. Use `%s roast` if you change your mind.", command.Prefix), nil
+	}
+	return "", fmt.Errorf("command %q has no implementation", cmd.Name)
+}
+
+func explainFile(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent, pullRequest *db.PullRequest, name string) (string, error) {
+	owner, repoName := body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName()
+	rawDiff, _, err := client.PullRequests.GetRaw(ctx, owner, repoName, body.GetNumber(), github.RawOptions{
+		Type: github.RawType(github.Diff),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get PR diff: %w", err)
+	}
+	files, err := diff.Parse(rawDiff)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse PR diff: %w", err)
+	}
+	name = strings.Trim(name, "`")
+	var file *diff.File
+	for i := range files {
+		if files[i].Path() == name || files[i].OldPath == name {
+			file = &files[i]
+			break
+		}
+	}
+	if file == nil {
+		return fmt.Sprintf("`%s` isn't changed in this pull request.", name), nil
+	}
+	if file.IsBinary {
+		return fmt.Sprintf("`%s` is a binary file, so there's nothing I can explain.", name), nil
+	}
+
+	engine, err := newEngine(ctx, conn, client, body.GetRepo(), body.GetPullRequest().GetBase().GetRef(), *pullRequest)
+	if err != nil {
+		return "", err
+	}
+	reservation, err := reserveCall(ctx, conn, body.GetInstallation(), fmt.Sprintf("explain:%d:%s", body.GetNumber(), file.Path()))
+	if errors.Is(err, quota.ErrExhausted) {
+		return fmt.Sprintf("This installation is out of quota for the month, so explanations resume on %s.", quota.ResetsAt(time.Now()).Format("January 2")), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	resp, err := engine.Explain(ctx, *file)
+	if resp != nil {
+		reservation.Record(conn, resp.Usage.TotalTokens)
+		usage.RecordCall(conn, db.ModelCall{
+			Kind:              db.ModelCallExplain,
+			RepoID:            body.GetRepo().GetID(),
+			PullRequestNumber: body.GetNumber(),
+			InstallationID:    body.GetInstallation().GetID(),
+			Provider:          engine.Provider.Name(),
+		}, resp)
+	}
+	if err != nil {
+		reservation.Release(conn)
+		if errors.Is(err, roast.ErrTooLarge) {
+			return fmt.Sprintf("The changes to `%s` are too large for me to explain in one go.", file.Path()), nil
+		}
+		return "", fmt.Errorf("unable to explain %s: %w", name, err)
+	}
+	return fmt.Sprintf("#### `%s`\n\n%s", file.Path(), resp.Text), nil
+}
+
+func updatePullRequest(conn *gorm.DB, pullRequest *db.PullRequest, updates map[string]any) error {
+	err := conn.Model(&db.PullRequest{}).
+		Where(&db.PullRequest{ID: pullRequest.ID}).
+		Updates(updates).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to update pull request: %w", err)
+	}
+	return nil
+}
+
+func react(ctx context.Context, client *github.Client, body *github.IssueCommentEvent, reaction string) {
+	_, _, err := client.Reactions.CreateIssueCommentReaction(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetComment().GetID(), reaction)
+	if err != nil {
+		log.Printf("Failed to react to comment %d: %v", body.GetComment().GetID(), err)
+	}
+}
+
+// reply answers the command in a new comment quoting it, since GitHub has no
+// threads for pull request conversation comments.
+func reply(ctx context.Context, client *github.Client, body *github.IssueCommentEvent, message string) {
+	quoted := "> " + strings.ReplaceAll(strings.TrimSpace(body.GetComment().GetBody()), "\n", "\n> ")
+	_, _, err := client.Issues.CreateComment(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetIssue().GetNumber(), &github.IssueComment{
+		Body: github.Ptr(quoted + "\n\n" + message),
+	})
+	if err != nil {
+		log.Printf("Failed to reply to comment %d: %v", body.GetComment().GetID(), err)
+	}
+}
@@ -52,7 +52,7 @@ func HandleMemberEvent(w http.ResponseWriter, body github.MemberEvent) {
 func handleMemberAdded(conn *gorm.DB, repository *github.Repository, member *github.User, changes *github.MemberChanges) error {
 	count := int64(0)
 	err := conn.Model(&db.UserLogin{}).
-		Where(&db.UserLogin{GithubID: member.GetID()}).
+		Where(&db.UserLogin{GithubID: github.Ptr(member.GetID())}).
 		Count(&count).
 		Error
 	if err != nil {
@@ -69,7 +69,7 @@ func handleMemberAdded(conn *gorm.DB, repository *github.Repository, member *git
 	}
 	if count > 0 {
 		var userLogin db.UserLogin
-		err = conn.Where(&db.UserLogin{GithubID: member.GetID()}).
+		err = conn.Where(&db.UserLogin{GithubID: github.Ptr(member.GetID())}).
 			First(&userLogin).
 			Error
 		if err != nil {
@@ -1,22 +1,36 @@
 package handlers
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"slices"
+	"strings"
+	"time"
 
 	db "github.com/chopstickleg/good-code/api/_db"
 	utils "github.com/chopstickleg/good-code/api/_utils"
+	command "github.com/chopstickleg/good-code/api/_utils/command"
+	config "github.com/chopstickleg/good-code/api/_utils/config"
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	jobs "github.com/chopstickleg/good-code/api/_utils/jobs"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+	policy "github.com/chopstickleg/good-code/api/_utils/policy"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+	usage "github.com/chopstickleg/good-code/api/_utils/usage"
 
 	"github.com/google/go-github/v72/github"
-	"google.golang.org/genai"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var actions = []string{"opened", "synchronize", "reopened"}
+// Actions that may trigger a roast, subject to the repository's trigger rules
+var actions = []string{"opened", "synchronize", "reopened", "ready_for_review", "labeled"}
 
 func HandlePullRequestEvent(w http.ResponseWriter, body github.PullRequestEvent) {
 	conn, err := db.GetDB()
@@ -25,19 +39,105 @@ func HandlePullRequestEvent(w http.ResponseWriter, body github.PullRequestEvent)
 		log.Printf("Failed to connect to database: %v", err)
 		return
 	}
-	if slices.Contains(actions, body.GetAction()) {
-		log.Printf("Received PR event: %s for PR #%d in %s", body.GetAction(), body.GetNumber(), body.GetRepo().GetFullName())
-		if err := roastPullRequest(conn, &body); err != nil {
-			log.Printf("Failed to roast PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
-			http.Error(w, "Failed to roast pull request", http.StatusInternalServerError)
-			return
-		}
-	} else if body.GetAction() == "closed" && body.PullRequest.GetMerged() {
-		log.Printf("Received merged/closed PR event: %s for PR #%d in %s", body.GetAction(), body.GetNumber(), body.GetRepo().GetFullName())
-	} else {
-		log.Printf("Received non-PR event: %s", body.GetAction())
+	log.Printf("Received PR event: %s for PR #%d in %s", body.GetAction(), body.GetNumber(), body.GetRepo().GetFullName())
+	pullRequest, err := upsertPullRequest(conn, &body)
+	if err != nil {
+		log.Printf("Failed to record PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
+		http.Error(w, "Failed to record pull request", http.StatusInternalServerError)
 		return
 	}
+	if !slices.Contains(actions, body.GetAction()) || pullRequest.Ignored {
+		return
+	}
+	if body.GetAction() == "labeled" && !forcesRoast(context.Background(), &body) {
+		log.Printf("Label %q does not trigger a roast of PR #%d", body.GetLabel().GetName(), body.GetNumber())
+		return
+	}
+	enqueue(w, conn, JobRoastPullRequest, body)
+}
+
+// forcesRoast reports whether the label just added is one of the repository's
+// force labels. Labels are added far more often than they trigger a roast, so
+// the rest are dropped here rather than each queueing a job.
+func forcesRoast(ctx context.Context, body *github.PullRequestEvent) bool {
+	rules := policy.Default()
+	client, err := getAuthedClient(body.GetInstallation().GetID())
+	if err == nil {
+		var resolved *config.Resolved
+		resolved, err = config.Fetch(ctx, client, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetPullRequest().GetBase().GetRef())
+		if err == nil {
+			rules = resolved.Config.Triggers
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to fetch %s for %s, using the default force labels: %v", config.FileName, body.GetRepo().GetFullName(), err)
+	}
+	return rules.Forces(body.GetLabel().GetName())
+}
+
+// upsertPullRequest records the current state of the pull request, whatever
+// the action was, so closed and merged pull requests can be told apart.
+func upsertPullRequest(conn *gorm.DB, body *github.PullRequestEvent) (*db.PullRequest, error) {
+	ghPullRequest := body.GetPullRequest()
+	state := ghPullRequest.GetState()
+	if ghPullRequest.GetMerged() || ghPullRequest.MergedAt != nil {
+		state = db.PullRequestMerged
+	}
+	pullRequest := db.PullRequest{
+		RepoID:         body.GetRepo().GetID(),
+		Number:         body.GetNumber(),
+		Title:          ghPullRequest.GetTitle(),
+		AuthorGithubID: ghPullRequest.GetUser().GetID(),
+		AuthorLogin:    ghPullRequest.GetUser().GetLogin(),
+		BaseRef:        ghPullRequest.GetBase().GetRef(),
+		BaseSHA:        ghPullRequest.GetBase().GetSHA(),
+		HeadRef:        ghPullRequest.GetHead().GetRef(),
+		HeadSHA:        ghPullRequest.GetHead().GetSHA(),
+		Draft:          ghPullRequest.GetDraft(),
+		State:          state,
+		OpenedAt:       ghPullRequest.GetCreatedAt().Time,
+	}
+	if ghPullRequest.ClosedAt != nil {
+		pullRequest.ClosedAt = &ghPullRequest.ClosedAt.Time
+	}
+	if ghPullRequest.MergedAt != nil {
+		pullRequest.MergedAt = &ghPullRequest.MergedAt.Time
+	}
+
+	err := conn.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "repo_id"}, {Name: "number"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"title", "author_github_id", "author_login", "base_ref", "base_sha", "head_ref", "head_sha",
+			"draft", "state", "opened_at", "closed_at", "merged_at", "updated_at",
+		}),
+	}).Create(&pullRequest).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pull request: %w", err)
+	}
+	// The ID is not returned when the row already existed
+	err = conn.Where(&db.PullRequest{RepoID: pullRequest.RepoID, Number: pullRequest.Number}).First(&pullRequest).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pull request: %w", err)
+	}
+	return &pullRequest, nil
+}
+
+// loadPullRequestEvent builds a pull request event for roasts that were not
+// triggered by a pull_request webhook, such as re-runs and slash commands.
+// Any action but synchronize gets a full review.
+func loadPullRequestEvent(ctx context.Context, client *github.Client, repo *github.Repository, installation *github.Installation, sender *github.User, number int, action string) (*github.PullRequestEvent, error) {
+	ghPullRequest, _, err := client.PullRequests.Get(ctx, repo.GetOwner().GetLogin(), repo.GetName(), number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pull request #%d: %w", number, err)
+	}
+	return &github.PullRequestEvent{
+		Action:       github.Ptr(action),
+		Number:       ghPullRequest.Number,
+		PullRequest:  ghPullRequest,
+		Repo:         repo,
+		Installation: installation,
+		Sender:       sender,
+	}, nil
 }
 
 func getAuthedClient(installationID int64) (*github.Client, error) {
@@ -58,90 +158,695 @@ func getAuthedClient(installationID int64) (*github.Client, error) {
 	return authedGHClient, nil
 }
 
-func roastPullRequest(conn *gorm.DB, body *github.PullRequestEvent) error {
+// roastPullRequest roasts the pull request unless something rules it out, in
+// which case it returns why and no error.
+func roastPullRequest(ctx context.Context, conn *gorm.DB, body *github.PullRequestEvent, pullRequest *db.PullRequest) (skipped string, err error) {
 	authedGHClient, err := getAuthedClient(body.GetInstallation().GetID())
 	if err != nil {
 		log.Printf("Failed to get authenticated GitHub client: %v", err)
-		return fmt.Errorf("Failed to get authenticated GitHub client: %w", err)
+		return "", fmt.Errorf("Failed to get authenticated GitHub client: %w", err)
+	}
+	if pullRequest.Ignored {
+		log.Printf("PR #%d in %s is ignored, skipping", body.GetNumber(), body.GetRepo().GetFullName())
+		skipped = "ignored with " + command.Prefix + " " + command.Ignore
+		recordSkip(conn, pullRequest, skipped)
+		return skipped, nil
+	}
+	repoConfig, err := config.Fetch(ctx, authedGHClient, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetPullRequest().GetBase().GetRef())
+	if err != nil {
+		log.Printf("Failed to fetch %s for %s, using defaults: %v", config.FileName, body.GetRepo().GetFullName(), err)
+		repoConfig = &config.Resolved{Config: config.Default(), Source: "default"}
+	}
+	cfg := repoConfig.Config
+
+	decision := cfg.Triggers.Evaluate(triggerFacts(body))
+	if !decision.Roast {
+		if decision.Reason == "" {
+			return "not a trigger for a roast", nil
+		}
+		log.Printf("Not roasting PR #%d in %s: %s", body.GetNumber(), body.GetRepo().GetFullName(), decision.Reason)
+		recordSkip(conn, pullRequest, decision.Reason)
+		return decision.Reason, nil
+	}
+
+	if done, err := alreadyRoasted(ctx, conn, body); err != nil {
+		return "", err
+	} else if done {
+		log.Printf("PR #%d in %s was already roasted at %s, not roasting it again", body.GetNumber(), body.GetRepo().GetFullName(), body.GetPullRequest().GetHead().GetSHA())
+		return "already roasted at " + body.GetPullRequest().GetHead().GetSHA(), nil
 	}
-	diff, _, err := authedGHClient.PullRequests.GetRaw(context.Background(), body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), github.RawOptions{
+
+	check := startCheckRun(ctx, authedGHClient, body)
+	defer func() {
+		if err != nil {
+			check.fail(err)
+		}
+	}()
+
+	var repo db.Repository
+	err = conn.Where(&db.Repository{ID: body.GetRepo().GetID()}).First(&repo).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Failed to load repository %d: %v", body.GetRepo().GetID(), err)
+		return "", fmt.Errorf("Failed to load repository: %w", err)
+	}
+
+	selectedPersona, err := persona.Resolve(conn, repo, *pullRequest, cfg.Tone)
+	if errors.Is(err, persona.ErrNotFound) && cfg.Tone != "" {
+		repoConfig.Errors = append(repoConfig.Errors, fmt.Sprintf("tone: unknown persona %q", cfg.Tone))
+		selectedPersona, err = persona.FindByName(conn, persona.Default, nil)
+	}
+	if err != nil {
+		// Fall back to the instructions baked into the prompt rather than skipping the roast
+		log.Printf("Failed to resolve persona for PR #%d, using the default instructions: %v", body.GetNumber(), err)
+		selectedPersona = nil
+	}
+
+	if len(repoConfig.Errors) > 0 {
+		if err := upsertNotice(ctx, authedGHClient, body, configNoticeMarker, renderConfigErrors(repoConfig.Errors)); err != nil {
+			log.Printf("Failed to report invalid %s on PR #%d: %v", config.FileName, body.GetNumber(), err)
+		}
+	}
+
+	rawDiff, _, err := authedGHClient.PullRequests.GetRaw(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), github.RawOptions{
 		Type: github.RawType(github.Diff),
 	})
 	if err != nil {
 		log.Printf("Failed to get PR diff for PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
-		return fmt.Errorf("Failed to get PR diff: %w", err)
+		return "", fmt.Errorf("Failed to get PR diff: %w", err)
+	}
+	if cfg.MaxDiffSize > 0 && len(rawDiff) > cfg.MaxDiffSize {
+		log.Printf("Diff of PR #%d is %d bytes, over the configured limit of %d", body.GetNumber(), len(rawDiff), cfg.MaxDiffSize)
+		completeCheckRun(check, roast.ConclusionNeutral, "Diff too large", fmt.Sprintf("The diff is %d bytes, over the `max_diff_size` of %d bytes.", len(rawDiff), cfg.MaxDiffSize), nil)
+		skipped = fmt.Sprintf("diff is %d bytes, over the max_diff_size of %d", len(rawDiff), cfg.MaxDiffSize)
+		recordSkip(conn, pullRequest, skipped)
+		if err := upsertNotice(ctx, authedGHClient, body, diffSizeNoticeMarker, fmt.Sprintf("This diff is %d bytes, which is over the `max_diff_size` of %d bytes set in `%s`, so I'm not even going to look at it.", len(rawDiff), cfg.MaxDiffSize, config.FileName)); err != nil {
+			log.Printf("Failed to explain skipped roast on PR #%d: %v", body.GetNumber(), err)
+		}
+		return skipped, nil
 	}
 
-	token := os.Getenv("AI_API_TOKEN")
-	if token == "" {
-		log.Printf("ERROR: Unable to get AI API token")
-		return fmt.Errorf("Unable to get AI API token")
+	installation, limits, err := quota.LimitsFor(conn, body.GetInstallation().GetID(), body.GetInstallation().GetAccount().GetLogin())
+	if err != nil {
+		log.Printf("Failed to load quota of installation %d: %v", body.GetInstallation().GetID(), err)
+		return "", fmt.Errorf("Failed to load quota: %w", err)
+	}
+	if limits.MaxDiffBytes > 0 && len(rawDiff) > limits.MaxDiffBytes {
+		log.Printf("Diff of PR #%d is %d bytes, over the %s plan limit of %d", body.GetNumber(), len(rawDiff), installation.Plan, limits.MaxDiffBytes)
+		completeCheckRun(check, roast.ConclusionNeutral, "Diff too large", fmt.Sprintf("The diff is %d bytes, over the %d bytes allowed on the %s plan.", len(rawDiff), limits.MaxDiffBytes, installation.Plan), nil)
+		skipped = fmt.Sprintf("diff is %d bytes, over the %d allowed on the %s plan", len(rawDiff), limits.MaxDiffBytes, installation.Plan)
+		recordSkip(conn, pullRequest, skipped)
+		if err := upsertNotice(ctx, authedGHClient, body, diffSizeNoticeMarker, fmt.Sprintf("Sorry, this diff is %d bytes and the %s plan this installation is on only covers diffs up to %d bytes, so I'll have to sit this one out. Smaller pull requests are easier to review anyway.", len(rawDiff), installation.Plan, limits.MaxDiffBytes)); err != nil {
+			log.Printf("Failed to explain skipped roast on PR #%d: %v", body.GetNumber(), err)
+		}
+		return skipped, nil
 	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  token,
-		Backend: genai.BackendGeminiAPI,
-	})
+	provider, err := llm.NewProvider(llm.ConfigFromEnv().
+		WithOverrides(repo.AiProvider, repo.AiModel).
+		WithOverrides(cfg.Provider, cfg.Model))
+	if err != nil {
+		log.Printf("Failed to create AI provider: %v", err)
+		return "", fmt.Errorf("Unable to create AI provider: %w", err)
+	}
+	log.Printf("Roasting PR #%d with provider %s (model %s)", body.GetNumber(), provider.Name(), provider.Model())
+
+	files, err := diff.Parse(rawDiff)
+	if err != nil {
+		log.Printf("Failed to parse diff for PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
+		return "", fmt.Errorf("Failed to parse PR diff: %w", err)
+	}
+
+	engine := roast.Engine{
+		Provider:     provider,
+		Options:      roast.OptionsFromEnv(),
+		Instructions: cfg.Instructions(),
+	}
+	if selectedPersona != nil {
+		engine.Instructions.Persona = selectedPersona.Instructions
+	}
+	reviewFiles := files
+	reviewedFrom := ""
+	if body.GetAction() == "synchronize" {
+		previous, delta, err := getIncrementalDiff(ctx, conn, authedGHClient, body)
+		if err != nil {
+			log.Printf("Failed to get incremental diff for PR #%d, falling back to a full review: %v", body.GetNumber(), err)
+		} else if previous != nil && delta == nil {
+			log.Printf("No new changes since last roast of PR #%d, skipping", body.GetNumber())
+			completeCheckRun(check, roast.ConclusionSkipped, "Nothing new to roast", fmt.Sprintf("Nothing changed since %s was roasted.", previous.HeadSHA), nil)
+			skipped = "nothing changed since " + previous.HeadSHA + " was roasted"
+			recordSkip(conn, pullRequest, skipped)
+			return skipped, nil
+		} else if previous != nil {
+			reviewFiles = delta
+			reviewedFrom = previous.HeadSHA
+			raised, err := raisedFindings(conn, body.GetRepo().GetID(), body.GetNumber())
+			if err != nil {
+				log.Printf("Failed to load earlier findings for PR #%d: %v", body.GetNumber(), err)
+			}
+			engine.Prompt.Preamble = roast.IncrementalPreamble(previous.Content, raised)
+		}
+	}
+
+	var attributes diff.Attributes
+	rawAttributes, found, err := config.FetchFile(ctx, authedGHClient, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), ".gitattributes", body.GetPullRequest().GetBase().GetRef())
 	if err != nil {
-		log.Printf("Failed to create AI client: %v", err)
-		return fmt.Errorf("Unable to create AI client: %w", err)
+		log.Printf("Failed to fetch .gitattributes for %s: %v", body.GetRepo().GetFullName(), err)
+	} else if found {
+		attributes = diff.ParseAttributes(string(rawAttributes))
 	}
 
-	config := genai.GenerateContentConfig{
-		SystemInstruction: genai.NewContentFromText("You are a code review assistant. You will be given a diff of a pull request. Your task is to review the code and provide feedback. You should be sarcastic and condescending, but still helpful and provide useful feedback that is factually accurate to the best of your knowledge", genai.RoleModel),
+	reviewFiles, ignored := diff.Preprocess(reviewFiles, diff.PreprocessOptions{
+		Ignore:       cfg.Ignore,
+		IgnoreReason: "ignored by " + config.FileName,
+		Attributes:   attributes,
+	})
+	if len(reviewFiles) == 0 {
+		log.Printf("Every changed file in PR #%d is ignored, skipping", body.GetNumber())
+		completeCheckRun(check, roast.ConclusionSkipped, "Nothing to roast", "Every changed file is ignored.\n\n"+roast.RenderSkipped(ignored), nil)
+		skipped = "every changed file is ignored"
+		recordSkip(conn, pullRequest, skipped)
+		if err := upsertNotice(ctx, authedGHClient, body, skippedNoticeMarker, "Every file changed in this pull request is ignored, so there is nothing for me to roast.\n\n"+roast.RenderSkipped(ignored)); err != nil {
+			log.Printf("Failed to list skipped files on PR #%d: %v", body.GetNumber(), err)
+		}
+		return skipped, nil
+	}
+	intent := pullRequestIntent(ctx, authedGHClient, body)
+	engine.Prompt.Intent = &intent
+	if cfg.Context.Lines > 0 {
+		engine.Prompt.Contents = fetchContents(ctx, authedGHClient, body.GetRepo(), body.GetPullRequest().GetHead().GetSHA(), reviewFiles)
+		engine.Prompt.ContextLines = cfg.Context.Lines
 	}
-	result, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-2.5-flash-lite-preview-06-17",
-		genai.Text(diff),
-		&config,
-	)
+
+	estimated, err := engine.Estimate(reviewFiles)
 	if err != nil {
-		log.Printf("Failed to generate AI analysis: %v", err)
-		return fmt.Errorf("Unable to generate AI analysis: %w", err)
+		log.Printf("Failed to plan roast of PR #%d: %v", body.GetNumber(), err)
+		return "", fmt.Errorf("Unable to plan roast: %w", err)
+	}
+	if limits.MaxTokensPerRoast > 0 && int64(estimated) > limits.MaxTokensPerRoast {
+		log.Printf("Roast of PR #%d would take about %d tokens, over the %s plan limit of %d", body.GetNumber(), estimated, installation.Plan, limits.MaxTokensPerRoast)
+		completeCheckRun(check, roast.ConclusionNeutral, "Diff too large", fmt.Sprintf("Roasting this diff would take about %d tokens, over the %d tokens per roast allowed on the %s plan.", estimated, limits.MaxTokensPerRoast, installation.Plan), nil)
+		skipped = fmt.Sprintf("roast would take about %d tokens, over the %d allowed on the %s plan", estimated, limits.MaxTokensPerRoast, installation.Plan)
+		recordSkip(conn, pullRequest, skipped)
+		if err := upsertNotice(ctx, authedGHClient, body, diffSizeNoticeMarker, fmt.Sprintf("Sorry, roasting this diff would take about %d tokens and the %s plan this installation is on only covers %d tokens per roast, so I'll have to sit this one out. Smaller pull requests are easier to review anyway.", estimated, installation.Plan, limits.MaxTokensPerRoast)); err != nil {
+			log.Printf("Failed to explain skipped roast on PR #%d: %v", body.GetNumber(), err)
+		}
+		return skipped, nil
 	}
 
-	if result == nil || result.Text() == "" {
-		log.Printf("AI analysis returned empty result for PR #%d", body.GetNumber())
-		return fmt.Errorf("AI analysis returned empty result")
+	reservation, err := quota.Reserve(conn, installation.ID, limits, reservationKey(ctx, fmt.Sprintf("roast:%d:%d", body.GetRepo().GetID(), body.GetNumber())))
+	if errors.Is(err, quota.ErrExhausted) {
+		log.Printf("Installation %d is out of quota, not roasting PR #%d", installation.ID, body.GetNumber())
+		completeCheckRun(check, roast.ConclusionNeutral, "Quota exhausted", fmt.Sprintf("This installation has used up its monthly quota on the %s plan. Roasts resume on %s.", installation.Plan, quota.ResetsAt(time.Now()).Format("January 2")), nil)
+		notifyQuotaExhausted(ctx, conn, authedGHClient, body, pullRequest, installation.Plan)
+		skipped = "out of quota on the " + installation.Plan + " plan"
+		recordSkip(conn, pullRequest, skipped)
+		return skipped, nil
+	}
+	if err != nil {
+		log.Printf("Failed to reserve quota for PR #%d: %v", body.GetNumber(), err)
+		return "", fmt.Errorf("Failed to reserve quota: %w", err)
 	}
 
-	log.Println(result.Text())
+	result, err := engine.Run(ctx, reviewFiles)
+	if err != nil {
+		if result != nil && result.Usage.TotalTokens > 0 {
+			reservation.Record(conn, result.Usage.TotalTokens)
+			usage.RecordCall(conn, db.ModelCall{
+				Kind:              db.ModelCallFailedRoast,
+				RepoID:            body.GetRepo().GetID(),
+				PullRequestNumber: body.GetNumber(),
+				InstallationID:    body.GetInstallation().GetID(),
+				Provider:          provider.Name(),
+			}, &llm.Response{Model: provider.Model(), Usage: result.Usage})
+		}
+		reservation.Release(conn)
+		log.Printf("Failed to generate AI analysis for PR #%d: %v", body.GetNumber(), err)
+		return "", fmt.Errorf("Unable to generate AI analysis: %w", err)
+	}
+	reservation.Record(conn, result.Usage.TotalTokens)
+	review := result.Review
+	if !intent.Described() {
+		review.Findings = append(review.Findings, roast.MissingDescription())
+	}
+	result.Plan.SkippedFiles = append(ignored, result.Plan.SkippedFiles...)
 
 	pr := db.AiRoast{
-		Content:           result.Text(),
+		Content:           roast.RenderMarkdown(review),
+		Provider:          provider.Name(),
+		Model:             result.Model,
+		ChunkPlan:         chunkPlan(result.Plan),
+		HeadSHA:           body.GetPullRequest().GetHead().GetSHA(),
+		BaseSHA:           body.GetPullRequest().GetBase().GetSHA(),
+		ReviewedFromSHA:   reviewedFrom,
+		PromptVersion:     roast.PromptVersion,
 		RepoID:            body.GetRepo().GetID(),
 		PullRequestNumber: body.PullRequest.GetNumber(),
+		PullRequestID:     &pullRequest.ID,
+		CheckRunID:        check.ID(),
+		Conclusion:        roast.Conclusion(review, cfg.Conclusion.Failure, cfg.Conclusion.Neutral),
+		InstallationID:    body.GetInstallation().GetID(),
+		PromptTokens:      result.Usage.PromptTokens,
+		CandidateTokens:   result.Usage.CandidateTokens,
+		TotalTokens:       result.Usage.TotalTokens,
+		LatencyMs:         result.Usage.Latency.Milliseconds(),
+	}
+	if cost, ok := llm.PricingFromEnv().Cost(result.Model, result.Usage); ok {
+		pr.EstimatedCostUSD = cost
+	} else {
+		log.Printf("No price for model %s, not estimating the cost of the roast of PR #%d", result.Model, body.GetNumber())
+	}
+	if selectedPersona != nil {
+		pr.PersonaID = &selectedPersona.ID
+		pr.PersonaName = selectedPersona.Name
+		pr.PersonaVersion = selectedPersona.Version
+	}
+	for _, finding := range review.Findings {
+		pr.Findings = append(pr.Findings, db.RoastFinding{
+			File:       finding.File,
+			StartLine:  finding.Line,
+			EndLine:    finding.EndLine,
+			Severity:   finding.Severity,
+			Category:   finding.Category,
+			Message:    finding.Message,
+			Suggestion: finding.Suggestion,
+		})
 	}
 	err = conn.Create(&pr).Error
 	if err != nil {
 		log.Printf("Failed to save AI analysis to database for PR #%d: %v", body.GetNumber(), err)
-		return fmt.Errorf("Unable to save AI analysis to database: %w", err)
+		return "", fmt.Errorf("Unable to save AI analysis to database: %w", err)
 	}
+	recordSkip(conn, pullRequest, "")
 
-	_, _, err = authedGHClient.Issues.CreateComment(context.Background(), body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), &github.IssueComment{
-		Body: github.Ptr(result.Text()),
-	})
-	if err != nil {
-		log.Printf("Failed to create comment on PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
-		return fmt.Errorf("Unable to create comment on PR: %w", err)
+	completeCheckRun(check, pr.Conclusion, checkRunTitle(review), roast.RenderMarkdown(review), annotations(review, files))
+
+	if err := postRoast(ctx, conn, authedGHClient, body, &pr, review, files, result.Plan.SkippedFiles, repo.CommentMode != db.CommentModeNew); err != nil {
+		log.Printf("Failed to create review on PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
+		return "", fmt.Errorf("Unable to create review on PR: %w", err)
 	}
 
 	log.Printf("Successfully processed PR #%d in %s", body.GetNumber(), body.GetRepo().GetFullName())
-	return nil
+	return "", nil
 }
 
-func disablePullRequest(conn *gorm.DB, body *github.PullRequestEvent) error {
+// alreadyRoasted reports whether the head commit was roasted already, so a
+// redelivered event or a retried job does not post the same roast twice. A
+// roast someone asked for, by command, re-run or force label, only counts as
+// done when the same job wrote it on an earlier attempt.
+func alreadyRoasted(ctx context.Context, conn *gorm.DB, body *github.PullRequestEvent) (bool, error) {
+	query := conn.Model(&db.AiRoast{}).
+		Where(&db.AiRoast{RepoID: body.GetRepo().GetID(), PullRequestNumber: body.GetNumber(), HeadSHA: body.GetPullRequest().GetHead().GetSHA()})
+	if action := body.GetAction(); action == "rerequested" || action == "labeled" {
+		job, ok := jobs.Current(ctx)
+		if !ok {
+			return false, nil
+		}
+		query = query.Where("created_at >= ?", job.CreatedAt)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to look for an earlier roast: %w", err)
+	}
+	return count > 0, nil
+}
+
+// raisedFindings returns the findings of every earlier roast of the pull
+// request, oldest first, with findings repeated by later roasts listed once.
+func raisedFindings(conn *gorm.DB, repoID int64, number int) ([]roast.Finding, error) {
+	var rows []db.RoastFinding
+	err := conn.
+		Where("roast_id IN (?)", conn.Model(&db.AiRoast{}).Select("id").Where(&db.AiRoast{RepoID: repoID, PullRequestNumber: number})).
+		Order("created_at, id").
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]int, len(rows))
+	var findings []roast.Finding
+	for _, row := range rows {
+		finding := roast.Finding{
+			File:       row.File,
+			Line:       row.StartLine,
+			EndLine:    row.EndLine,
+			Severity:   row.Severity,
+			Category:   row.Category,
+			Message:    strings.TrimPrefix(row.Message, "Still open: "),
+			Suggestion: row.Suggestion,
+		}
+		key := row.File + "\x00" + strings.ToLower(strings.Join(strings.Fields(finding.Message), " "))
+		if i, ok := seen[key]; ok {
+			// Keep the latest line number, the file has moved on since
+			findings[i] = finding
+			continue
+		}
+		seen[key] = len(findings)
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
 
-	err := conn.Model(&db.AiRoast{}).
+// getIncrementalDiff returns the last roast of the pull request and the diff
+// of everything pushed since it. A nil roast means a full review is needed,
+// either because there is no usable previous roast or because the branch was
+// force-pushed and the old head is no longer an ancestor of the new one. A
+// roast with a nil diff means nothing changed since it was written.
+func getIncrementalDiff(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent) (*db.AiRoast, []diff.File, error) {
+	var previous db.AiRoast
+	err := conn.
 		Where(&db.AiRoast{RepoID: body.GetRepo().GetID(), PullRequestNumber: body.GetNumber()}).
-		Updates(&db.AiRoast{IsOpen: false}).
+		Where("head_sha <> ''").
+		Order("created_at DESC").
+		First(&previous).
+		Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load previous roast: %w", err)
+	}
+
+	head := body.GetPullRequest().GetHead().GetSHA()
+	if previous.HeadSHA == head {
+		return &previous, nil, nil
+	}
+
+	owner, name := body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName()
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, name, previous.HeadSHA, head, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		// The old head may have been garbage collected after a force-push
+		log.Printf("Failed to compare %s...%s for PR #%d, assuming history was rewritten: %v", previous.HeadSHA, head, body.GetNumber(), err)
+		return nil, nil, nil
+	}
+	if comparison.GetStatus() == "identical" {
+		return &previous, nil, nil
+	}
+	if comparison.GetStatus() != "ahead" {
+		log.Printf("Head of PR #%d is %s relative to the last roast, doing a full review", body.GetNumber(), comparison.GetStatus())
+		return nil, nil, nil
+	}
+
+	rawDelta, _, err := client.Repositories.CompareCommitsRaw(ctx, owner, name, previous.HeadSHA, head, github.RawOptions{
+		Type: github.RawType(github.Diff),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get compare diff: %w", err)
+	}
+	delta, err := diff.Parse(rawDelta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compare diff: %w", err)
+	}
+	if len(delta) == 0 {
+		return &previous, nil, nil
+	}
+	log.Printf("Reviewing %d files changed in PR #%d since %s", len(delta), body.GetNumber(), previous.HeadSHA)
+	return &previous, delta, nil
+}
+
+func postComment(ctx context.Context, client *github.Client, body *github.PullRequestEvent, comment string) error {
+	_, _, err := client.Issues.CreateComment(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), &github.IssueComment{
+		Body: github.Ptr(comment),
+	})
+	return err
+}
+
+// Markers of the notices GoodCode edits in place rather than posting again on
+// every push.
+const (
+	configNoticeMarker   = "<!-- goodcode:config -->"
+	diffSizeNoticeMarker = "<!-- goodcode:diff-size -->"
+	skippedNoticeMarker  = "<!-- goodcode:skipped -->"
+)
+
+// upsertNotice keeps a single comment carrying the marker on the pull request,
+// editing it when the notice changed and creating it the first time.
+func upsertNotice(ctx context.Context, client *github.Client, body *github.PullRequestEvent, marker string, notice string) error {
+	owner, name := body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName()
+	text := marker + "\n" + notice
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, name, body.GetNumber(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to list comments: %w", err)
+		}
+		for _, comment := range comments {
+			if comment.GetUser().GetType() != "Bot" || !strings.HasPrefix(comment.GetBody(), marker) {
+				continue
+			}
+			if comment.GetBody() == text {
+				return nil
+			}
+			_, _, err := client.Issues.EditComment(ctx, owner, name, comment.GetID(), &github.IssueComment{Body: github.Ptr(text)})
+			return err
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return postComment(ctx, client, body, text)
+}
+
+func renderConfigErrors(errs []string) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Some settings in the `%s` on the base branch are invalid, so this review uses their defaults instead:\n", config.FileName))
+	for _, e := range errs {
+		b.WriteString("\n- " + e)
+	}
+	return b.String()
+}
+
+// postRoast publishes a roast on the pull request. Findings that can be
+// anchored to the diff become inline review comments. The summary goes in the
+// review body or, when sticky is set, in the single comment GoodCode keeps
+// editing on the pull request.
+func postRoast(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent, record *db.AiRoast, review *roast.Review, files []diff.File, skipped []diff.SkippedFile, sticky bool) error {
+	anchored, unanchored := roast.Anchor(review, files)
+	skippedSection := roast.RenderSkipped(skipped)
+	if skippedSection != "" {
+		skippedSection = "\n\n" + skippedSection
+	}
+
+	comments := make([]*github.DraftReviewComment, 0, len(anchored))
+	for _, finding := range anchored {
+		comments = append(comments, &github.DraftReviewComment{
+			Path:     github.Ptr(finding.File),
+			Position: github.Ptr(finding.Position),
+			Body:     github.Ptr(finding.Body()),
+		})
+	}
+
+	if !sticky {
+		posted, err := postReview(ctx, client, body, roast.RenderSummary(review.Overview(), unanchored)+skippedSection, comments)
+		if err == nil && len(comments) > 0 {
+			linkReviewComments(ctx, conn, client, body, posted, record)
+		}
+		if err == nil || len(comments) == 0 {
+			return err
+		}
+		// GitHub rejects the whole review if a single position is off, so retry
+		// with every finding in the summary rather than losing the roast
+		log.Printf("Failed to create review with %d inline comments, retrying without them: %v", len(comments), err)
+		_, err = postReview(ctx, client, body, roast.RenderMarkdown(review)+skippedSection, nil)
+		return err
+	}
+
+	summary := roast.RenderSummary(review.Overview(), unanchored)
+	if len(comments) > 0 {
+		posted, err := postReview(ctx, client, body, "Inline findings for this push; the full roast is in the GoodCode comment on this pull request.", comments)
+		if err != nil {
+			log.Printf("Failed to create review with %d inline comments, moving them to the sticky comment: %v", len(comments), err)
+			summary = roast.RenderMarkdown(review)
+		} else {
+			linkReviewComments(ctx, conn, client, body, posted, record)
+		}
+	}
+	return upsertStickyComment(ctx, conn, client, body, record, summary+skippedSection)
+}
+
+func postReview(ctx context.Context, client *github.Client, body *github.PullRequestEvent, reviewBody string, comments []*github.DraftReviewComment) (*github.PullRequestReview, error) {
+	review, _, err := client.PullRequests.CreateReview(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), &github.PullRequestReviewRequest{
+		CommitID: github.Ptr(body.GetPullRequest().GetHead().GetSHA()),
+		Body:     github.Ptr(reviewBody),
+		Event:    github.Ptr("COMMENT"),
+		Comments: comments,
+	})
+	return review, err
+}
+
+// linkReviewComments records which inline comment each finding was posted as,
+// so replies to the comment can be traced back to the finding. Comments are
+// matched by path and line, in the order they were posted when several
+// findings share a line.
+func linkReviewComments(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent, review *github.PullRequestReview, record *db.AiRoast) {
+	if len(record.Findings) == 0 {
+		return
+	}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comments, resp, err := client.PullRequests.ListReviewComments(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetNumber(), review.GetID(), opts)
+		if err != nil {
+			log.Printf("Failed to list comments of review %d on PR #%d: %v", review.GetID(), body.GetNumber(), err)
+			return
+		}
+		for _, comment := range comments {
+			for i := range record.Findings {
+				finding := &record.Findings[i]
+				if finding.GithubCommentID != 0 || strings.TrimPrefix(finding.File, "b/") != comment.GetPath() {
+					continue
+				}
+				if line := cmp.Or(comment.GetLine(), comment.GetOriginalLine()); line != finding.StartLine {
+					continue
+				}
+				finding.GithubCommentID = comment.GetID()
+				err := conn.Model(&db.RoastFinding{}).
+					Where(&db.RoastFinding{ID: finding.ID}).
+					Update("github_comment_id", comment.GetID()).
+					Error
+				if err != nil {
+					log.Printf("Failed to link finding %d to comment %d: %v", finding.ID, comment.GetID(), err)
+				}
+				break
+			}
+		}
+		if resp.NextPage == 0 {
+			return
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func toFinding(finding db.RoastFinding) roast.Finding {
+	return roast.Finding{
+		File:       finding.File,
+		Line:       finding.StartLine,
+		EndLine:    finding.EndLine,
+		Severity:   finding.Severity,
+		Category:   finding.Category,
+		Message:    finding.Message,
+		Suggestion: finding.Suggestion,
+	}
+}
+
+// upsertStickyComment edits the comment holding the previous roast of the pull
+// request, keeping earlier roasts in a collapsed history, and records the
+// comment on the new roast. The comment is recreated if someone deleted it.
+func upsertStickyComment(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent, record *db.AiRoast, current string) error {
+	var history []db.AiRoast
+	err := conn.
+		Omit("Repository").
+		Where(&db.AiRoast{RepoID: record.RepoID, PullRequestNumber: record.PullRequestNumber}).
+		Where("id <> ?", record.ID).
+		Order("created_at DESC").
+		Limit(20).
+		Find(&history).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to load previous roasts: %w", err)
+	}
+
+	entries := make([]roast.HistoryEntry, 0, len(history))
+	var commentID int64
+	for _, previous := range history {
+		entries = append(entries, roast.HistoryEntry{HeadSHA: previous.HeadSHA, CreatedAt: previous.CreatedAt, Content: previous.Content})
+		if commentID == 0 {
+			commentID = previous.CommentID
+		}
+	}
+	comment := &github.IssueComment{Body: github.Ptr(roast.RenderSticky(current, entries))}
+
+	owner, name := body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName()
+	if commentID != 0 {
+		_, resp, err := client.Issues.EditComment(ctx, owner, name, commentID, comment)
+		if err == nil {
+			return saveCommentID(conn, record, commentID)
+		}
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("failed to edit comment %d: %w", commentID, err)
+		}
+		log.Printf("Sticky comment %d on PR #%d was deleted, creating a new one", commentID, body.GetNumber())
+	}
+
+	created, _, err := client.Issues.CreateComment(ctx, owner, name, body.GetNumber(), comment)
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return saveCommentID(conn, record, created.GetID())
+}
+
+func saveCommentID(conn *gorm.DB, record *db.AiRoast, commentID int64) error {
+	record.CommentID = commentID
+	return conn.Model(&db.AiRoast{}).
+		Where(&db.AiRoast{ID: record.ID}).
+		Update("comment_id", commentID).
+		Error
+}
+
+func chunkPlan(plan roast.Plan) db.ChunkPlan {
+	skipped := make([]db.SkippedFile, 0, len(plan.SkippedFiles))
+	for _, f := range plan.SkippedFiles {
+		skipped = append(skipped, db.SkippedFile{Path: f.Path, Reason: f.Reason})
+	}
+	return db.ChunkPlan{
+		Chunks:       plan.Chunks,
+		FailedChunks: plan.FailedChunks,
+		SkippedFiles: skipped,
+	}
+}
+
+// notifyQuotaExhausted lets the author know why their pull request was not
+// roasted, once a month per pull request however often they push.
+func notifyQuotaExhausted(ctx context.Context, conn *gorm.DB, client *github.Client, body *github.PullRequestEvent, pullRequest *db.PullRequest, plan string) {
+	period := quota.Period(time.Now())
+	result := conn.Model(&db.PullRequest{}).
+		Where(&db.PullRequest{ID: pullRequest.ID}).
+		Where("quota_notice_period IS DISTINCT FROM ?", period).
+		Update("quota_notice_period", period)
+	if result.Error != nil {
+		log.Printf("Failed to record quota notice on PR #%d: %v", body.GetNumber(), result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	message := fmt.Sprintf("Hi @%s! This installation has used up this month's roasts on the %s plan, so I can't review this pull request right now. Roasting resumes on %s, or ask an admin about a bigger plan in the meantime.",
+		body.GetPullRequest().GetUser().GetLogin(), plan, quota.ResetsAt(time.Now()).Format("January 2"))
+	if err := postComment(ctx, client, body, message); err != nil {
+		log.Printf("Failed to post quota notice on PR #%d: %v", body.GetNumber(), err)
+	}
+}
+
+// triggerFacts picks out of the event what the trigger rules look at.
+func triggerFacts(body *github.PullRequestEvent) policy.PullRequest {
+	ghPullRequest := body.GetPullRequest()
+	facts := policy.PullRequest{
+		Action:       body.GetAction(),
+		Label:        body.GetLabel().GetName(),
+		AuthorLogin:  ghPullRequest.GetUser().GetLogin(),
+		AuthorIsBot:  ghPullRequest.GetUser().GetType() == "Bot",
+		BaseRef:      ghPullRequest.GetBase().GetRef(),
+		Draft:        ghPullRequest.GetDraft(),
+		ChangedLines: ghPullRequest.GetAdditions() + ghPullRequest.GetDeletions(),
+		ChangedFiles: ghPullRequest.GetChangedFiles(),
+	}
+	for _, label := range ghPullRequest.Labels {
+		facts.Labels = append(facts.Labels, label.GetName())
+	}
+	return facts
+}
+
+// recordSkip notes on the pull request why it was not roasted, so the
+// dashboard can explain the silence. An empty reason clears it.
+func recordSkip(conn *gorm.DB, pullRequest *db.PullRequest, reason string) {
+	updates := map[string]any{"skip_reason": reason, "skipped_at": nil}
+	if reason != "" {
+		updates["skipped_at"] = time.Now()
+	}
+	err := conn.Model(&db.PullRequest{}).
+		Where(&db.PullRequest{ID: pullRequest.ID}).
+		Updates(updates).
 		Error
 	if err != nil {
-		log.Printf("Failed to update AI roast for PR #%d in %s: %v", body.GetNumber(), body.GetRepo().GetFullName(), err)
-		return fmt.Errorf("Failed to update AI roast: %w", err)
+		log.Printf("Failed to record why PR #%d was skipped: %v", pullRequest.Number, err)
 	}
-	log.Printf("Successfully closed PR #%d in %s", body.GetNumber(), body.GetRepo().GetFullName())
-	return nil
 }
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"github.com/google/go-github/v72/github"
+)
+
+func pullRequestEvent(action string, head string) *github.PullRequestEvent {
+	return &github.PullRequestEvent{
+		Action: github.Ptr(action),
+		Number: github.Ptr(7),
+		Repo: &github.Repository{
+			ID:    github.Ptr(int64(1)),
+			Name:  github.Ptr("repo"),
+			Owner: &github.User{Login: github.Ptr("owner")},
+		},
+		PullRequest: &github.PullRequest{
+			Number: github.Ptr(7),
+			Head:   &github.PullRequestBranch{SHA: github.Ptr(head)},
+			Base:   &github.PullRequestBranch{SHA: github.Ptr("base"), Ref: github.Ptr("main")},
+		},
+	}
+}
+
+const deltaDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-old
++new
+`
+
+func TestGetIncrementalDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		roasted  string
+		head     string
+		status   int
+		compare  string
+		delta    string
+		previous bool
+		files    int
+	}{
+		{"never roasted", "", "new", 0, "", "", false, 0},
+		{"head already roasted", "old", "old", 0, "", "", true, 0},
+		{"new commits", "old", "new", http.StatusOK, "ahead", deltaDiff, true, 1},
+		{"no changes", "old", "new", http.StatusOK, "identical", "", true, 0},
+		{"force-pushed", "old", "new", http.StatusOK, "diverged", "", false, 0},
+		{"old head gone", "old", "new", http.StatusNotFound, "", "", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t, &db.AiRoast{})
+			if tt.roasted != "" {
+				conn.Create(&db.AiRoast{RepoID: 1, PullRequestNumber: 7, HeadSHA: tt.roasted, Content: "Earlier roast"})
+			}
+			client := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/owner/repo/compare/"+tt.roasted+"..."+tt.head {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				if tt.status != http.StatusOK {
+					w.WriteHeader(tt.status)
+					return
+				}
+				if r.Header.Get("Accept") == "application/vnd.github.v3.diff" {
+					io.WriteString(w, tt.delta)
+					return
+				}
+				io.WriteString(w, `{"status": "`+tt.compare+`"}`)
+			}))
+
+			previous, delta, err := getIncrementalDiff(context.Background(), conn, client, pullRequestEvent("synchronize", tt.head))
+			if err != nil {
+				t.Fatalf("getIncrementalDiff() error = %v", err)
+			}
+			if (previous != nil) != tt.previous {
+				t.Errorf("previous = %+v, want one: %v", previous, tt.previous)
+			}
+			if previous != nil && previous.HeadSHA != tt.roasted {
+				t.Errorf("previous head = %s, want %s", previous.HeadSHA, tt.roasted)
+			}
+			if len(delta) != tt.files {
+				t.Errorf("delta has %d files, want %d", len(delta), tt.files)
+			}
+		})
+	}
+}
+
+func TestRaisedFindings(t *testing.T) {
+	conn := testDB(t, &db.AiRoast{}, &db.RoastFinding{})
+	first := db.AiRoast{RepoID: 1, PullRequestNumber: 7, HeadSHA: "a", Findings: []db.RoastFinding{
+		{File: "main.go", StartLine: 10, Severity: "high", Category: "bug", Message: "Nil dereference"},
+		{File: "util.go", StartLine: 3, Severity: "low", Category: "style", Message: "Odd name"},
+	}}
+	second := db.AiRoast{RepoID: 1, PullRequestNumber: 7, HeadSHA: "b", Findings: []db.RoastFinding{
+		{File: "main.go", StartLine: 12, Severity: "high", Category: "bug", Message: "Still open: Nil  dereference"},
+	}}
+	other := db.AiRoast{RepoID: 1, PullRequestNumber: 8, HeadSHA: "c", Findings: []db.RoastFinding{
+		{File: "main.go", StartLine: 1, Severity: "low", Category: "style", Message: "Another pull request"},
+	}}
+	for _, r := range []*db.AiRoast{&first, &second, &other} {
+		if err := conn.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings, err := raisedFindings(conn, 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []roast.Finding{
+		{File: "main.go", Line: 12, Severity: "high", Category: "bug", Message: "Nil  dereference"},
+		{File: "util.go", Line: 3, Severity: "low", Category: "style", Message: "Odd name"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("raisedFindings() = %+v, want %+v", findings, want)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+}
+
+func TestUpsertStickyComment(t *testing.T) {
+	tests := []struct {
+		name       string
+		previous   int64
+		editStatus int
+		want       int64
+		created    bool
+	}{
+		{"first roast", 0, 0, 99, true},
+		{"edits the previous comment", 42, http.StatusOK, 42, false},
+		{"recreates a deleted comment", 42, http.StatusNotFound, 99, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t, &db.AiRoast{})
+			if tt.previous != 0 {
+				conn.Create(&db.AiRoast{RepoID: 1, PullRequestNumber: 7, HeadSHA: "old", Content: "Earlier roast", CommentID: tt.previous})
+			}
+			record := &db.AiRoast{RepoID: 1, PullRequestNumber: 7, HeadSHA: "new", Content: "Current roast"}
+			conn.Create(record)
+
+			var created bool
+			var body string
+			client := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				json.NewDecoder(r.Body).Decode(&comment)
+				body = comment.GetBody()
+				switch {
+				case r.Method == http.MethodPatch && r.URL.Path == "/repos/owner/repo/issues/comments/42":
+					w.WriteHeader(tt.editStatus)
+					io.WriteString(w, `{"id": 42}`)
+				case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/issues/7/comments":
+					created = true
+					w.WriteHeader(http.StatusCreated)
+					io.WriteString(w, `{"id": 99}`)
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+				}
+			}))
+
+			err := upsertStickyComment(context.Background(), conn, client, pullRequestEvent("synchronize", "new"), record, "Current roast")
+			if err != nil {
+				t.Fatalf("upsertStickyComment() error = %v", err)
+			}
+			if created != tt.created {
+				t.Errorf("created a comment = %v, want %v", created, tt.created)
+			}
+			if !strings.HasPrefix(body, roast.StickyMarker+"\nCurrent roast") {
+				t.Errorf("comment body = %q, want the marked current roast", body)
+			}
+			if tt.previous != 0 && !strings.Contains(body, "Earlier roast") {
+				t.Errorf("comment body = %q, want the earlier roast in the history", body)
+			}
+			var saved db.AiRoast
+			conn.First(&saved, record.ID)
+			if record.CommentID != tt.want || saved.CommentID != tt.want {
+				t.Errorf("comment ID = %d, saved %d, want %d", record.CommentID, saved.CommentID, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertPullRequest(t *testing.T) {
+	conn := testDB(t, &db.Repository{}, &db.PullRequest{})
+	opened := pullRequestEvent("opened", "first")
+	opened.PullRequest.Title = github.Ptr("Add a feature")
+	opened.PullRequest.State = github.Ptr("open")
+	opened.PullRequest.User = &github.User{ID: github.Ptr(int64(5)), Login: github.Ptr("author")}
+
+	pullRequest, err := upsertPullRequest(conn, opened)
+	if err != nil {
+		t.Fatalf("upsertPullRequest() error = %v", err)
+	}
+	if pullRequest.ID == 0 || pullRequest.State != db.PullRequestOpen || pullRequest.AuthorLogin != "author" || pullRequest.HeadSHA != "first" {
+		t.Errorf("upsertPullRequest() = %+v, want the opened pull request", pullRequest)
+	}
+	// Set by slash commands, which the webhook knows nothing about
+	if err := conn.Model(pullRequest).Update("ignored", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	merged := pullRequestEvent("closed", "second")
+	merged.PullRequest.Title = github.Ptr("Add a better feature")
+	merged.PullRequest.State = github.Ptr("closed")
+	merged.PullRequest.Merged = github.Ptr(true)
+	merged.PullRequest.ClosedAt = &github.Timestamp{Time: time.Now()}
+	merged.PullRequest.MergedAt = merged.PullRequest.ClosedAt
+
+	updated, err := upsertPullRequest(conn, merged)
+	if err != nil {
+		t.Fatalf("upsertPullRequest() error = %v", err)
+	}
+	if updated.ID != pullRequest.ID {
+		t.Errorf("upsertPullRequest() ID = %d, want the existing %d", updated.ID, pullRequest.ID)
+	}
+	if updated.State != db.PullRequestMerged || updated.MergedAt == nil || updated.ClosedAt == nil {
+		t.Errorf("upsertPullRequest() = %+v, want it merged", updated)
+	}
+	if updated.Title != "Add a better feature" || updated.HeadSHA != "second" {
+		t.Errorf("upsertPullRequest() = %+v, want the new title and head", updated)
+	}
+	if !updated.Ignored {
+		t.Error("upsertPullRequest() cleared a setting made with a slash command")
+	}
+	var count int64
+	conn.Model(&db.PullRequest{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d pull requests saved, want 1", count)
+	}
+}
@@ -54,15 +54,33 @@ func HandleRepositoryEvent(w http.ResponseWriter, body github.RepositoryEvent) {
 func handleRepositoryDeleted(conn *gorm.DB, repository *github.Repository) error {
 	repoID := repository.GetID()
 
-	if err := conn.Where(&db.Repository{ID: repoID}).
+	if err := conn.Where("roast_id IN (?)", conn.Model(&db.AiRoast{}).Select("id").Where(&db.AiRoast{RepoID: repoID})).
+		Delete(&db.RoastFinding{}).Error; err != nil {
+		log.Printf("failed to delete roast findings for repository %d: %v", repoID, err)
+		return err
+	}
+
+	if err := conn.Where(&db.ConversationTurn{RepoID: repoID}).
+		Delete(&db.ConversationTurn{}).Error; err != nil {
+		log.Printf("failed to delete conversations for repository %d: %v", repoID, err)
+		return err
+	}
+
+	if err := conn.Where(&db.AiRoast{RepoID: repoID}).
 		Delete(&db.AiRoast{}).Error; err != nil {
-		log.Printf("failed to delete AI roasts for repository %d: %w", repoID, err)
+		log.Printf("failed to delete AI roasts for repository %d: %v", repoID, err)
+		return err
+	}
+
+	if err := conn.Where(&db.PullRequest{RepoID: repoID}).
+		Delete(&db.PullRequest{}).Error; err != nil {
+		log.Printf("failed to delete pull requests for repository %d: %v", repoID, err)
 		return err
 	}
 
 	if err := conn.Where(&db.Repository{ID: repoID}).
 		Delete(&db.Repository{}).Error; err != nil {
-		log.Printf("failed to delete repository %d: %w", repoID, err)
+		log.Printf("failed to delete repository %d: %v", repoID, err)
 		return err
 	}
 
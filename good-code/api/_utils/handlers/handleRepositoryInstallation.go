@@ -51,10 +51,11 @@ func handleRepositoryAdded(w http.ResponseWriter, body github.InstallationReposi
 			continue
 		}
 		err = conn.Create(&db.Repository{
-			ID:      fullRepo.GetID(),
-			Name:    fullRepo.GetName(),
-			Owner:   fullRepo.GetOwner().GetLogin(),
-			OwnerID: fullRepo.GetOwner().GetID(),
+			ID:             fullRepo.GetID(),
+			Name:           fullRepo.GetName(),
+			Owner:          fullRepo.GetOwner().GetLogin(),
+			OwnerID:        fullRepo.GetOwner().GetID(),
+			InstallationID: installationId,
 		}).Error
 		if err != nil {
 			http.Error(w, "Failed to add repository to database", http.StatusInternalServerError)
@@ -69,7 +70,7 @@ func handleRepositoryAdded(w http.ResponseWriter, body github.InstallationReposi
 		for _, collaborator := range collaborators {
 			var userLoginID *int64
 			var userLogin db.UserLogin
-			err = conn.Where(&db.UserLogin{GithubID: collaborator.GetID()}).First(&userLogin).Error
+			err = conn.Where(&db.UserLogin{GithubID: github.Ptr(collaborator.GetID())}).First(&userLogin).Error
 			if gorm.ErrRecordNotFound == err {
 				log.Printf("User login not found for collaborator %s in repo %s", collaborator.GetLogin(), repo.GetFullName())
 				userLoginID = nil
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+	usage "github.com/chopstickleg/good-code/api/_utils/usage"
+
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+// HandlePullRequestReviewCommentEvent queues replies to inline comments so
+// the ones answering GoodCode's findings get an answer.
+func HandlePullRequestReviewCommentEvent(w http.ResponseWriter, body github.PullRequestReviewCommentEvent) {
+	if body.GetAction() != "created" || body.GetComment().GetInReplyTo() == 0 || body.GetSender().GetType() == "Bot" {
+		log.Printf("Ignoring review comment event: %s", body.GetAction())
+		return
+	}
+
+	conn, err := db.GetDB()
+	if err != nil {
+		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		log.Printf("Failed to connect to database: %v", err)
+		return
+	}
+	enqueue(w, conn, JobReviewComment, body)
+}
+
+// answerReviewComment answers replies to the inline comments GoodCode left
+// for its findings, with the finding, the diff hunk and the thread so far as
+// context.
+func answerReviewComment(ctx context.Context, conn *gorm.DB, body *github.PullRequestReviewCommentEvent) error {
+	comment := body.GetComment()
+	if !mayConverse(comment.GetAuthorAssociation(), comment.GetUser(), body.GetPullRequest().GetUser()) {
+		log.Printf("Not answering %s (%s) on PR #%d in %s", comment.GetUser().GetLogin(), comment.GetAuthorAssociation(), body.GetPullRequest().GetNumber(), body.GetRepo().GetFullName())
+		return nil
+	}
+
+	// Replies always point at the first comment of the thread
+	threadID := comment.GetInReplyTo()
+	var finding db.RoastFinding
+	err := conn.Where(&db.RoastFinding{GithubCommentID: threadID}).First(&finding).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up finding for comment %d: %w", threadID, err)
+	}
+
+	var history []db.ConversationTurn
+	err = conn.
+		Where(&db.ConversationTurn{ThreadID: threadID}).
+		Order("created_at ASC").
+		Find(&history).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to load thread %d: %w", threadID, err)
+	}
+	log.Printf("Answering %s in thread %d on PR #%d in %s", body.GetSender().GetLogin(), threadID, body.GetPullRequest().GetNumber(), body.GetRepo().GetFullName())
+
+	client, err := getAuthedClient(body.GetInstallation().GetID())
+	if err != nil {
+		return fmt.Errorf("failed to get authenticated GitHub client: %w", err)
+	}
+	var pullRequest db.PullRequest
+	err = conn.Where(&db.PullRequest{RepoID: body.GetRepo().GetID(), Number: body.GetPullRequest().GetNumber()}).First(&pullRequest).Error
+	if err == gorm.ErrRecordNotFound {
+		pullRequest = db.PullRequest{Number: body.GetPullRequest().GetNumber(), AuthorGithubID: body.GetPullRequest().GetUser().GetID()}
+	} else if err != nil {
+		return fmt.Errorf("failed to load PR #%d in %s: %w", body.GetPullRequest().GetNumber(), body.GetRepo().GetFullName(), err)
+	}
+	engine, err := newEngine(ctx, conn, client, body.GetRepo(), body.GetPullRequest().GetBase().GetRef(), pullRequest)
+	if err != nil {
+		return fmt.Errorf("failed to set up AI provider for PR #%d: %w", body.GetPullRequest().GetNumber(), err)
+	}
+
+	original := toFinding(finding)
+	userTurn := db.ConversationTurn{
+		RoastID:           finding.RoastID,
+		RepoID:            body.GetRepo().GetID(),
+		PullRequestNumber: body.GetPullRequest().GetNumber(),
+		FindingID:         &finding.ID,
+		ThreadID:          threadID,
+		GithubCommentID:   comment.GetID(),
+		Role:              roast.RoleUser,
+		AuthorLogin:       body.GetSender().GetLogin(),
+		Body:              comment.GetBody(),
+	}
+	reservation, err := reserveCall(ctx, conn, body.GetInstallation(), fmt.Sprintf("answer:%d", comment.GetID()))
+	if errors.Is(err, quota.ErrExhausted) {
+		log.Printf("Installation %d is out of quota, not answering comment %d", body.GetInstallation().GetID(), comment.GetID())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := engine.Answer(ctx, roast.FollowUp{
+		Finding:  &original,
+		DiffHunk: comment.GetDiffHunk(),
+		History:  toTurns(history),
+		Question: roast.Turn{Role: roast.RoleUser, Author: userTurn.AuthorLogin, Body: userTurn.Body},
+	})
+	if resp != nil {
+		reservation.Record(conn, resp.Usage.TotalTokens)
+		usage.RecordCall(conn, db.ModelCall{
+			Kind:              db.ModelCallAnswer,
+			RepoID:            userTurn.RepoID,
+			PullRequestNumber: userTurn.PullRequestNumber,
+			InstallationID:    body.GetInstallation().GetID(),
+			Provider:          engine.Provider.Name(),
+		}, resp)
+	}
+	if err != nil {
+		reservation.Release(conn)
+		return fmt.Errorf("failed to answer comment %d on PR #%d: %w", comment.GetID(), body.GetPullRequest().GetNumber(), err)
+	}
+	answer := resp.Text
+
+	posted, _, err := client.PullRequests.CreateCommentInReplyTo(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), body.GetPullRequest().GetNumber(), answer, threadID)
+	if err != nil {
+		return fmt.Errorf("failed to reply in thread %d on PR #%d: %w", threadID, body.GetPullRequest().GetNumber(), err)
+	}
+	saveTurns(conn, userTurn, db.ConversationTurn{
+		RoastID:           finding.RoastID,
+		RepoID:            userTurn.RepoID,
+		PullRequestNumber: userTurn.PullRequestNumber,
+		FindingID:         &finding.ID,
+		ThreadID:          threadID,
+		GithubCommentID:   posted.GetID(),
+		Role:              roast.RoleAssistant,
+		Body:              answer,
+		Model:             resp.Model,
+	})
+	return nil
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"github.com/google/go-github/v72/github"
+)
+
+// pullRequestIntent collects what the pull request says it does, including
+// the issues it closes. Issues that cannot be read are left out.
+func pullRequestIntent(ctx context.Context, client *github.Client, body *github.PullRequestEvent) roast.Intent {
+	pullRequest := body.GetPullRequest()
+	intent := roast.Intent{Title: pullRequest.GetTitle(), Body: pullRequest.GetBody()}
+	for _, number := range roast.LinkedIssueNumbers(pullRequest.GetBody()) {
+		issue, _, err := client.Issues.Get(ctx, body.GetRepo().GetOwner().GetLogin(), body.GetRepo().GetName(), number)
+		if err != nil {
+			log.Printf("Failed to fetch issue #%d linked from PR #%d: %v", number, body.GetNumber(), err)
+			continue
+		}
+		intent.Issues = append(intent.Issues, roast.LinkedIssue{
+			Number: number,
+			Title:  issue.GetTitle(),
+			Body:   issue.GetBody(),
+		})
+	}
+	return intent
+}
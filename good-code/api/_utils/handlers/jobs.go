@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	jobs "github.com/chopstickleg/good-code/api/_utils/jobs"
+
+	"github.com/google/go-github/v72/github"
+	"gorm.io/gorm"
+)
+
+// Job kinds for webhook work too slow to do inside GitHub's delivery timeout.
+// Their payloads are the webhook events themselves.
+const (
+	JobRoastPullRequest = "roast_pull_request"
+	JobRerunCheck       = "rerun_check"
+	JobIssueComment     = "issue_comment"
+	JobReviewComment    = "review_comment"
+	JobSyncInstallation = "sync_installation"
+)
+
+func init() {
+	jobs.Register(JobRoastPullRequest, eventJob(runRoastJob))
+	jobs.Register(JobRerunCheck, eventJob(rerunCheck))
+	jobs.Register(JobIssueComment, eventJob(handleIssueComment))
+	jobs.Register(JobReviewComment, eventJob(answerReviewComment))
+	jobs.Register(JobSyncInstallation, eventJob(syncInstallation))
+}
+
+// eventJob decodes the webhook event a job carries before running it.
+func eventJob[E any](run func(ctx context.Context, conn *gorm.DB, event *E) error) jobs.Handler {
+	return func(ctx context.Context, conn *gorm.DB, payload json.RawMessage) error {
+		var event E
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return jobs.Permanent(fmt.Errorf("failed to decode payload: %w", err))
+		}
+		return run(ctx, conn, &event)
+	}
+}
+
+// enqueue hands the event over to the worker and tells GitHub it was accepted.
+func enqueue(w http.ResponseWriter, conn *gorm.DB, kind string, event any) {
+	job, err := jobs.Enqueue(conn, kind, event)
+	if err != nil {
+		log.Printf("Failed to enqueue %s job: %v", kind, err)
+		http.Error(w, "Failed to queue event", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Queued %s job %d", kind, job.ID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// reservationKey ties a quota reservation to the running job, so a retry of the
+// job reuses it instead of counting against the quota again. Outside a job
+// every call reserves afresh.
+func reservationKey(ctx context.Context, purpose string) string {
+	job, ok := jobs.Current(ctx)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("job:%d:%s", job.ID, purpose)
+}
+
+func runRoastJob(ctx context.Context, conn *gorm.DB, body *github.PullRequestEvent) error {
+	var pullRequest db.PullRequest
+	err := conn.Where(&db.PullRequest{RepoID: body.GetRepo().GetID(), Number: body.GetNumber()}).First(&pullRequest).Error
+	if err == gorm.ErrRecordNotFound {
+		recorded, err := upsertPullRequest(conn, body)
+		if err != nil {
+			return err
+		}
+		pullRequest = *recorded
+	} else if err != nil {
+		return fmt.Errorf("failed to load pull request: %w", err)
+	}
+	if pullRequest.State != db.PullRequestOpen {
+		log.Printf("PR #%d in %s is %s by now, not roasting it", body.GetNumber(), body.GetRepo().GetFullName(), pullRequest.State)
+		return nil
+	}
+	_, err = roastPullRequest(ctx, conn, body, &pullRequest)
+	return err
+}
+
+func syncInstallation(ctx context.Context, conn *gorm.DB, body *github.InstallationEvent) error {
+	return HandleAppCreated(ctx, conn, body.GetInstallation(), body.Repositories)
+}
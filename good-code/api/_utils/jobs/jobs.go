@@ -0,0 +1,244 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	// Lease is how long a job may run before another worker assumes the one
+	// running it died and claims it again. It only has to outlast the worker
+	// function's maxDuration in vercel.json.
+	Lease = 6 * time.Minute
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Handler runs a single job. Returning an error retries the job later unless
+// the error is wrapped with Permanent.
+type Handler func(ctx context.Context, conn *gorm.DB, payload json.RawMessage) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]Handler{}
+)
+
+// Register makes a job kind runnable. Packages register their kinds from init
+// so every worker that imports them can run them.
+func Register(kind string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := handlers[kind]; exists {
+		panic(fmt.Sprintf("jobs: kind %q registered twice", kind))
+	}
+	handlers[kind] = handler
+}
+
+func lookup(kind string) (Handler, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	handler, ok := handlers[kind]
+	return handler, ok
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying will not fix, such as a malformed
+// payload, so the job goes straight to the dead-letter state.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Enqueue stores a job to be run as soon as a worker is free.
+func Enqueue(conn *gorm.DB, kind string, payload any) (*db.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", kind, err)
+	}
+	job := db.Job{
+		Kind:        kind,
+		Payload:     db.JSON(encoded),
+		Status:      db.JobQueued,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := conn.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+	return &job, nil
+}
+
+// Claim locks up to limit jobs that are due, skipping rows other workers hold,
+// and marks them as running. Jobs whose lease ran out are claimed again.
+func Claim(conn *gorm.DB, worker string, limit int) ([]db.Job, error) {
+	var claimed []db.Job
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)", db.JobQueued, now, db.JobRunning, now.Add(-Lease)).
+			Order("run_at ASC").
+			Limit(limit).
+			Find(&claimed).
+			Error
+		if err != nil {
+			return err
+		}
+		for i := range claimed {
+			claimed[i].Status = db.JobRunning
+			claimed[i].Attempts++
+			claimed[i].LockedAt = &now
+			claimed[i].LockedBy = worker
+			err := tx.Model(&db.Job{}).
+				Where(&db.Job{ID: claimed[i].ID}).
+				Updates(map[string]any{
+					"status":    db.JobRunning,
+					"attempts":  claimed[i].Attempts,
+					"locked_at": now,
+					"locked_by": worker,
+				}).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+	return claimed, nil
+}
+
+// Run executes a claimed job and records the outcome: succeeded, queued again
+// with exponential backoff, or dead once it runs out of attempts.
+func Run(ctx context.Context, conn *gorm.DB, job *db.Job) error {
+	handler, ok := lookup(job.Kind)
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("unknown job kind %q", job.Kind))
+	} else {
+		err = runSafely(ctx, conn, handler, job)
+	}
+
+	now := time.Now()
+	updates := map[string]any{"locked_at": nil, "locked_by": ""}
+	switch {
+	case err == nil:
+		updates["status"] = db.JobSucceeded
+		updates["completed_at"] = now
+		updates["last_error"] = ""
+	case errors.As(err, &permanentError{}) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed for good after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+		updates["status"] = db.JobDead
+		updates["completed_at"] = now
+		updates["last_error"] = err.Error()
+	default:
+		retryAt := now.Add(Backoff(job.Attempts))
+		log.Printf("Job %d (%s) failed on attempt %d, retrying at %s: %v", job.ID, job.Kind, job.Attempts, retryAt.Format(time.RFC3339), err)
+		updates["status"] = db.JobQueued
+		updates["run_at"] = retryAt
+		updates["last_error"] = err.Error()
+	}
+	if dbErr := conn.Model(&db.Job{}).Where(&db.Job{ID: job.ID}).Updates(updates).Error; dbErr != nil {
+		return fmt.Errorf("failed to record outcome of job %d: %w", job.ID, dbErr)
+	}
+	return err
+}
+
+func runSafely(ctx context.Context, conn *gorm.DB, handler Handler, job *db.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(context.WithValue(ctx, jobKey{}, job), conn, json.RawMessage(job.Payload))
+}
+
+type jobKey struct{}
+
+// Current returns the job a handler is running for, so work it already did on
+// an earlier attempt can be recognised and not done twice.
+func Current(ctx context.Context) (*db.Job, bool) {
+	job, ok := ctx.Value(jobKey{}).(*db.Job)
+	return job, ok
+}
+
+// Backoff is the delay before the next attempt of a job that failed on the
+// given attempt: doubling from 30 seconds up to an hour, with some jitter so
+// jobs that failed together do not retry together.
+func Backoff(attempt int) time.Duration {
+	delay := maxBackoff
+	if attempt < 8 {
+		delay = min(baseBackoff<<max(attempt-1, 0), maxBackoff)
+	}
+	return delay + rand.N(delay/4+1)
+}
+
+// Work claims and runs jobs until the queue is empty or the deadline passes,
+// returning how many jobs it ran.
+func Work(ctx context.Context, conn *gorm.DB, worker string, deadline time.Time) (int, error) {
+	ran := 0
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		claimed, err := Claim(conn, worker, 1)
+		if err != nil {
+			return ran, err
+		}
+		if len(claimed) == 0 {
+			return ran, nil
+		}
+		for i := range claimed {
+			log.Printf("Running job %d (%s), attempt %d of %d", claimed[i].ID, claimed[i].Kind, claimed[i].Attempts, claimed[i].MaxAttempts)
+			if err := Run(ctx, conn, &claimed[i]); err != nil {
+				log.Printf("Job %d (%s) failed: %v", claimed[i].ID, claimed[i].Kind, err)
+			}
+			ran++
+		}
+	}
+	return ran, nil
+}
+
+// WorkerID names this process in the locked_by column.
+func WorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Retry puts a job back in the queue with a fresh set of attempts and no
+// error left over from the ones before.
+func Retry(conn *gorm.DB, id int64) error {
+	return conn.Model(&db.Job{}).
+		Where(&db.Job{ID: id}).
+		Updates(map[string]any{
+			"status":       db.JobQueued,
+			"attempts":     0,
+			"last_error":   "",
+			"run_at":       time.Now(),
+			"locked_at":    nil,
+			"locked_by":    "",
+			"completed_at": nil,
+		}).
+		Error
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&db.Job{}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// outcomes is what the test job kind returns, by payload.
+var outcomes = map[string]error{
+	"ok":        nil,
+	"flaky":     errors.New("try again"),
+	"malformed": Permanent(errors.New("bad payload")),
+}
+
+func init() {
+	Register("test", func(ctx context.Context, conn *gorm.DB, payload json.RawMessage) error {
+		var outcome string
+		if err := json.Unmarshal(payload, &outcome); err != nil {
+			return err
+		}
+		if outcome == "panic" {
+			panic("boom")
+		}
+		if job, ok := Current(ctx); !ok || job == nil {
+			return errors.New("job missing from context")
+		}
+		return outcomes[outcome]
+	})
+}
+
+// claimOne claims the job with the given ID, which must be due.
+func claimOne(t *testing.T, conn *gorm.DB, id int64) *db.Job {
+	t.Helper()
+	claimed, err := Claim(conn, "test-worker", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range claimed {
+		if claimed[i].ID == id {
+			return &claimed[i]
+		}
+	}
+	t.Fatalf("job %d not claimed", id)
+	return nil
+}
+
+func load(t *testing.T, conn *gorm.DB, id int64) db.Job {
+	t.Helper()
+	var job db.Job
+	if err := conn.First(&job, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		maxAttempts int
+		status      string
+		lastError   string
+	}{
+		{"succeeded", "ok", DefaultMaxAttempts, db.JobSucceeded, ""},
+		{"retried", "flaky", DefaultMaxAttempts, db.JobQueued, "try again"},
+		{"out of attempts", "flaky", 1, db.JobDead, "try again"},
+		{"permanent", "malformed", DefaultMaxAttempts, db.JobDead, "bad payload"},
+		{"panicked", "panic", DefaultMaxAttempts, db.JobQueued, "job panicked: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testDB(t)
+			job, err := Enqueue(conn, "test", tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Model(job).Update("max_attempts", tt.maxAttempts)
+
+			claimed := claimOne(t, conn, job.ID)
+			if claimed.Status != db.JobRunning || claimed.Attempts != 1 || claimed.LockedBy != "test-worker" {
+				t.Errorf("claimed job = %+v, want it running on its first attempt", claimed)
+			}
+			err = Run(context.Background(), conn, claimed)
+			if (err == nil) != (tt.lastError == "") {
+				t.Errorf("Run() error = %v", err)
+			}
+
+			stored := load(t, conn, job.ID)
+			if stored.Status != tt.status || stored.LastError != tt.lastError || stored.LockedBy != "" || stored.LockedAt != nil {
+				t.Errorf("job = %+v, want %s with last error %q", stored, tt.status, tt.lastError)
+			}
+			if tt.status == db.JobQueued && !stored.RunAt.After(time.Now()) {
+				t.Errorf("retry due at %s, want it backed off", stored.RunAt)
+			}
+		})
+	}
+}
+
+func TestRunUnknownKind(t *testing.T) {
+	conn := testDB(t)
+	job, err := Enqueue(conn, "no such kind", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(context.Background(), conn, claimOne(t, conn, job.ID)); err == nil {
+		t.Error("Run() ran a job of an unknown kind")
+	}
+	if stored := load(t, conn, job.ID); stored.Status != db.JobDead {
+		t.Errorf("job status = %s, want dead", stored.Status)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	conn := testDB(t)
+	job, err := Enqueue(conn, "test", "malformed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run(context.Background(), conn, claimOne(t, conn, job.ID))
+	if dead := load(t, conn, job.ID); dead.Status != db.JobDead || dead.LastError == "" || dead.Attempts != 1 {
+		t.Fatalf("job = %+v, want it dead after one attempt", dead)
+	}
+
+	if err := Retry(conn, job.ID); err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	retried := load(t, conn, job.ID)
+	if retried.Status != db.JobQueued || retried.Attempts != 0 || retried.LastError != "" || retried.CompletedAt != nil || retried.RunAt.After(time.Now()) {
+		t.Errorf("job = %+v, want it queued afresh", retried)
+	}
+	if claimed := claimOne(t, conn, job.ID); claimed.Attempts != 1 {
+		t.Errorf("attempts = %d on the first run after the retry, want 1", claimed.Attempts)
+	}
+}
+
+func TestClaimSkipsJobsNotDue(t *testing.T) {
+	conn := testDB(t)
+	later, err := Enqueue(conn, "test", "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Model(later).Update("run_at", time.Now().Add(time.Hour))
+	stale, err := Enqueue(conn, "test", "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A worker that died mid-job long ago
+	conn.Model(stale).Updates(map[string]any{"status": db.JobRunning, "locked_at": time.Now().Add(-2 * Lease), "locked_by": "gone"})
+
+	claimed, err := Claim(conn, "test-worker", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != stale.ID || claimed[0].LockedBy != "test-worker" {
+		t.Errorf("Claim() = %+v, want only the job whose lease ran out", claimed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := Backoff(tt.attempt); got < tt.base || got > tt.base+tt.base/4 {
+				t.Errorf("Backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.base, tt.base+tt.base/4)
+			}
+		}
+	}
+}
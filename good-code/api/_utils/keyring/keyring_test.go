@@ -0,0 +1,242 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	secretA = strings.Repeat("a", minSecretLength)
+	secretB = strings.Repeat("b", minSecretLength)
+)
+
+// ed25519PEM returns a fresh Ed25519 key pair, PEM encoded.
+func ed25519PEM(t *testing.T) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func TestNew(t *testing.T) {
+	private, public := ed25519PEM(t)
+	tests := []struct {
+		name    string
+		configs []KeyConfig
+		wantErr string
+	}{
+		{"single secret", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true}}, ""},
+		{"EdDSA signing", []KeyConfig{{ID: "a", Algorithm: AlgEdDSA, PrivateKey: private, Sign: true}}, ""},
+		{"EdDSA verifying", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true}, {ID: "b", Algorithm: AlgEdDSA, PublicKey: public}}, ""},
+		{"short legacy secret", []KeyConfig{{ID: LegacyKeyID, Algorithm: AlgHS256, Secret: "short", Sign: true}}, ""},
+		{"no kid", []KeyConfig{{Algorithm: AlgHS256, Secret: secretA, Sign: true}}, "without a kid"},
+		{"defined twice", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true}, {ID: "a", Algorithm: AlgHS256, Secret: secretB}}, "defined twice"},
+		{"two signing keys", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true}, {ID: "b", Algorithm: AlgHS256, Secret: secretB, Sign: true}}, "both sign"},
+		{"no signing key", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: secretA}}, "no JWT key signs"},
+		{"short secret", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Secret: "short", Sign: true}}, "at least"},
+		{"no secret", []KeyConfig{{ID: "a", Algorithm: AlgHS256, Sign: true}}, "secret not set"},
+		{"unsupported algorithm", []KeyConfig{{ID: "a", Algorithm: "none", Sign: true}}, "unsupported algorithm"},
+		{"no key material", []KeyConfig{{ID: "a", Algorithm: AlgEdDSA, Sign: true}}, "private_key or public_key not set"},
+		{"signing with a public key", []KeyConfig{{ID: "a", Algorithm: AlgEdDSA, PublicKey: public, Sign: true}}, "needs the private key"},
+		{"invalid PEM", []KeyConfig{{ID: "a", Algorithm: AlgEdDSA, PrivateKey: "nonsense", Sign: true}}, "invalid private key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.configs)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("New() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        string
+		secret      string
+		wantSigning string
+		wantKeys    []string
+		wantErr     string
+	}{
+		{"legacy secret only", "", "legacy", LegacyKeyID, []string{LegacyKeyID}, ""},
+		{"keys with legacy secret", `[{"kid": "2026-10", "alg": "HS256", "secret": "` + secretA + `", "sign": true}]`, "legacy", "2026-10", []string{"2026-10", LegacyKeyID}, ""},
+		{"explicit default key", `[{"kid": "default", "alg": "HS256", "secret": "` + secretA + `", "sign": true}]`, "legacy", LegacyKeyID, []string{LegacyKeyID}, ""},
+		{"nothing set", "", "", "", nil, "neither"},
+		{"invalid JSON", `[{"kid": `, "", "", nil, "invalid JWT_KEYS"},
+		{"keys without signer and legacy secret", `[{"kid": "2026-10", "alg": "HS256", "secret": "` + secretA + `"}]`, "legacy", "", nil, "no JWT key signs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_KEYS", tt.keys)
+			t.Setenv("JWT_SECRET_KEY", tt.secret)
+			ring, err := FromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromEnv() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromEnv() error = %v", err)
+			}
+			if ring.signing.ID != tt.wantSigning {
+				t.Errorf("signing key = %q, want %q", ring.signing.ID, tt.wantSigning)
+			}
+			if len(ring.keys) != len(tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", ring.keys, tt.wantKeys)
+			}
+			for _, id := range tt.wantKeys {
+				if _, ok := ring.keys[id]; !ok {
+					t.Errorf("key %q missing", id)
+				}
+			}
+		})
+	}
+}
+
+// claims are valid claims expiring an hour from now.
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{"iss": Issuer, "id": 1, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func mustNew(t *testing.T, configs ...KeyConfig) *Keyring {
+	t.Helper()
+	ring, err := New(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func TestParse(t *testing.T) {
+	private, _ := ed25519PEM(t)
+	ringA := mustNew(t, KeyConfig{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true})
+	ringB := mustNew(t, KeyConfig{ID: "a", Algorithm: AlgHS256, Secret: secretB, Sign: true})
+	signed, err := ringA.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same key", func(t *testing.T) {
+		parsed, err := ringA.Parse(signed)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if parsed["id"] != float64(1) {
+			t.Errorf("Parse() id = %v, want 1", parsed["id"])
+		}
+	})
+
+	t.Run("different key", func(t *testing.T) {
+		if _, err := ringB.Parse(signed); err == nil {
+			t.Fatal("Parse() accepted a token signed with another key")
+		}
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		other := mustNew(t, KeyConfig{ID: "b", Algorithm: AlgHS256, Secret: secretA, Sign: true})
+		if _, err := other.Parse(signed); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+			t.Fatalf("Parse() error = %v, want an unknown key", err)
+		}
+	})
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		// An HS256 token naming an EdDSA key, as if its public key were a secret
+		ring := mustNew(t,
+			KeyConfig{ID: "ed", Algorithm: AlgEdDSA, PrivateKey: private, Sign: true},
+			KeyConfig{ID: "hs", Algorithm: AlgHS256, Secret: secretA},
+		)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+		token.Header["kid"] = "ed"
+		forged, err := token.SignedString([]byte(secretA))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ring.Parse(forged); err == nil || !strings.Contains(err.Error(), "token is signed with HS256") {
+			t.Fatalf("Parse() error = %v, want an algorithm mismatch", err)
+		}
+	})
+
+	t.Run("missing kid falls back to default", func(t *testing.T) {
+		ring := mustNew(t,
+			KeyConfig{ID: "a", Algorithm: AlgHS256, Secret: secretA, Sign: true},
+			KeyConfig{ID: LegacyKeyID, Algorithm: AlgHS256, Secret: "legacy"},
+		)
+		legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte("legacy"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ring.Parse(legacy); err != nil {
+			t.Fatalf("Parse() error = %v, want the default key to verify it", err)
+		}
+
+		unkeyed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte(secretA))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ring.Parse(unkeyed); err == nil {
+			t.Fatal("Parse() verified a token without a kid with a key other than the default")
+		}
+		if _, err := ringA.Parse(legacy); err == nil || !strings.Contains(err.Error(), `unknown signing key "default"`) {
+			t.Fatalf("Parse() error = %v, want the default key to be missing", err)
+		}
+	})
+
+	t.Run("missing exp", func(t *testing.T) {
+		unbounded := claims()
+		delete(unbounded, "exp")
+		signed, err := ringA.Sign(unbounded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ringA.Parse(signed); err == nil || !strings.Contains(err.Error(), "does not expire") {
+			t.Fatalf("Parse() error = %v, want a missing expiry", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := claims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		signed, err := ringA.Sign(expired)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ringA.Parse(signed); err == nil {
+			t.Fatal("Parse() accepted an expired token")
+		}
+	})
+
+	t.Run("other issuer", func(t *testing.T) {
+		foreign := claims()
+		foreign["iss"] = "example.com"
+		signed, err := ringA.Sign(foreign)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ringA.Parse(signed); err == nil || !strings.Contains(err.Error(), "not issued by us") {
+			t.Fatalf("Parse() error = %v, want a foreign issuer", err)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+)
+
+const defaultFakeModel = "fake-reviewer"
+
+// fakeProvider never leaves the process and always returns the same output
+// for the same input, which makes it useful for local development and
+// exercising the webhook flow without spending tokens.
+type fakeProvider struct {
+	cfg Config
+}
+
+func newFakeProvider(cfg Config) *fakeProvider {
+	if cfg.Model == "" {
+		cfg.Model = defaultFakeModel
+	}
+	return &fakeProvider{cfg: cfg}
+}
+
+func (p *fakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *fakeProvider) Model() string {
+	return p.cfg.Model
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	sum := sha256.Sum256([]byte(req.SystemInstruction + "\n" + req.Prompt))
+	text := fmt.Sprintf("Fake review of %d bytes of input (digest %x). Looks fine, I guess.", len(req.Prompt), sum[:8])
+	if req.JSON {
+		encoded, err := json.Marshal(map[string]any{
+			"summary":  text,
+			"findings": []any{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		text = string(encoded)
+	}
+	usage := Usage{
+		PromptTokens:    int64(diff.EstimateTokens(req.SystemInstruction + req.Prompt)),
+		CandidateTokens: int64(diff.EstimateTokens(text)),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CandidateTokens
+	return &Response{Text: text, Model: p.cfg.Model, Usage: usage}, nil
+}
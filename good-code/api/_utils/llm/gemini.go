@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+type geminiProvider struct {
+	cfg Config
+}
+
+func newGeminiProvider(cfg Config) (*geminiProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("AI_GEMINI_API_TOKEN or AI_API_TOKEN must be set for the gemini provider")
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultGeminiModel
+	}
+	return &geminiProvider{cfg: cfg}, nil
+}
+
+func (p *geminiProvider) Name() string {
+	return ProviderGemini
+}
+
+func (p *geminiProvider) Model() string {
+	return p.cfg.Model
+}
+
+func (p *geminiProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  p.cfg.APIKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %w", err)
+	}
+
+	config := genai.GenerateContentConfig{
+		Temperature:     p.cfg.Temperature,
+		TopP:            p.cfg.TopP,
+		MaxOutputTokens: p.cfg.MaxOutputTokens,
+	}
+	if req.JSON {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = req.Schema.toGenai()
+	}
+	if req.SystemInstruction != "" {
+		config.SystemInstruction = genai.NewContentFromText(req.SystemInstruction, genai.RoleModel)
+	}
+
+	started := time.Now()
+	result, err := client.Models.GenerateContent(ctx, p.cfg.Model, genai.Text(req.Prompt), &config)
+	if err != nil {
+		return nil, fmt.Errorf("gemini request failed: %w", err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("gemini returned no result")
+	}
+
+	model := result.ModelVersion
+	if model == "" {
+		model = p.cfg.Model
+	}
+	usage := Usage{Latency: time.Since(started)}
+	if meta := result.UsageMetadata; meta != nil {
+		usage.PromptTokens = int64(meta.PromptTokenCount)
+		// Thinking tokens are billed as output
+		usage.CandidateTokens = int64(meta.CandidatesTokenCount) + int64(meta.ThoughtsTokenCount)
+		usage.TotalTokens = int64(meta.TotalTokenCount)
+	}
+	return &Response{Text: result.Text(), Model: model, Usage: usage}, nil
+}
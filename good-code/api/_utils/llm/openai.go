@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// openAIProvider talks to any server implementing the OpenAI chat completions
+// API, which covers OpenAI itself as well as self-hosted Ollama and vLLM.
+type openAIProvider struct {
+	cfg    Config
+	client *http.Client
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature *float32        `json:"temperature,omitempty"`
+	TopP        *float32        `json:"top_p,omitempty"`
+	MaxTokens   int32           `json:"max_tokens,omitempty"`
+
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string  `json:"name"`
+	Schema *Schema `json:"schema"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func newOpenAIProvider(cfg Config) (*openAIProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("AI_OPENAI_BASE_URL or AI_BASE_URL must be set for the openai provider")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("AI_MODEL must be set for the openai provider")
+	}
+	return &openAIProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (p *openAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+func (p *openAIProvider) Model() string {
+	return p.cfg.Model
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, req Request) (*Response, error) {
+	messages := []openAIMessage{}
+	if req.SystemInstruction != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: req.SystemInstruction})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: req.Prompt})
+
+	chatReq := openAIChatRequest{
+		Model:       p.cfg.Model,
+		Messages:    messages,
+		Temperature: p.cfg.Temperature,
+		TopP:        p.cfg.TopP,
+		MaxTokens:   p.cfg.MaxOutputTokens,
+	}
+	if req.JSON && req.Schema != nil {
+		chatReq.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: "response", Schema: req.Schema},
+		}
+	} else if req.JSON {
+		chatReq.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+
+	payload, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chat completion request: %w", err)
+	}
+
+	url := strings.TrimRight(p.cfg.BaseURL, "/") + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build chat completion request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	started := time.Now()
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat completion response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completion request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed openAIChatResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode chat completion response: %w", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, fmt.Errorf("chat completion response has no choices")
+	}
+
+	model := parsed.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+	usage := Usage{
+		PromptTokens:    parsed.Usage.PromptTokens,
+		CandidateTokens: parsed.Usage.CompletionTokens,
+		TotalTokens:     parsed.Usage.TotalTokens,
+		Latency:         time.Since(started),
+	}
+	return &Response{Text: parsed.Choices[0].Message.Content, Model: model, Usage: usage}, nil
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIGenerate(t *testing.T) {
+	var got openAIChatRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"model": "llama3:8b", "choices": [{"message": {"role": "assistant", "content": "{\"summary\": \"Meh\"}"}}], "usage": {"prompt_tokens": 12, "completion_tokens": 5, "total_tokens": 17}}`)
+	}))
+	defer server.Close()
+
+	temperature := float32(0.3)
+	provider, err := NewProvider(Config{Provider: ProviderOpenAI, BaseURL: server.URL + "/v1/", Model: "llama3", APIKey: "secret", Temperature: &temperature})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{"summary": {Type: "string"}}}
+	resp, err := provider.Generate(context.Background(), Request{SystemInstruction: "Be harsh", Prompt: "the diff", JSON: true, Schema: schema})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got.Model != "llama3" || len(got.Messages) != 2 || got.Messages[0] != (openAIMessage{Role: "system", Content: "Be harsh"}) || got.Messages[1] != (openAIMessage{Role: "user", Content: "the diff"}) {
+		t.Errorf("request = %+v", got)
+	}
+	if got.Temperature == nil || *got.Temperature != temperature || got.TopP != nil {
+		t.Errorf("sampling = %v, %v", got.Temperature, got.TopP)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_schema" || got.ResponseFormat.JSONSchema.Schema.Type != "object" {
+		t.Errorf("response_format = %+v, want the schema", got.ResponseFormat)
+	}
+	if resp.Text != `{"summary": "Meh"}` || resp.Model != "llama3:8b" {
+		t.Errorf("Generate() = %+v", resp)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CandidateTokens != 5 || resp.Usage.TotalTokens != 17 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusTooManyRequests, `{"error": "slow down"}`, `status 429: {"error": "slow down"}`},
+		{"not JSON", http.StatusOK, `<html>`, "failed to decode chat completion response"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "has no choices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+			provider, err := NewProvider(Config{Provider: ProviderOpenAI, BaseURL: server.URL, Model: "llama3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.Generate(context.Background(), Request{Prompt: "diff"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Generate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		provider, err := NewProvider(Config{Provider: ProviderOpenAI, BaseURL: "http://localhost:1", Model: "llama3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.Generate(ctx, Request{Prompt: "diff"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Generate() error = %v, want it to wrap context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// Price is what a model charges in US dollars per million tokens.
+type Price struct {
+	InputPerMillion  float64 `json:"input"`
+	OutputPerMillion float64 `json:"output"`
+}
+
+// Pricing maps model names to prices. A model matches the longest entry its
+// name starts with, so dated and preview versions share their family's price.
+type Pricing map[string]Price
+
+var defaultPricing = Pricing{
+	"gemini-2.5-pro":        {InputPerMillion: 1.25, OutputPerMillion: 10},
+	"gemini-2.5-flash":      {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.0-flash":      {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.0-flash-lite": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gpt-4o":                {InputPerMillion: 2.50, OutputPerMillion: 10},
+	"gpt-4o-mini":           {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	"gpt-4.1":               {InputPerMillion: 2, OutputPerMillion: 8},
+	"gpt-4.1-mini":          {InputPerMillion: 0.40, OutputPerMillion: 1.60},
+	ProviderFake:            {},
+}
+
+// PricingFromEnv returns the built-in prices with any set in AI_PRICING, a
+// JSON object such as {"my-model": {"input": 0.5, "output": 1.5}}, on top.
+func PricingFromEnv() Pricing {
+	pricing := Pricing{}
+	for model, price := range defaultPricing {
+		pricing[model] = price
+	}
+	v := os.Getenv("AI_PRICING")
+	if v == "" {
+		return pricing
+	}
+	var overrides Pricing
+	if err := json.Unmarshal([]byte(v), &overrides); err != nil {
+		log.Printf("Ignoring invalid AI_PRICING: %v", err)
+		return pricing
+	}
+	for model, price := range overrides {
+		pricing[model] = price
+	}
+	return pricing
+}
+
+// Cost estimates what the usage cost in US dollars. It reports false when the
+// model has no price.
+func (p Pricing) Cost(model string, usage Usage) (float64, bool) {
+	match := ""
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			match = name
+		}
+	}
+	if match == "" {
+		return 0, false
+	}
+	price := p[match]
+	// Whatever is not prompt is billed as output, which includes thinking
+	output := usage.TotalTokens - usage.PromptTokens
+	if output < usage.CandidateTokens {
+		output = usage.CandidateTokens
+	}
+	return (float64(usage.PromptTokens)*price.InputPerMillion + float64(output)*price.OutputPerMillion) / 1e6, true
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCost(t *testing.T) {
+	pricing := Pricing{
+		"gpt-4o":      {InputPerMillion: 2.50, OutputPerMillion: 10},
+		"gpt-4o-mini": {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	}
+	tests := []struct {
+		name  string
+		model string
+		usage Usage
+		want  float64
+		ok    bool
+	}{
+		{"exact match", "gpt-4o", Usage{PromptTokens: 1_000_000, CandidateTokens: 100_000, TotalTokens: 1_100_000}, 3.50, true},
+		{"longest prefix wins", "gpt-4o-mini-2024-07-18", Usage{PromptTokens: 1_000_000, CandidateTokens: 1_000_000, TotalTokens: 2_000_000}, 0.75, true},
+		{"thinking billed as output", "gpt-4o", Usage{PromptTokens: 0, CandidateTokens: 100_000, TotalTokens: 300_000}, 3, true},
+		{"total missing", "gpt-4o", Usage{PromptTokens: 0, CandidateTokens: 100_000}, 1, true},
+		{"unknown model", "claude-3", Usage{PromptTokens: 1000, TotalTokens: 1000}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pricing.Cost(tt.model, tt.usage)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost(%q) = %v, %v, want %v, %v", tt.model, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPricingFromEnv(t *testing.T) {
+	t.Setenv("AI_PRICING", "")
+	if pricing := PricingFromEnv(); len(pricing) != len(defaultPricing) {
+		t.Errorf("PricingFromEnv() has %d prices, want the %d built-in", len(pricing), len(defaultPricing))
+	}
+
+	t.Setenv("AI_PRICING", `{"my-model": {"input": 0.5, "output": 1.5}, "gpt-4o": {"input": 1, "output": 2}}`)
+	pricing := PricingFromEnv()
+	if pricing["my-model"] != (Price{InputPerMillion: 0.5, OutputPerMillion: 1.5}) {
+		t.Errorf("PricingFromEnv() my-model = %+v", pricing["my-model"])
+	}
+	if pricing["gpt-4o"] != (Price{InputPerMillion: 1, OutputPerMillion: 2}) {
+		t.Errorf("PricingFromEnv() gpt-4o = %+v, want the override", pricing["gpt-4o"])
+	}
+	if defaultPricing["gpt-4o"].InputPerMillion != 2.50 {
+		t.Error("PricingFromEnv() changed the built-in prices")
+	}
+
+	t.Setenv("AI_PRICING", "{not json")
+	if pricing := PricingFromEnv(); len(pricing) != len(defaultPricing) {
+		t.Errorf("PricingFromEnv() with invalid JSON has %d prices, want the built-in", len(pricing))
+	}
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+
+	defaultGeminiModel = "gemini-2.5-flash-lite-preview-06-17"
+)
+
+type Provider interface {
+	Name() string
+	Model() string
+	Generate(ctx context.Context, req Request) (*Response, error)
+}
+
+type Request struct {
+	SystemInstruction string
+	Prompt            string
+	// JSON asks the model to answer with a single JSON document, constrained
+	// to Schema when one is given
+	JSON   bool
+	Schema *Schema
+}
+
+type Response struct {
+	Text  string
+	Model string
+	Usage Usage
+}
+
+// Usage is what a model call cost in tokens and time, as reported by the
+// provider.
+type Usage struct {
+	PromptTokens    int64
+	CandidateTokens int64
+	TotalTokens     int64
+	Latency         time.Duration
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:    u.PromptTokens + other.PromptTokens,
+		CandidateTokens: u.CandidateTokens + other.CandidateTokens,
+		TotalTokens:     u.TotalTokens + other.TotalTokens,
+		Latency:         u.Latency + other.Latency,
+	}
+}
+
+type Config struct {
+	Provider        string
+	Model           string
+	BaseURL         string
+	APIKey          string
+	Temperature     *float32
+	TopP            *float32
+	MaxOutputTokens int32
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider: os.Getenv("AI_PROVIDER"),
+		Model:    os.Getenv("AI_MODEL"),
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderGemini
+	}
+	cfg.APIKey, cfg.BaseURL = providerEnv(cfg.Provider)
+	cfg.Temperature = envFloat("AI_TEMPERATURE")
+	cfg.TopP = envFloat("AI_TOP_P")
+	if v := os.Getenv("AI_MAX_OUTPUT_TOKENS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			log.Printf("Ignoring invalid AI_MAX_OUTPUT_TOKENS %q: %v", v, err)
+		} else {
+			cfg.MaxOutputTokens = int32(n)
+		}
+	}
+	return cfg
+}
+
+// WithOverrides returns a copy of the config with any non-empty provider or
+// model replaced, so a repository can opt out of the deployment defaults.
+func (c Config) WithOverrides(provider string, model string) Config {
+	if provider != "" && provider != c.Provider {
+		c.Provider = provider
+		// A model name, key and endpoint only make sense for the provider
+		// they were configured for
+		c.Model = ""
+		c.APIKey, c.BaseURL = providerEnv(provider)
+	}
+	if model != "" {
+		c.Model = model
+	}
+	return c
+}
+
+// ValidProvider reports whether name is a provider NewProvider knows about.
+func ValidProvider(name string) bool {
+	switch name {
+	case ProviderGemini, ProviderOpenAI, ProviderFake:
+		return true
+	}
+	return false
+}
+
+// providerEnv reads the credentials and endpoint of one provider from
+// AI_<PROVIDER>_API_TOKEN and AI_<PROVIDER>_BASE_URL, falling back to the
+// generic AI_API_TOKEN and AI_BASE_URL only when they belong to that provider.
+func providerEnv(provider string) (apiKey string, baseURL string) {
+	prefix := "AI_" + strings.ToUpper(provider) + "_"
+	apiKey = os.Getenv(prefix + "API_TOKEN")
+	baseURL = os.Getenv(prefix + "BASE_URL")
+	deployed := os.Getenv("AI_PROVIDER")
+	if deployed == "" {
+		deployed = ProviderGemini
+	}
+	if deployed == provider {
+		if apiKey == "" {
+			apiKey = os.Getenv("AI_API_TOKEN")
+		}
+		if baseURL == "" {
+			baseURL = os.Getenv("AI_BASE_URL")
+		}
+	}
+	return apiKey, baseURL
+}
+
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderGemini, "":
+		return newGeminiProvider(cfg)
+	case ProviderOpenAI:
+		return newOpenAIProvider(cfg)
+	case ProviderFake:
+		return newFakeProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider: %s", cfg.Provider)
+	}
+}
+
+func envFloat(name string) *float32 {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 32)
+	if err != nil {
+		log.Printf("Ignoring invalid %s %q: %v", name, v, err)
+		return nil
+	}
+	f32 := float32(f)
+	return &f32
+}
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable ConfigFromEnv reads.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"AI_PROVIDER", "AI_MODEL", "AI_API_TOKEN", "AI_BASE_URL",
+		"AI_GEMINI_API_TOKEN", "AI_GEMINI_BASE_URL", "AI_OPENAI_API_TOKEN", "AI_OPENAI_BASE_URL",
+		"AI_TEMPERATURE", "AI_TOP_P", "AI_MAX_OUTPUT_TOKENS",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("defaults to gemini", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("AI_API_TOKEN", "generic")
+		cfg := ConfigFromEnv()
+		if cfg.Provider != ProviderGemini || cfg.APIKey != "generic" {
+			t.Errorf("ConfigFromEnv() = %+v, want gemini with the generic token", cfg)
+		}
+	})
+
+	t.Run("provider specific settings win", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("AI_PROVIDER", ProviderOpenAI)
+		t.Setenv("AI_API_TOKEN", "generic")
+		t.Setenv("AI_BASE_URL", "http://generic")
+		t.Setenv("AI_OPENAI_API_TOKEN", "openai")
+		cfg := ConfigFromEnv()
+		if cfg.APIKey != "openai" || cfg.BaseURL != "http://generic" {
+			t.Errorf("ConfigFromEnv() = %+v, want the openai token and the generic URL", cfg)
+		}
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("AI_TEMPERATURE", "0.2")
+		t.Setenv("AI_TOP_P", "nonsense")
+		t.Setenv("AI_MAX_OUTPUT_TOKENS", "2048")
+		cfg := ConfigFromEnv()
+		if cfg.Temperature == nil || *cfg.Temperature != 0.2 || cfg.TopP != nil || cfg.MaxOutputTokens != 2048 {
+			t.Errorf("ConfigFromEnv() = %+v", cfg)
+		}
+	})
+}
+
+func TestWithOverrides(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AI_API_TOKEN", "gemini key")
+	t.Setenv("AI_OPENAI_BASE_URL", "http://localhost:11434/v1")
+	base := ConfigFromEnv()
+	base.Model = "gemini-pro"
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		want     Config
+	}{
+		{"nothing", "", "", Config{Provider: ProviderGemini, Model: "gemini-pro", APIKey: "gemini key"}},
+		{"model only", "", "gemini-flash", Config{Provider: ProviderGemini, Model: "gemini-flash", APIKey: "gemini key"}},
+		{"same provider", ProviderGemini, "", Config{Provider: ProviderGemini, Model: "gemini-pro", APIKey: "gemini key"}},
+		// The deployment's key and model belong to gemini, not to the other provider
+		{"other provider", ProviderOpenAI, "llama3", Config{Provider: ProviderOpenAI, Model: "llama3", BaseURL: "http://localhost:11434/v1"}},
+		{"other provider without model", ProviderOpenAI, "", Config{Provider: ProviderOpenAI, BaseURL: "http://localhost:11434/v1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.WithOverrides(tt.provider, tt.model); got != tt.want {
+				t.Errorf("WithOverrides(%q, %q) = %+v, want %+v", tt.provider, tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		name    string
+		model   string
+		wantErr string
+	}{
+		{Config{Provider: ProviderGemini, APIKey: "key"}, ProviderGemini, defaultGeminiModel, ""},
+		{Config{APIKey: "key", Model: "gemini-pro"}, ProviderGemini, "gemini-pro", ""},
+		{Config{Provider: ProviderGemini}, "", "", "must be set for the gemini provider"},
+		{Config{Provider: ProviderOpenAI, BaseURL: "http://localhost", Model: "llama3"}, ProviderOpenAI, "llama3", ""},
+		{Config{Provider: ProviderOpenAI, Model: "llama3"}, "", "", "AI_OPENAI_BASE_URL or AI_BASE_URL must be set"},
+		{Config{Provider: ProviderOpenAI, BaseURL: "http://localhost"}, "", "", "AI_MODEL must be set"},
+		{Config{Provider: ProviderFake}, ProviderFake, defaultFakeModel, ""},
+		{Config{Provider: "claude"}, "", "", "unknown AI provider"},
+	}
+	for _, tt := range tests {
+		provider, err := NewProvider(tt.cfg)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewProvider(%+v) error = %v, want one containing %q", tt.cfg, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewProvider(%+v) error = %v", tt.cfg, err)
+			continue
+		}
+		if provider.Name() != tt.name || provider.Model() != tt.model {
+			t.Errorf("NewProvider(%+v) = %s %s, want %s %s", tt.cfg, provider.Name(), provider.Model(), tt.name, tt.model)
+		}
+	}
+}
+
+func TestValidProvider(t *testing.T) {
+	for name, want := range map[string]bool{ProviderGemini: true, ProviderOpenAI: true, ProviderFake: true, "": false, "Gemini": false, "claude": false} {
+		if got := ValidProvider(name); got != want {
+			t.Errorf("ValidProvider(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	provider, err := NewProvider(Config{Provider: ProviderFake})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{SystemInstruction: "Review this", Prompt: "diff", JSON: true}
+	first, err := provider.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := provider.Generate(context.Background(), req)
+	if first.Text != second.Text {
+		t.Errorf("Generate() is not deterministic: %q, %q", first.Text, second.Text)
+	}
+	if !strings.HasPrefix(first.Text, `{"findings":[]`) {
+		t.Errorf("Generate() = %q, want a JSON review", first.Text)
+	}
+	if first.Usage.TotalTokens != first.Usage.PromptTokens+first.Usage.CandidateTokens || first.Usage.TotalTokens == 0 {
+		t.Errorf("Usage = %+v", first.Usage)
+	}
+}
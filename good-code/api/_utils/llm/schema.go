@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"google.golang.org/genai"
+)
+
+// Schema is the subset of JSON Schema that every provider understands well
+// enough to constrain its output with.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+func (s *Schema) toGenai() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	out := &genai.Schema{
+		Description: s.Description,
+		Enum:        s.Enum,
+		Required:    s.Required,
+		Items:       s.Items.toGenai(),
+	}
+	switch s.Type {
+	case "object":
+		out.Type = genai.TypeObject
+	case "array":
+		out.Type = genai.TypeArray
+	case "string":
+		out.Type = genai.TypeString
+	case "integer":
+		out.Type = genai.TypeInteger
+	case "number":
+		out.Type = genai.TypeNumber
+	case "boolean":
+		out.Type = genai.TypeBoolean
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = prop.toGenai()
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// logSender never delivers anything. It writes each message to a file in Dir,
+// or to the log when there is none, so links in emails can be followed during
+// development.
+type logSender struct {
+	cfg Config
+}
+
+func (s *logSender) Name() string {
+	return SenderLog
+}
+
+func (s *logSender) Send(ctx context.Context, msg Message) error {
+	text := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s\n", s.cfg.From, msg.To, msg.Subject, msg.Body)
+	if s.cfg.Dir == "" {
+		log.Printf("Mail not sent, logging it instead:\n%s", text)
+		return nil
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	recipient := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, msg.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), recipient)
+	if err := os.WriteFile(filepath.Join(s.cfg.Dir, name), []byte(text), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail to %s: %w", s.cfg.Dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const (
+	SenderSMTP = "smtp"
+	SenderLog  = "log"
+
+	defaultFrom     = "Good Code <no-reply@good-code.net>"
+	defaultSMTPPort = 587
+)
+
+type Message struct {
+	To      string
+	Subject string
+	// Body is plain text
+	Body string
+}
+
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Sender   string
+	From     string
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Dir is where the log sender writes messages; empty logs them instead
+	Dir string
+}
+
+// ConfigFromEnv reads MAIL_SENDER and the SMTP_* settings. Without a sender
+// configured, SMTP is used when SMTP_HOST is set and messages are logged
+// otherwise, so development works without a mail server.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Sender:   os.Getenv("MAIL_SENDER"),
+		From:     os.Getenv("MAIL_FROM"),
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     defaultSMTPPort,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Dir:      os.Getenv("MAIL_DIR"),
+	}
+	if cfg.Sender == "" {
+		cfg.Sender = SenderLog
+		if cfg.Host != "" {
+			cfg.Sender = SenderSMTP
+		}
+	}
+	if cfg.From == "" {
+		cfg.From = defaultFrom
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("Ignoring invalid SMTP_PORT %q: %v", v, err)
+		} else {
+			cfg.Port = port
+		}
+	}
+	return cfg
+}
+
+func NewSender(cfg Config) (Sender, error) {
+	switch cfg.Sender {
+	case SenderSMTP:
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("SMTP_HOST environment variable not set")
+		}
+		return &smtpSender{cfg: cfg}, nil
+	case SenderLog, "":
+		return &logSender{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender: %s", cfg.Sender)
+	}
+}
@@ -0,0 +1,146 @@
+package persona
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	roast "github.com/chopstickleg/good-code/api/_utils/roast"
+
+	"gorm.io/gorm"
+)
+
+const Default = "sarcastic"
+
+var ErrNotFound = errors.New("persona not found")
+
+// BuiltIns are seeded into the personas table by the migration. Bump Version
+// whenever the instructions change so roasts can be traced back to them.
+var BuiltIns = []db.Persona{
+	{
+		Name:         "sarcastic",
+		Description:  "The original GoodCode experience: condescending, but right.",
+		Instructions: roast.DefaultPersona,
+		Version:      1,
+	},
+	{
+		Name:         "professional",
+		Description:  "Polite and concise, suitable for teams that want the feedback without the attitude.",
+		Instructions: "You should be polite, concise and professional, and provide useful feedback that is factually accurate to the best of your knowledge.",
+		Version:      1,
+	},
+	{
+		Name:         "mentor",
+		Description:  "Patient and encouraging, explains the why behind every suggestion.",
+		Instructions: "You should be encouraging and explain the reasoning behind each suggestion as a patient mentor would, while keeping the feedback factually accurate to the best of your knowledge.",
+		Version:      1,
+	},
+	{
+		Name:         "security auditor",
+		Description:  "Focuses on vulnerabilities, unsafe input handling and secrets.",
+		Instructions: "You are reviewing as a meticulous security auditor. Prioritise vulnerabilities, unsafe handling of untrusted input, authentication and authorisation mistakes, and leaked secrets, and explain how each issue could be exploited. Keep the feedback factually accurate to the best of your knowledge.",
+		Version:      1,
+	},
+}
+
+func Seed(conn *gorm.DB) error {
+	for _, builtIn := range BuiltIns {
+		var existing db.Persona
+		err := conn.Where("built_in = ? AND name = ?", true, builtIn.Name).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			persona := builtIn
+			persona.BuiltIn = true
+			if err := conn.Create(&persona).Error; err != nil {
+				return fmt.Errorf("failed to create persona %q: %w", builtIn.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load persona %q: %w", builtIn.Name, err)
+		}
+		if existing.Version < builtIn.Version {
+			err = conn.Model(&existing).Updates(db.Persona{
+				Description:  builtIn.Description,
+				Instructions: builtIn.Instructions,
+				Version:      builtIn.Version,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update persona %q: %w", builtIn.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Visible reports whether the user may see and select the persona: built-in
+// personas are public, custom ones only to the user who created them.
+func Visible(persona db.Persona, userID int64) bool {
+	return persona.BuiltIn || (persona.OwnerID != nil && *persona.OwnerID == userID)
+}
+
+// FindByName looks a persona up by name among the built-ins and the custom
+// personas of the given user, preferring the user's own.
+func FindByName(conn *gorm.DB, name string, ownerID *int64) (*db.Persona, error) {
+	var personas []db.Persona
+	query := conn.Where("name = ?", name)
+	if ownerID != nil {
+		query = query.Where("built_in = ? OR owner_id = ?", true, *ownerID)
+	} else {
+		query = query.Where("built_in = ?", true)
+	}
+	if err := query.Order("built_in ASC").Find(&personas).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up persona %q: %w", name, err)
+	}
+	if len(personas) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	return &personas[0], nil
+}
+
+// FindForRepo looks a persona up by name among the built-ins and the custom
+// personas of the repository owner.
+func FindForRepo(conn *gorm.DB, repo db.Repository, name string) (*db.Persona, error) {
+	var owner db.UserLogin
+	var ownerID *int64
+	if repo.OwnerID != 0 && conn.Where(&db.UserLogin{GithubID: &repo.OwnerID}).First(&owner).Error == nil {
+		ownerID = &owner.ID
+	}
+	return FindByName(conn, name, ownerID)
+}
+
+// Resolve picks the persona for a roast. The repository's choices come first,
+// the tone named in .goodcode.yml and then the persona selected for the
+// repository in the dashboard, so an author cannot soften their own review.
+// Only when the repository pins neither does a persona picked for the pull
+// request, then the author's own preference, apply before the default.
+func Resolve(conn *gorm.DB, repo db.Repository, pullRequest db.PullRequest, configTone string) (*db.Persona, error) {
+	if configTone != "" {
+		return FindForRepo(conn, repo, configTone)
+	}
+
+	var author db.UserLogin
+	if pullRequest.AuthorGithubID != 0 {
+		err := conn.Where(&db.UserLogin{GithubID: &pullRequest.AuthorGithubID}).First(&author).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to load pull request author: %w", err)
+		}
+	}
+
+	for _, id := range []*int64{repo.PersonaID, pullRequest.PersonaID, author.PersonaID} {
+		if id == nil {
+			continue
+		}
+		var persona db.Persona
+		err := conn.Where(&db.Persona{ID: *id}).First(&persona).Error
+		if err == nil {
+			return &persona, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to load persona %d: %w", *id, err)
+		}
+		log.Printf("Selected persona %d no longer exists, ignoring it", *id)
+	}
+
+	return FindByName(conn, Default, nil)
+}
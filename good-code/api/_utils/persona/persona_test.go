@@ -0,0 +1,133 @@
+package persona
+
+import (
+	"errors"
+	"testing"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&db.Persona{}, &db.UserLogin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seed(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// createUser creates an account linked to the given GitHub ID.
+func createUser(t *testing.T, conn *gorm.DB, githubID int64) *db.UserLogin {
+	t.Helper()
+	user := &db.UserLogin{Email: "user@example.com", GithubID: &githubID}
+	if err := conn.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createPersona(t *testing.T, conn *gorm.DB, name string, ownerID *int64) *db.Persona {
+	t.Helper()
+	persona := &db.Persona{Name: name, Instructions: "Be " + name + ".", OwnerID: ownerID}
+	if err := conn.Create(persona).Error; err != nil {
+		t.Fatal(err)
+	}
+	return persona
+}
+
+func TestSeed(t *testing.T) {
+	conn := testDB(t)
+	err := conn.Model(&db.Persona{}).Where("name = ?", Default).
+		Updates(map[string]any{"instructions": "Be nice.", "version": 0}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Seed(conn); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	var count int64
+	conn.Model(&db.Persona{}).Count(&count)
+	if count != int64(len(BuiltIns)) {
+		t.Errorf("%d personas after seeding twice, want %d", count, len(BuiltIns))
+	}
+	persona, err := FindByName(conn, Default, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if persona.Instructions != BuiltIns[0].Instructions || persona.Version != BuiltIns[0].Version || !persona.BuiltIn {
+		t.Errorf("outdated built-in = %+v, want it brought up to date", persona)
+	}
+}
+
+func TestFindByName(t *testing.T) {
+	conn := testDB(t)
+	owner, other := int64(1), int64(2)
+	own := createPersona(t, conn, "professional", &owner)
+	createPersona(t, conn, "pirate", &other)
+
+	persona, err := FindByName(conn, "professional", &owner)
+	if err != nil || persona.ID != own.ID {
+		t.Errorf("FindByName(owner) = %+v, %v, want the owner's own persona", persona, err)
+	}
+	persona, err = FindByName(conn, "professional", nil)
+	if err != nil || !persona.BuiltIn {
+		t.Errorf("FindByName(nil) = %+v, %v, want the built-in", persona, err)
+	}
+	if _, err := FindByName(conn, "pirate", &owner); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByName(someone else's) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	conn := testDB(t)
+	owner := createUser(t, conn, 100)
+	author := createUser(t, conn, 200)
+	ownerTone := createPersona(t, conn, "pirate", &owner.ID)
+	repoChoice := createPersona(t, conn, "repo choice", &owner.ID)
+	pullRequestChoice := createPersona(t, conn, "pull request choice", &owner.ID)
+	authorChoice := createPersona(t, conn, "author choice", &author.ID)
+	if err := conn.Model(author).Update("persona_id", authorChoice.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	gone := int64(999)
+
+	tests := []struct {
+		name        string
+		repo        db.Repository
+		pullRequest db.PullRequest
+		tone        string
+		want        string
+	}{
+		{"tone from config", db.Repository{OwnerID: 100, PersonaID: &repoChoice.ID}, db.PullRequest{AuthorGithubID: 200}, "pirate", ownerTone.Name},
+		{"repository choice", db.Repository{OwnerID: 100, PersonaID: &repoChoice.ID}, db.PullRequest{AuthorGithubID: 200, PersonaID: &pullRequestChoice.ID}, "", repoChoice.Name},
+		{"pull request choice", db.Repository{OwnerID: 100}, db.PullRequest{AuthorGithubID: 200, PersonaID: &pullRequestChoice.ID}, "", pullRequestChoice.Name},
+		{"author preference", db.Repository{OwnerID: 100}, db.PullRequest{AuthorGithubID: 200}, "", authorChoice.Name},
+		{"deleted choice", db.Repository{OwnerID: 100, PersonaID: &gone}, db.PullRequest{}, "", Default},
+		{"default", db.Repository{OwnerID: 100}, db.PullRequest{AuthorGithubID: 300}, "", Default},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persona, err := Resolve(conn, tt.repo, tt.pullRequest, tt.tone)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if persona.Name != tt.want {
+				t.Errorf("Resolve() = %q, want %q", persona.Name, tt.want)
+			}
+		})
+	}
+
+	if _, err := Resolve(conn, db.Repository{OwnerID: 100}, db.PullRequest{}, "nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve() with an unknown tone error = %v, want ErrNotFound", err)
+	}
+}
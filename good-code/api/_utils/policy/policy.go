@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultSkipLabel  = "no-roast"
+	DefaultForceLabel = "roast-me"
+)
+
+// Rules decide which pull requests get roasted. They live under "triggers"
+// in .goodcode.yml.
+type Rules struct {
+	// A pull request with any of these labels is never roasted
+	SkipLabels []string `yaml:"skip_labels" json:"skip_labels"`
+	// A pull request with any of these labels is roasted whatever the other
+	// rules say, and adding one triggers a roast
+	ForceLabels []string `yaml:"force_labels" json:"force_labels"`
+	// RequireLabel only roasts pull requests carrying one of ForceLabels
+	RequireLabel bool `yaml:"require_label" json:"require_label"`
+
+	// Author logins to skip, as globs such as "dependabot*"
+	IgnoreAuthors []string `yaml:"ignore_authors" json:"ignore_authors"`
+	IgnoreBots    bool     `yaml:"ignore_bots" json:"ignore_bots"`
+
+	// Base branches to roast pull requests into, as globs such as "release/*";
+	// empty means every branch not in IgnoreBranches
+	Branches       []string `yaml:"branches" json:"branches"`
+	IgnoreBranches []string `yaml:"ignore_branches" json:"ignore_branches"`
+
+	// Drafts are roasted once marked ready for review unless this is set
+	Drafts bool `yaml:"drafts" json:"drafts"`
+
+	// Size limits on the whole pull request; 0 means no limit
+	MaxChangedLines int `yaml:"max_changed_lines" json:"max_changed_lines"`
+	MaxChangedFiles int `yaml:"max_changed_files" json:"max_changed_files"`
+}
+
+func Default() Rules {
+	return Rules{
+		SkipLabels:     []string{DefaultSkipLabel},
+		ForceLabels:    []string{DefaultForceLabel},
+		IgnoreAuthors:  []string{},
+		IgnoreBots:     true,
+		Branches:       []string{},
+		IgnoreBranches: []string{},
+	}
+}
+
+// Validate reports invalid globs, prefixed with the config key they are under.
+func (r Rules) Validate(prefix string) []string {
+	var errs []string
+	for key, patterns := range map[string][]string{
+		"ignore_authors":  r.IgnoreAuthors,
+		"branches":        r.Branches,
+		"ignore_branches": r.IgnoreBranches,
+	} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s: invalid glob %q", prefix, key, pattern))
+			}
+		}
+	}
+	if r.MaxChangedLines < 0 {
+		errs = append(errs, prefix+".max_changed_lines: must not be negative")
+	}
+	if r.MaxChangedFiles < 0 {
+		errs = append(errs, prefix+".max_changed_files: must not be negative")
+	}
+	slices.Sort(errs)
+	return errs
+}
+
+// PullRequest holds what the rules look at, taken from the webhook event.
+type PullRequest struct {
+	// Action is the pull_request webhook action, or "rerequested" when
+	// someone explicitly asked for a roast
+	Action string
+	// Label is the label just added, for the "labeled" action
+	Label        string
+	Labels       []string
+	AuthorLogin  string
+	AuthorIsBot  bool
+	BaseRef      string
+	Draft        bool
+	ChangedLines int
+	ChangedFiles int
+}
+
+// Decision is the outcome of the rules. A pull request that is not roasted
+// normally comes with the reason; events that are simply not triggers, such
+// as adding an unrelated label, have none.
+type Decision struct {
+	Roast  bool
+	Reason string
+}
+
+func roast() Decision {
+	return Decision{Roast: true}
+}
+
+func skip(format string, args ...any) Decision {
+	return Decision{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Forces reports whether adding the label triggers a roast.
+func (r Rules) Forces(label string) bool {
+	_, ok := firstOf(r.ForceLabels, []string{label})
+	return ok
+}
+
+// Evaluate applies the rules to a pull request.
+func (r Rules) Evaluate(pr PullRequest) Decision {
+	if pr.Action == "labeled" && !r.Forces(pr.Label) {
+		return Decision{}
+	}
+	// Explicit requests override everything else
+	if pr.Action == "rerequested" {
+		return roast()
+	}
+	if label, ok := firstOf(r.SkipLabels, pr.Labels); ok {
+		return skip("labeled %q", label)
+	}
+	// Force labels override everything but skip labels
+	if _, ok := firstOf(r.ForceLabels, pr.Labels); ok {
+		return roast()
+	}
+	if r.RequireLabel {
+		return skip("not labeled %s", quoteAll(r.ForceLabels))
+	}
+
+	if pr.AuthorIsBot && r.IgnoreBots {
+		return skip("opened by bot %s", pr.AuthorLogin)
+	}
+	if pattern, ok := matchAny(r.IgnoreAuthors, pr.AuthorLogin); ok {
+		return skip("author %s is ignored (%s)", pr.AuthorLogin, pattern)
+	}
+	if pattern, ok := matchAny(r.IgnoreBranches, pr.BaseRef); ok {
+		return skip("base branch %s is ignored (%s)", pr.BaseRef, pattern)
+	}
+	if _, ok := matchAny(r.Branches, pr.BaseRef); len(r.Branches) > 0 && !ok {
+		return skip("base branch %s is not one of %s", pr.BaseRef, quoteAll(r.Branches))
+	}
+	if pr.Draft && !r.Drafts {
+		return skip("draft, will roast once ready for review")
+	}
+	if r.MaxChangedLines > 0 && pr.ChangedLines > r.MaxChangedLines {
+		return skip("changes %d lines, over the limit of %d", pr.ChangedLines, r.MaxChangedLines)
+	}
+	if r.MaxChangedFiles > 0 && pr.ChangedFiles > r.MaxChangedFiles {
+		return skip("changes %d files, over the limit of %d", pr.ChangedFiles, r.MaxChangedFiles)
+	}
+	return roast()
+}
+
+func firstOf(wanted []string, labels []string) (string, bool) {
+	for _, label := range labels {
+		if slices.ContainsFunc(wanted, func(w string) bool { return strings.EqualFold(w, label) }) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+func matchAny(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,81 @@
+package policy
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	defaults := Default()
+	tests := []struct {
+		name  string
+		rules Rules
+		pr    PullRequest
+		want  Decision
+	}{
+		{"opened", defaults, PullRequest{Action: "opened", BaseRef: "main"}, Decision{Roast: true}},
+		{"unrelated label added", defaults, PullRequest{Action: "labeled", Label: "bug", Labels: []string{"bug"}}, Decision{}},
+		{"force label added", defaults, PullRequest{Action: "labeled", Label: "Roast-Me", Labels: []string{"Roast-Me"}}, Decision{Roast: true}},
+		{"rerequested overrides skip label", defaults, PullRequest{Action: "rerequested", Labels: []string{"no-roast"}}, Decision{Roast: true}},
+		{"skip label", defaults, PullRequest{Action: "opened", Labels: []string{"no-roast"}}, Decision{Reason: `labeled "no-roast"`}},
+		{"skip label beats force label", defaults, PullRequest{Action: "opened", Labels: []string{"roast-me", "no-roast"}}, Decision{Reason: `labeled "no-roast"`}},
+		{"force label beats draft", defaults, PullRequest{Action: "opened", Draft: true, Labels: []string{"roast-me"}}, Decision{Roast: true}},
+		{"label required", Rules{ForceLabels: []string{"roast-me"}, RequireLabel: true}, PullRequest{Action: "opened"}, Decision{Reason: `not labeled "roast-me"`}},
+		{"bot", defaults, PullRequest{Action: "opened", AuthorLogin: "renovate[bot]", AuthorIsBot: true}, Decision{Reason: "opened by bot renovate[bot]"}},
+		{"bots allowed", Rules{}, PullRequest{Action: "opened", AuthorIsBot: true}, Decision{Roast: true}},
+		{"ignored author", Rules{IgnoreAuthors: []string{"dependabot*"}}, PullRequest{Action: "opened", AuthorLogin: "dependabot-preview"}, Decision{Reason: "author dependabot-preview is ignored (dependabot*)"}},
+		{"ignored branch", Rules{IgnoreBranches: []string{"release/*"}}, PullRequest{Action: "opened", BaseRef: "release/1.2"}, Decision{Reason: "base branch release/1.2 is ignored (release/*)"}},
+		{"branch listed", Rules{Branches: []string{"main", "release/*"}}, PullRequest{Action: "opened", BaseRef: "release/1.2"}, Decision{Roast: true}},
+		{"branch not listed", Rules{Branches: []string{"main"}}, PullRequest{Action: "opened", BaseRef: "develop"}, Decision{Reason: `base branch develop is not one of "main"`}},
+		{"draft", defaults, PullRequest{Action: "opened", Draft: true}, Decision{Reason: "draft, will roast once ready for review"}},
+		{"drafts allowed", Rules{Drafts: true}, PullRequest{Action: "opened", Draft: true}, Decision{Roast: true}},
+		{"too many lines", Rules{MaxChangedLines: 100}, PullRequest{Action: "opened", ChangedLines: 101}, Decision{Reason: "changes 101 lines, over the limit of 100"}},
+		{"lines at the limit", Rules{MaxChangedLines: 100}, PullRequest{Action: "opened", ChangedLines: 100}, Decision{Roast: true}},
+		{"too many files", Rules{MaxChangedFiles: 10}, PullRequest{Action: "opened", ChangedFiles: 11}, Decision{Reason: "changes 11 files, over the limit of 10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Evaluate(tt.pr); got != tt.want {
+				t.Errorf("Evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	rules := Rules{Branches: []string{"release/["}, MaxChangedLines: -1}
+	want := []string{
+		`triggers.branches: invalid glob "release/["`,
+		"triggers.max_changed_lines: must not be negative",
+	}
+	got := rules.Validate("triggers")
+	if len(got) != len(want) {
+		t.Fatalf("Validate() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Validate()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if errs := Default().Validate("triggers"); len(errs) != 0 {
+		t.Errorf("Default().Validate() = %q, want none", errs)
+	}
+}
+
+func TestForces(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		label string
+		want  bool
+	}{
+		{Default(), DefaultForceLabel, true},
+		{Default(), "ROAST-ME", true},
+		{Default(), "bug", false},
+		{Default(), "", false},
+		{Rules{ForceLabels: []string{"review please"}}, "review please", true},
+		{Rules{ForceLabels: []string{"review please"}}, DefaultForceLabel, false},
+		{Rules{}, DefaultForceLabel, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Forces(tt.label); got != tt.want {
+			t.Errorf("Forces(%q) with %q = %v, want %v", tt.label, tt.rules.ForceLabels, got, tt.want)
+		}
+	}
+}
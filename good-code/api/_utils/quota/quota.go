@@ -0,0 +1,293 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PlanFree      = "free"
+	PlanPro       = "pro"
+	PlanUnlimited = "unlimited"
+)
+
+// ErrExhausted is returned by Reserve when the installation has used up its
+// roasts or tokens for the month.
+var ErrExhausted = errors.New("quota exhausted")
+
+// Limits caps what an installation may use. Zero means no limit.
+type Limits struct {
+	RoastsPerMonth int   `json:"roasts_per_month"`
+	MaxDiffBytes   int   `json:"max_diff_bytes"`
+	TokensPerMonth int64 `json:"tokens_per_month"`
+	// MaxTokensPerRoast caps the estimated prompt tokens of a single roast,
+	// checked before the model is called
+	MaxTokensPerRoast int64 `json:"max_tokens_per_roast"`
+}
+
+var defaultPlans = map[string]Limits{
+	PlanFree:      {RoastsPerMonth: 50, MaxDiffBytes: 200_000, TokensPerMonth: 2_000_000, MaxTokensPerRoast: 100_000},
+	PlanPro:       {RoastsPerMonth: 1000, MaxDiffBytes: 1_000_000, TokensPerMonth: 50_000_000, MaxTokensPerRoast: 500_000},
+	PlanUnlimited: {},
+}
+
+// PlansFromEnv returns the built-in plans with any defined in QUOTA_PLANS, a
+// JSON object such as {"team": {"roasts_per_month": 200}}, on top.
+func PlansFromEnv() map[string]Limits {
+	plans := map[string]Limits{}
+	for name, limits := range defaultPlans {
+		plans[name] = limits
+	}
+	v := os.Getenv("QUOTA_PLANS")
+	if v == "" {
+		return plans
+	}
+	var overrides map[string]Limits
+	if err := json.Unmarshal([]byte(v), &overrides); err != nil {
+		log.Printf("Ignoring invalid QUOTA_PLANS: %v", err)
+		return plans
+	}
+	for name, limits := range overrides {
+		plans[name] = limits
+	}
+	return plans
+}
+
+// DefaultPlan is the plan new installations start on, QUOTA_DEFAULT_PLAN or
+// the free plan.
+func DefaultPlan() string {
+	if plan := os.Getenv("QUOTA_DEFAULT_PLAN"); plan != "" {
+		return plan
+	}
+	return PlanFree
+}
+
+// Period names the calendar month quotas are counted in.
+func Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// ResetsAt is when the quota of the period containing t starts over.
+func ResetsAt(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// LimitsFor loads the installation, recording it on the default plan the first
+// time it is seen, and works out its limits.
+func LimitsFor(conn *gorm.DB, installationID int64, accountLogin string) (*db.Installation, Limits, error) {
+	installation := db.Installation{ID: installationID, AccountLogin: accountLogin, Plan: DefaultPlan()}
+	err := conn.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&installation).
+		Error
+	if err != nil {
+		return nil, Limits{}, fmt.Errorf("failed to record installation %d: %w", installationID, err)
+	}
+	if err := conn.Where(&db.Installation{ID: installationID}).First(&installation).Error; err != nil {
+		return nil, Limits{}, fmt.Errorf("failed to load installation %d: %w", installationID, err)
+	}
+	if installation.AccountLogin == "" && accountLogin != "" {
+		installation.AccountLogin = accountLogin
+		if err := conn.Model(&installation).Update("account_login", accountLogin).Error; err != nil {
+			log.Printf("Failed to record account of installation %d: %v", installationID, err)
+		}
+	}
+
+	limits, ok := PlansFromEnv()[installation.Plan]
+	if !ok {
+		log.Printf("Installation %d is on unknown plan %q, using %q", installationID, installation.Plan, DefaultPlan())
+		limits = PlansFromEnv()[DefaultPlan()]
+	}
+	if installation.RoastsPerMonth != nil {
+		limits.RoastsPerMonth = *installation.RoastsPerMonth
+	}
+	if installation.MaxDiffBytes != nil {
+		limits.MaxDiffBytes = *installation.MaxDiffBytes
+	}
+	if installation.TokensPerMonth != nil {
+		limits.TokensPerMonth = *installation.TokensPerMonth
+	}
+	if installation.MaxTokensPerRoast != nil {
+		limits.MaxTokensPerRoast = *installation.MaxTokensPerRoast
+	}
+	return &installation, limits, nil
+}
+
+// Reservation is a roast, or another model call, counted against an
+// installation's quota.
+type Reservation struct {
+	InstallationID int64
+	Period         string
+	// Key ties the reservation to the job that made it; empty for none
+	Key    string
+	roasts int
+}
+
+// Reserve counts a roast against the installation's quota for this month, in
+// a single upsert that only goes through while the installation is under its
+// limits. Tokens are only known once the model answered, so concurrent roasts
+// may take the token count somewhat over its limit, but never the roast count.
+// A non-empty key makes the reservation idempotent: reserving again with the
+// same key, as a retried job does, returns the first reservation uncounted.
+func Reserve(conn *gorm.DB, installationID int64, limits Limits, key string) (*Reservation, error) {
+	return reserve(conn, installationID, limits, key, 1)
+}
+
+// ReserveCall checks a model call that is not a roast, such as an answer to a
+// comment, against the token limit. It does not use up a roast.
+func ReserveCall(conn *gorm.DB, installationID int64, limits Limits, key string) (*Reservation, error) {
+	return reserve(conn, installationID, limits, key, 0)
+}
+
+func reserve(conn *gorm.DB, installationID int64, limits Limits, key string, roasts int) (*Reservation, error) {
+	reservation := &Reservation{InstallationID: installationID, Period: Period(time.Now()), Key: key, roasts: roasts}
+
+	var under []clause.Expression
+	if roasts > 0 && limits.RoastsPerMonth > 0 {
+		under = append(under, gorm.Expr("quota_usages.roasts + ? <= ?", roasts, limits.RoastsPerMonth))
+	}
+	if limits.TokensPerMonth > 0 {
+		under = append(under, gorm.Expr("quota_usages.tokens < ?", limits.TokensPerMonth))
+	}
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if key != "" {
+			claimed := tx.
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&db.QuotaReservation{Key: key, InstallationID: installationID, Period: reservation.Period, Roasts: roasts})
+			if claimed.Error != nil {
+				return claimed.Error
+			}
+			if claimed.RowsAffected == 0 {
+				var existing db.QuotaReservation
+				if err := tx.Where(&db.QuotaReservation{Key: key}).First(&existing).Error; err != nil {
+					return err
+				}
+				reservation.Period = existing.Period
+				reservation.roasts = existing.Roasts
+				return nil
+			}
+		}
+
+		result := tx.
+			Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "installation_id"}, {Name: "period"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"roasts":     gorm.Expr("quota_usages.roasts + ?", roasts),
+					"updated_at": time.Now(),
+				}),
+				Where: clause.Where{Exprs: under},
+			}).
+			Create(&db.QuotaUsage{InstallationID: installationID, Period: reservation.Period, Roasts: roasts})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrExhausted
+		}
+		return nil
+	})
+	if errors.Is(err, ErrExhausted) {
+		return nil, ErrExhausted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve quota for installation %d: %w", installationID, err)
+	}
+	return reservation, nil
+}
+
+// Release gives the reservation back, for when the model was never
+// successfully called.
+func (r *Reservation) Release(conn *gorm.DB) {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if r.Key != "" {
+			if err := tx.Where(&db.QuotaReservation{Key: r.Key}).Delete(&db.QuotaReservation{}).Error; err != nil {
+				return err
+			}
+		}
+		if r.roasts == 0 {
+			return nil
+		}
+		return tx.Model(&db.QuotaUsage{}).
+			Where(&db.QuotaUsage{InstallationID: r.InstallationID, Period: r.Period}).
+			Where("roasts >= ?", r.roasts).
+			UpdateColumn("roasts", gorm.Expr("roasts - ?", r.roasts)).
+			Error
+	})
+	if err != nil {
+		log.Printf("Failed to release quota of installation %d: %v", r.InstallationID, err)
+	}
+}
+
+// Record adds the tokens the model calls used.
+func (r *Reservation) Record(conn *gorm.DB, tokens int64) {
+	err := conn.Model(&db.QuotaUsage{}).
+		Where(&db.QuotaUsage{InstallationID: r.InstallationID, Period: r.Period}).
+		UpdateColumn("tokens", gorm.Expr("tokens + ?", tokens)).
+		Error
+	if err != nil {
+		log.Printf("Failed to record %d tokens against installation %d: %v", tokens, r.InstallationID, err)
+	}
+}
+
+// Remaining is what is left of a limit, nil when there is no limit.
+type Remaining struct {
+	Roasts *int   `json:"roasts"`
+	Tokens *int64 `json:"tokens"`
+}
+
+type Status struct {
+	InstallationID int64     `json:"installation_id"`
+	Plan           string    `json:"plan"`
+	Period         string    `json:"period"`
+	ResetsAt       time.Time `json:"resets_at"`
+	Limits         Limits    `json:"limits"`
+	Used           struct {
+		Roasts int   `json:"roasts"`
+		Tokens int64 `json:"tokens"`
+	} `json:"used"`
+	Remaining Remaining `json:"remaining"`
+}
+
+// GetStatus reports the installation's limits and what is left of them this
+// month.
+func GetStatus(conn *gorm.DB, installationID int64) (*Status, error) {
+	installation, limits, err := LimitsFor(conn, installationID, "")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	status := &Status{
+		InstallationID: installationID,
+		Plan:           installation.Plan,
+		Period:         Period(now),
+		ResetsAt:       ResetsAt(now),
+		Limits:         limits,
+	}
+
+	var used db.QuotaUsage
+	err = conn.Where(&db.QuotaUsage{InstallationID: installationID, Period: status.Period}).First(&used).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to load quota usage of installation %d: %w", installationID, err)
+	}
+	status.Used.Roasts = used.Roasts
+	status.Used.Tokens = used.Tokens
+	if limits.RoastsPerMonth > 0 {
+		roasts := max(limits.RoastsPerMonth-used.Roasts, 0)
+		status.Remaining.Roasts = &roasts
+	}
+	if limits.TokensPerMonth > 0 {
+		tokens := max(limits.TokensPerMonth-used.Tokens, 0)
+		status.Remaining.Tokens = &tokens
+	}
+	return status, nil
+}
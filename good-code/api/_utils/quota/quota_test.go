@@ -0,0 +1,67 @@
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriod(t *testing.T) {
+	tests := []struct {
+		t        time.Time
+		period   string
+		resetsAt time.Time
+	}{
+		{time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), "2026-10", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC), "2026-12", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), "2026-11", time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)},
+		// Still October in UTC
+		{time.Date(2026, 11, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600)), "2026-10", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := Period(tt.t); got != tt.period {
+			t.Errorf("Period(%v) = %s, want %s", tt.t, got, tt.period)
+		}
+		if got := ResetsAt(tt.t); !got.Equal(tt.resetsAt) {
+			t.Errorf("ResetsAt(%v) = %v, want %v", tt.t, got, tt.resetsAt)
+		}
+	}
+}
+
+func TestPlansFromEnv(t *testing.T) {
+	t.Run("built in", func(t *testing.T) {
+		t.Setenv("QUOTA_PLANS", "")
+		plans := PlansFromEnv()
+		if plans[PlanFree] != defaultPlans[PlanFree] || plans[PlanPro] != defaultPlans[PlanPro] {
+			t.Errorf("PlansFromEnv() = %v, want the built-in plans", plans)
+		}
+		if plans[PlanUnlimited] != (Limits{}) {
+			t.Errorf("unlimited plan = %+v, want no limits", plans[PlanUnlimited])
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("QUOTA_PLANS", `{"team": {"roasts_per_month": 200}, "free": {"roasts_per_month": 5, "tokens_per_month": 1000}}`)
+		plans := PlansFromEnv()
+		if want := (Limits{RoastsPerMonth: 200}); plans["team"] != want {
+			t.Errorf("team plan = %+v, want %+v", plans["team"], want)
+		}
+		// A plan is replaced as a whole, not merged
+		if want := (Limits{RoastsPerMonth: 5, TokensPerMonth: 1000}); plans[PlanFree] != want {
+			t.Errorf("free plan = %+v, want %+v", plans[PlanFree], want)
+		}
+		if plans[PlanPro] != defaultPlans[PlanPro] {
+			t.Errorf("pro plan = %+v, want the built-in one", plans[PlanPro])
+		}
+		if defaultPlans[PlanFree].RoastsPerMonth != 50 {
+			t.Errorf("overrides changed the built-in plans")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("QUOTA_PLANS", `{"team": `)
+		plans := PlansFromEnv()
+		if len(plans) != len(defaultPlans) {
+			t.Errorf("PlansFromEnv() = %v, want the built-in plans", plans)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"fmt"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"gorm.io/gorm"
+)
+
+func GetInstallationID(repoId int64, conn *gorm.DB) (int64, error) {
+	var installationId int64
+	err := conn.Model(&db.Repository{}).
+		Select("installation_id").
+		Where("id = ?", repoId).
+		Scan(&installationId).
+		Error
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving installation ID for repository %d: %w", repoId, err)
+	}
+	return installationId, nil
+}
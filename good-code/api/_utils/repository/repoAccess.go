@@ -15,7 +15,7 @@ func GetRepoAccess(repoId int64, owner db.UserLogin, conn *gorm.DB) (bool, error
 		return false, fmt.Errorf("error retrieving repository with ID %d: %w", repoId, err)
 	}
 
-	isOwner := repo.OwnerID == owner.GithubID
+	isOwner := owner.GithubID != nil && repo.OwnerID == *owner.GithubID
 
 	var isCollaborator bool
 	if !isOwner {
@@ -27,3 +27,58 @@ func GetRepoAccess(repoId int64, owner db.UserLogin, conn *gorm.DB) (bool, error
 	}
 	return (isOwner || isCollaborator), nil
 }
+
+// GetRepoManageAccess reports whether the user may change the repository's
+// GoodCode settings: the owner, or a collaborator with admin or maintain rights.
+func GetRepoManageAccess(repoId int64, user db.UserLogin, conn *gorm.DB) (bool, error) {
+	var repo db.Repository
+	err := conn.Where(&db.Repository{ID: repoId}).First(&repo).Error
+	if err != nil {
+		return false, fmt.Errorf("error retrieving repository with ID %d: %w", repoId, err)
+	}
+	if user.GithubID != nil && repo.OwnerID == *user.GithubID {
+		return true, nil
+	}
+
+	var collaborator db.UserRepositoryCollaborator
+	err = conn.Where(&db.UserRepositoryCollaborator{RepositoryID: repoId, UserLoginID: &user.ID}).First(&collaborator).Error
+	if err != nil {
+		return false, nil
+	}
+	return collaborator.Role == "admin" || collaborator.Role == "maintain", nil
+}
+
+// roleRank orders the repository roles GitHub reports, including the legacy
+// names still used by member events.
+var roleRank = map[string]int{
+	"read":     1,
+	"pull":     1,
+	"triage":   2,
+	"write":    3,
+	"push":     3,
+	"maintain": 4,
+	"admin":    5,
+}
+
+// HasRole reports whether the GitHub user owns the repository or collaborates
+// on it with at least the given role.
+func HasRole(repoId int64, githubUserID int64, minimum string, conn *gorm.DB) (bool, error) {
+	var repo db.Repository
+	err := conn.Where(&db.Repository{ID: repoId}).First(&repo).Error
+	if err != nil {
+		return false, fmt.Errorf("error retrieving repository with ID %d: %w", repoId, err)
+	}
+	if repo.OwnerID == githubUserID {
+		return true, nil
+	}
+
+	var collaborator db.UserRepositoryCollaborator
+	err = conn.Where(&db.UserRepositoryCollaborator{RepositoryID: repoId, GithubUserID: githubUserID}).First(&collaborator).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error retrieving collaborator %d on repository %d: %w", githubUserID, repoId, err)
+	}
+	return roleRank[collaborator.Role] >= roleRank[minimum], nil
+}
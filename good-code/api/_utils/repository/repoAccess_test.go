@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"testing"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHasRole(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Accounts create the collaborators table as a bare join table, which
+	// migrating the collaborator model afterwards completes
+	if err := conn.AutoMigrate(&db.UserLogin{}, &db.UserRepositoryCollaborator{}); err != nil {
+		t.Fatal(err)
+	}
+	conn.Create(&db.Repository{ID: 1, Name: "repo", OwnerID: 100})
+	conn.Create(&db.UserRepositoryCollaborator{RepositoryID: 1, GithubUserID: 200, Role: "push"})
+	conn.Create(&db.UserRepositoryCollaborator{RepositoryID: 1, GithubUserID: 300, Role: "triage"})
+
+	tests := []struct {
+		name    string
+		user    int64
+		minimum string
+		want    bool
+	}{
+		{"owner", 100, "admin", true},
+		{"legacy role name", 200, "write", true},
+		{"role too low", 300, "write", false},
+		{"role high enough", 300, "read", true},
+		{"not a collaborator", 400, "read", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HasRole(1, tt.user, tt.minimum, conn)
+			if err != nil {
+				t.Fatalf("HasRole() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasRole(%d, %q) = %v, want %v", tt.user, tt.minimum, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := HasRole(2, 100, "read", conn); err == nil {
+		t.Error("HasRole() on an unknown repository succeeded")
+	}
+}
@@ -0,0 +1,131 @@
+package roast
+
+import (
+	"fmt"
+	"strings"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+)
+
+// contextShare is the part of the chunk budget set aside for surrounding code
+// when file contents are available: one quarter.
+const contextShare = 4
+
+const contextIntroduction = "Below is the code surrounding the changes, taken from the new version of each file. It is only there so you know what the changed code refers to; do not review it or report findings on lines that are not part of the diff."
+
+// PromptBuilder turns chunks of a diff into the prompts sent to the model.
+type PromptBuilder struct {
+	// Intent opens every chunk prompt with what the pull request claims to do
+	Intent *Intent
+	// Preamble is prepended to every chunk prompt, e.g. to carry the previous roast
+	Preamble string
+	// Contents maps file paths to their contents after the change, for the
+	// files whose surrounding code should be included
+	Contents map[string]string
+	// ContextLines is how many lines to show around each hunk
+	ContextLines int
+}
+
+// DiffBudget is how many tokens of diff go into a chunk with the given budget,
+// after the intent and preamble and leaving room for surrounding code when
+// there is any.
+func (b PromptBuilder) DiffBudget(budget int) int {
+	budget = max(budget-b.overhead(), 1)
+	if len(b.Contents) == 0 || b.ContextLines <= 0 {
+		return budget
+	}
+	return budget - budget/contextShare
+}
+
+// overhead is the estimated size of what every chunk prompt carries besides
+// the diff itself.
+func (b PromptBuilder) overhead() int {
+	tokens := diff.EstimateTokens(b.Preamble)
+	if b.Intent != nil {
+		tokens += diff.EstimateTokens(b.Intent.String())
+	}
+	return tokens
+}
+
+// Build renders the prompt for part of total chunks. The surrounding code is
+// cut down to fewer lines around each hunk until the prompt fits the budget,
+// and left out when not even that fits.
+func (b PromptBuilder) Build(chunk diff.Chunk, part int, total int, budget int) string {
+	prompt := chunk.Text
+	if total > 1 {
+		prompt = fmt.Sprintf("This is part %d of %d of the diff.\n\n%s", part, total, chunk.Text)
+	}
+	if b.Preamble != "" {
+		prompt = b.Preamble + "\n\n" + prompt
+	}
+	if b.Intent != nil {
+		prompt = b.Intent.String() + "\n\n" + prompt
+	}
+
+	if len(b.Contents) == 0 || b.ContextLines <= 0 {
+		return prompt
+	}
+	files, err := diff.Parse(chunk.Text)
+	if err != nil {
+		return prompt
+	}
+	room := budget - diff.EstimateTokens(prompt)
+	for lines := b.ContextLines; lines > 0; lines /= 2 {
+		surrounding := b.surroundings(files, lines)
+		if surrounding == "" {
+			return prompt
+		}
+		if diff.EstimateTokens(surrounding) <= room {
+			return surrounding + "\n\n" + prompt
+		}
+	}
+	return prompt
+}
+
+func (b PromptBuilder) surroundings(files []diff.File, lines int) string {
+	var sections []string
+	for i := range files {
+		file := &files[i]
+		content, ok := b.Contents[file.Path()]
+		if !ok || file.IsDeleted() {
+			continue
+		}
+		if excerpt := Excerpt(file, strings.Split(content, "\n"), lines); excerpt != "" {
+			sections = append(sections, fmt.Sprintf("%s:\n```\n%s```", file.Path(), excerpt))
+		}
+	}
+	if len(sections) == 0 {
+		return ""
+	}
+	return contextIntroduction + "\n\n" + strings.Join(sections, "\n\n")
+}
+
+// Excerpt renders the lines of the new version of the file around each hunk,
+// with overlapping ranges merged and every line numbered.
+func Excerpt(file *diff.File, content []string, lines int) string {
+	type span struct{ from, to int }
+	var spans []span
+	for _, hunk := range file.Hunks {
+		from := max(hunk.NewStart-lines, 1)
+		to := min(hunk.NewStart+max(hunk.NewLines, 1)-1+lines, len(content))
+		if from > to {
+			continue
+		}
+		if n := len(spans); n > 0 && from <= spans[n-1].to+1 {
+			spans[n-1].to = max(spans[n-1].to, to)
+			continue
+		}
+		spans = append(spans, span{from, to})
+	}
+
+	var b strings.Builder
+	for i, s := range spans {
+		if i > 0 {
+			b.WriteString("...\n")
+		}
+		for line := s.from; line <= s.to; line++ {
+			fmt.Fprintf(&b, "%5d | %s\n", line, content[line-1])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package roast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+)
+
+func TestDiffBudget(t *testing.T) {
+	contents := map[string]string{"main.go": "package main\n"}
+	tests := []struct {
+		name    string
+		builder PromptBuilder
+		budget  int
+		want    int
+	}{
+		{"nothing else", PromptBuilder{}, 1000, 1000},
+		{"surrounding code", PromptBuilder{Contents: contents, ContextLines: 10}, 1000, 750},
+		{"contents without context lines", PromptBuilder{Contents: contents}, 1000, 1000},
+		{"context lines without contents", PromptBuilder{ContextLines: 10}, 1000, 1000},
+		{"preamble", PromptBuilder{Preamble: strings.Repeat("x", 400)}, 1000, 900},
+		{"preamble and surrounding code", PromptBuilder{Preamble: strings.Repeat("x", 400), Contents: contents, ContextLines: 10}, 1000, 675},
+		{"intent", PromptBuilder{Intent: &Intent{Title: "Fix"}}, 1000, 1000 - diff.EstimateTokens(Intent{Title: "Fix"}.String())},
+		{"overhead over budget", PromptBuilder{Preamble: strings.Repeat("x", 8000)}, 1000, 1},
+		{"overhead over budget with surrounding code", PromptBuilder{Preamble: strings.Repeat("x", 8000), Contents: contents, ContextLines: 10}, 1000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.builder.DiffBudget(tt.budget); got != tt.want {
+				t.Errorf("DiffBudget(%d) = %d, want %d", tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+// numbered returns the content of a file with n lines reading "line 1" to
+// "line n".
+func numbered(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+const oneLineDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -50,1 +50,1 @@
+-old
++line 50
+`
+
+func TestBuildHalvesContext(t *testing.T) {
+	builder := PromptBuilder{Contents: map[string]string{"main.go": numbered(100)}, ContextLines: 20}
+	chunk := diff.Chunk{Files: []string{"main.go"}, Text: oneLineDiff}
+	files, err := diff.Parse(oneLineDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := diff.EstimateTokens(oneLineDiff)
+
+	tests := []struct {
+		name  string
+		lines int
+	}{
+		{"everything fits", 20},
+		{"halved once", 10},
+		{"halved twice", 5},
+		{"halved down to one line", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Just enough room for the surrounding code at this many lines
+			budget := base + diff.EstimateTokens(builder.surroundings(files, tt.lines))
+			prompt := builder.Build(chunk, 1, 1, budget)
+			if !strings.Contains(prompt, fmt.Sprintf("%5d | line %d\n", 50-tt.lines, 50-tt.lines)) {
+				t.Errorf("prompt does not start the excerpt %d lines above the hunk:\n%s", tt.lines, prompt)
+			}
+			if strings.Contains(prompt, fmt.Sprintf("%5d | ", 50-tt.lines-1)) {
+				t.Errorf("prompt has more than %d lines of context:\n%s", tt.lines, prompt)
+			}
+		})
+	}
+
+	t.Run("nothing fits", func(t *testing.T) {
+		prompt := builder.Build(chunk, 1, 1, base)
+		if strings.Contains(prompt, contextIntroduction) {
+			t.Errorf("prompt has surrounding code despite no room for it:\n%s", prompt)
+		}
+		if !strings.HasSuffix(prompt, oneLineDiff) {
+			t.Errorf("prompt lost the diff:\n%s", prompt)
+		}
+	})
+}
+
+func TestBuildParts(t *testing.T) {
+	chunk := diff.Chunk{Files: []string{"main.go"}, Text: oneLineDiff}
+	builder := PromptBuilder{Preamble: "Previously", Intent: &Intent{Title: "Fix"}}
+
+	prompt := builder.Build(chunk, 2, 3, 1000)
+	want := Intent{Title: "Fix"}.String() + "\n\nPreviously\n\nThis is part 2 of 3 of the diff.\n\n" + oneLineDiff
+	if prompt != want {
+		t.Errorf("Build() = %q, want %q", prompt, want)
+	}
+	if prompt := (PromptBuilder{}).Build(chunk, 1, 1, 1000); prompt != oneLineDiff {
+		t.Errorf("Build() of the only part = %q, want the diff alone", prompt)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	content := strings.Split(numbered(30), "\n")
+	tests := []struct {
+		name  string
+		hunks []diff.Hunk
+		lines int
+		want  [][2]int
+	}{
+		{"single hunk", []diff.Hunk{{NewStart: 10, NewLines: 1}}, 2, [][2]int{{8, 12}}},
+		{"multi-line hunk", []diff.Hunk{{NewStart: 10, NewLines: 3}}, 2, [][2]int{{8, 14}}},
+		{"pure deletion", []diff.Hunk{{NewStart: 10, NewLines: 0}}, 1, [][2]int{{9, 11}}},
+		{"clamped to the start", []diff.Hunk{{NewStart: 2, NewLines: 1}}, 5, [][2]int{{1, 7}}},
+		{"clamped to the end", []diff.Hunk{{NewStart: 29, NewLines: 1}}, 5, [][2]int{{24, 30}}},
+		{"overlapping hunks merged", []diff.Hunk{{NewStart: 10, NewLines: 1}, {NewStart: 13, NewLines: 1}}, 2, [][2]int{{8, 15}}},
+		{"adjacent hunks merged", []diff.Hunk{{NewStart: 10, NewLines: 1}, {NewStart: 15, NewLines: 1}}, 2, [][2]int{{8, 17}}},
+		{"distant hunks apart", []diff.Hunk{{NewStart: 5, NewLines: 1}, {NewStart: 20, NewLines: 1}}, 2, [][2]int{{3, 7}, {18, 22}}},
+		{"hunk past the end", []diff.Hunk{{NewStart: 40, NewLines: 1}}, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []string
+			for _, span := range tt.want {
+				var b strings.Builder
+				for line := span[0]; line <= span[1]; line++ {
+					fmt.Fprintf(&b, "%5d | line %d\n", line, line)
+				}
+				want = append(want, b.String())
+			}
+			file := &diff.File{NewPath: "main.go", Hunks: tt.hunks}
+			if got := Excerpt(file, content, tt.lines); got != strings.Join(want, "...\n") {
+				t.Errorf("Excerpt() =\n%s\nwant\n%s", got, strings.Join(want, "...\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package roast
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+const conversationInstruction = `Someone is replying to your review of a pull request. You will be given what you said, the code it was about and the conversation so far. Answer the latest message in markdown: explain your reasoning, concede if they are right, and keep it short. Respond with the answer only, not JSON.`
+
+type Turn struct {
+	Role   string
+	Author string
+	Body   string
+}
+
+// FollowUp is everything the model needs to answer a reply to a roast. Finding
+// and DiffHunk are set for replies to an inline comment, Roast for replies to
+// the roast as a whole.
+type FollowUp struct {
+	Finding  *Finding
+	DiffHunk string
+	Roast    string
+	History  []Turn
+	Question Turn
+}
+
+func (f FollowUp) prompt() string {
+	var b strings.Builder
+	if f.Finding != nil {
+		b.WriteString(fmt.Sprintf("You left this comment on `%s`:\n\n%s\n\n", f.Finding.File, f.Finding.Body()))
+	}
+	if f.DiffHunk != "" {
+		b.WriteString("It was about this part of the diff:\n\n```diff\n" + strings.TrimSpace(f.DiffHunk) + "\n```\n\n")
+	}
+	if f.Roast != "" {
+		b.WriteString("Your review of the pull request was:\n\n" + strings.TrimSpace(f.Roast) + "\n\n")
+	}
+	if len(f.History) > 0 {
+		b.WriteString("The conversation so far:\n\n")
+		for _, turn := range f.History {
+			b.WriteString(turn.label() + ": " + strings.TrimSpace(turn.Body) + "\n\n")
+		}
+	}
+	b.WriteString("The message to answer, from " + f.Question.label() + ":\n\n" + strings.TrimSpace(f.Question.Body))
+	return b.String()
+}
+
+func (t Turn) label() string {
+	if t.Role == RoleAssistant {
+		return "You"
+	}
+	if t.Author != "" {
+		return "@" + t.Author
+	}
+	return "The user"
+}
+
+// Conversation is the system prompt for answering replies to a roast.
+func (i Instructions) Conversation() string {
+	parts := []string{"You are a code review assistant. " + i.persona(), conversationInstruction}
+	if i.Language != "" {
+		parts = append(parts, "Write the answer in "+i.Language+".")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Answer asks the model to respond to a reply to the roast. An empty answer
+// comes back along with the error so what it used can still be recorded.
+func (e *Engine) Answer(ctx context.Context, followUp FollowUp) (*llm.Response, error) {
+	resp, err := e.Provider.Generate(ctx, llm.Request{
+		SystemInstruction: e.Instructions.Conversation(),
+		Prompt:            followUp.prompt(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.Text) == "" {
+		return resp, fmt.Errorf("AI answer returned empty result")
+	}
+	return resp, nil
+}
@@ -0,0 +1,333 @@
+package roast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+)
+
+const (
+	defaultChunkTokens = 60000
+	defaultWorkers     = 4
+	// minDiffTokens is the least room a chunk must leave for the diff once the
+	// instructions and context are in
+	minDiffTokens = 500
+)
+
+// ErrTooLarge is returned when a prompt cannot be made to fit the chunk budget.
+var ErrTooLarge = errors.New("prompt does not fit the chunk budget")
+
+const reduceInstruction = `
+
+You will now be given several partial reviews of the same pull request, each written about a different part of the diff. Merge them into one review in the same JSON format: write a single summary covering the whole change, keep every distinct finding, and drop findings that repeat the same point about the same line.`
+
+type Options struct {
+	ChunkTokens int
+	Workers     int
+}
+
+func OptionsFromEnv() Options {
+	return Options{
+		ChunkTokens: envInt("AI_CHUNK_TOKENS", defaultChunkTokens),
+		Workers:     envInt("AI_REVIEW_WORKERS", defaultWorkers),
+	}
+}
+
+type Plan struct {
+	Chunks       [][]string
+	FailedChunks int
+	SkippedFiles []diff.SkippedFile
+}
+
+type Result struct {
+	Review *Review
+	Model  string
+	Plan   Plan
+	// Usage adds up every model call, with Latency the wall time of the run
+	Usage llm.Usage
+}
+
+type Engine struct {
+	Provider llm.Provider
+	Options  Options
+	// Instructions shape the system prompt; the zero value is the classic roast
+	Instructions Instructions
+	// Prompt turns chunks of the diff into prompts
+	Prompt PromptBuilder
+}
+
+// Run reviews the diff one chunk at a time on a bounded pool of workers and
+// then merges the partial reviews into a single roast.
+func (e *Engine) Run(ctx context.Context, files []diff.File) (*Result, error) {
+	started := time.Now()
+	system, budget, chunks, skipped, err := e.split(files)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Plan: Plan{SkippedFiles: skipped}}
+	for _, chunk := range chunks {
+		result.Plan.Chunks = append(result.Plan.Chunks, chunk.Files)
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no reviewable changes in diff")
+	}
+	log.Printf("Reviewing diff in %d chunks (%d skipped)", len(chunks), len(skipped))
+
+	partials := make([]*Review, len(chunks))
+	models := make([]string, len(chunks))
+	usages := make([]llm.Usage, len(chunks))
+	errs := make([]error, len(chunks))
+
+	workers := max(e.Options.Workers, 1)
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			resp, err := e.Provider.Generate(ctx, llm.Request{
+				SystemInstruction: system,
+				Prompt:            e.Prompt.Build(chunk, i+1, len(chunks), budget),
+				JSON:              true,
+				Schema:            ReviewSchema,
+			})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			usages[i] = resp.Usage
+			if resp.Text == "" {
+				errs[i] = fmt.Errorf("AI analysis returned empty result")
+				return
+			}
+			partials[i] = ParseReview(resp.Text)
+			models[i] = resp.Model
+		}()
+	}
+	wg.Wait()
+
+	var reviews []*Review
+	for i := range chunks {
+		result.Usage = result.Usage.Add(usages[i])
+		if errs[i] != nil {
+			log.Printf("Failed to review chunk %d of %d: %v", i+1, len(chunks), errs[i])
+			result.Plan.FailedChunks++
+			continue
+		}
+		reviews = append(reviews, partials[i])
+		result.Model = models[i]
+	}
+	if len(reviews) == 0 {
+		// The result still carries what the failed calls used
+		result.Usage.Latency = time.Since(started)
+		return result, fmt.Errorf("Unable to generate AI analysis: %w", errs[0])
+	}
+
+	if len(reviews) == 1 {
+		result.Review = reviews[0]
+		result.Usage.Latency = time.Since(started)
+		return result, nil
+	}
+
+	merged, model, usage, err := e.reduce(ctx, reviews)
+	result.Usage = result.Usage.Add(usage)
+	if err != nil {
+		log.Printf("Failed to merge %d partial reviews, concatenating instead: %v", len(reviews), err)
+		merged = concatReviews(reviews)
+	} else {
+		result.Model = model
+	}
+	merged.Findings = dedupeFindings(merged.Findings)
+	result.Review = merged
+	result.Usage.Latency = time.Since(started)
+	return result, nil
+}
+
+// Estimate is roughly how many prompt tokens a run over the files sends:
+// every chunk prompt with its instructions, though not the merge step.
+func (e *Engine) Estimate(files []diff.File) (int, error) {
+	system, budget, chunks, _, err := e.split(files)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for i, chunk := range chunks {
+		total += diff.EstimateTokens(system) + diff.EstimateTokens(e.Prompt.Build(chunk, i+1, len(chunks), budget))
+	}
+	return total, nil
+}
+
+// split cuts the diff into chunks that fit the chunk budget once the system
+// instruction is taken out of it. It fails rather than skipping every hunk
+// when the instructions and context leave next to no room for the diff.
+func (e *Engine) split(files []diff.File) (string, int, []diff.Chunk, []diff.SkippedFile, error) {
+	system := e.Instructions.System()
+	budget := max(e.Options.ChunkTokens-diff.EstimateTokens(system), 0)
+	room := e.Prompt.DiffBudget(budget)
+	if room < minDiffTokens {
+		return "", 0, nil, nil, fmt.Errorf("%w: the instructions and context leave %d of %d tokens for the diff, raise AI_CHUNK_TOKENS", ErrTooLarge, room, e.Options.ChunkTokens)
+	}
+	chunks, skipped := diff.Split(files, room)
+	return system, budget, chunks, skipped, nil
+}
+
+// Explain asks the model to walk through the changes made to a single file,
+// as long as they fit the chunk budget. Like Answer, an empty explanation
+// comes back along with the error.
+func (e *Engine) Explain(ctx context.Context, file diff.File) (*llm.Response, error) {
+	system, prompt := e.Instructions.Explain(), file.String()
+	if tokens := diff.EstimateTokens(system) + diff.EstimateTokens(prompt); tokens > e.Options.ChunkTokens {
+		return nil, fmt.Errorf("%w: the changes to %s come to about %d of %d tokens", ErrTooLarge, file.Path(), tokens, e.Options.ChunkTokens)
+	}
+	resp, err := e.Provider.Generate(ctx, llm.Request{
+		SystemInstruction: system,
+		Prompt:            prompt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.Text) == "" {
+		return resp, fmt.Errorf("AI explanation returned empty result")
+	}
+	return resp, nil
+}
+
+// reduce merges the partial reviews in rounds. Each call gets as many reviews
+// as fit the chunk budget next to the instructions, so a large pull request is
+// merged a few partials at a time until one review is left.
+func (e *Engine) reduce(ctx context.Context, reviews []*Review) (*Review, string, llm.Usage, error) {
+	system := e.Instructions.System() + reduceInstruction
+	budget := e.Options.ChunkTokens - diff.EstimateTokens(system)
+	var model string
+	var usage llm.Usage
+	for len(reviews) > 1 {
+		batches, err := batchReviews(reviews, budget)
+		if err != nil {
+			return nil, model, usage, err
+		}
+		if len(batches) == len(reviews) {
+			return nil, model, usage, fmt.Errorf("partial reviews do not fit the %d token budget in pairs", e.Options.ChunkTokens)
+		}
+		next := make([]*Review, 0, len(batches))
+		for _, batch := range batches {
+			if len(batch) == 1 {
+				next = append(next, batch[0])
+				continue
+			}
+			merged, resp, err := e.merge(ctx, system, batch)
+			if resp != nil {
+				usage = usage.Add(resp.Usage)
+			}
+			if err != nil {
+				return nil, model, usage, err
+			}
+			model = resp.Model
+			next = append(next, merged)
+		}
+		reviews = next
+	}
+	return reviews[0], model, usage, nil
+}
+
+func (e *Engine) merge(ctx context.Context, system string, reviews []*Review) (*Review, *llm.Response, error) {
+	payload, err := json.Marshal(reviews)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode partial reviews: %w", err)
+	}
+	resp, err := e.Provider.Generate(ctx, llm.Request{
+		SystemInstruction: system,
+		Prompt:            string(payload),
+		JSON:              true,
+		Schema:            ReviewSchema,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	merged := ParseReview(resp.Text)
+	if strings.TrimSpace(merged.Summary) == "" {
+		return nil, resp, fmt.Errorf("merged review has no summary")
+	}
+	return merged, resp, nil
+}
+
+// batchReviews packs consecutive reviews into groups whose JSON fits budget.
+func batchReviews(reviews []*Review, budget int) ([][]*Review, error) {
+	var batches [][]*Review
+	var current []*Review
+	used := 0
+	for _, r := range reviews {
+		encoded, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode partial review: %w", err)
+		}
+		tokens := diff.EstimateTokens(string(encoded))
+		if len(current) > 0 && used+tokens > budget {
+			batches = append(batches, current)
+			current, used = nil, 0
+		}
+		current = append(current, r)
+		used += tokens
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
+
+func concatReviews(reviews []*Review) *Review {
+	merged := &Review{}
+	summaries := make([]string, 0, len(reviews))
+	var intents []string
+	for _, r := range reviews {
+		if s := strings.TrimSpace(r.Summary); s != "" {
+			summaries = append(summaries, s)
+		}
+		if s := strings.TrimSpace(r.Intent); s != "" {
+			intents = append(intents, s)
+		}
+		merged.Findings = append(merged.Findings, r.Findings...)
+	}
+	merged.Summary = strings.Join(summaries, "\n\n")
+	merged.Intent = strings.Join(intents, "\n\n")
+	return merged
+}
+
+func dedupeFindings(findings []Finding) []Finding {
+	seen := make(map[string]bool, len(findings))
+	out := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		key := fmt.Sprintf("%s:%d:%s", f.File, f.Line, strings.ToLower(strings.Join(strings.Fields(f.Message), " ")))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+func envInt(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring invalid %s %q", name, v)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,241 @@
+package roast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+)
+
+// fakeProvider answers review and merge requests with the given functions
+// and records every request it gets.
+type fakeProvider struct {
+	review func(req llm.Request) (*llm.Response, error)
+	merge  func(req llm.Request) (*llm.Response, error)
+
+	mu       sync.Mutex
+	requests []llm.Request
+}
+
+func (p *fakeProvider) Name() string  { return "fake" }
+func (p *fakeProvider) Model() string { return "fake-model" }
+
+func (p *fakeProvider) Generate(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+	if strings.HasSuffix(req.SystemInstruction, reduceInstruction) {
+		return p.merge(req)
+	}
+	return p.review(req)
+}
+
+func (p *fakeProvider) merges() int {
+	n := 0
+	for _, req := range p.requests {
+		if strings.HasSuffix(req.SystemInstruction, reduceInstruction) {
+			n++
+		}
+	}
+	return n
+}
+
+func reply(review Review) (*llm.Response, error) {
+	text, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.Response{Text: string(text), Model: "fake-model", Usage: llm.Usage{TotalTokens: 10}}, nil
+}
+
+// reviewEach flags the first file of every chunk it is shown, plus the same
+// finding about shared.go each time.
+func reviewEach(req llm.Request) (*llm.Response, error) {
+	path := strings.TrimPrefix(strings.Fields(req.Prompt[strings.Index(req.Prompt, "diff --git"):])[2], "a/")
+	return reply(Review{
+		Summary: "Review of " + path,
+		Findings: []Finding{
+			{File: path, Line: 1, Severity: "low", Category: "style", Message: "Rename this"},
+			{File: "shared.go", Line: 1, Severity: "low", Category: "style", Message: "Same  point"},
+		},
+	})
+}
+
+// bigFiles are n files whose diffs each need about 750 tokens.
+func bigFiles(t *testing.T, n int) []diff.File {
+	t.Helper()
+	var raw strings.Builder
+	for i := range n {
+		path := fmt.Sprintf("file%d.go", i)
+		fmt.Fprintf(&raw, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -0,0 +1,150 @@\n", path, path, path, path)
+		for line := range 150 {
+			fmt.Fprintf(&raw, "+// line %04d of %s\n", line+1, path)
+		}
+	}
+	files, err := diff.Parse(raw.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// oneFilePerChunk is a chunk budget that fits any one of bigFiles but not two.
+func oneFilePerChunk() int {
+	return diff.EstimateTokens(Instructions{}.System()) + 1000
+}
+
+func TestRunTooLarge(t *testing.T) {
+	provider := &fakeProvider{review: reviewEach}
+	engine := Engine{
+		Provider: provider,
+		Options:  Options{ChunkTokens: diff.EstimateTokens(Instructions{}.System()) + 100, Workers: 1},
+	}
+	files := bigFiles(t, 1)
+
+	if _, err := engine.Run(context.Background(), files); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Run() error = %v, want ErrTooLarge", err)
+	}
+	if _, err := engine.Estimate(files); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Estimate() error = %v, want ErrTooLarge", err)
+	}
+	if _, err := engine.Explain(context.Background(), files[0]); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Explain() error = %v, want ErrTooLarge", err)
+	}
+	if len(provider.requests) != 0 {
+		t.Errorf("provider called %d times, want none", len(provider.requests))
+	}
+
+	// A system prompt over the whole budget must not wrap around
+	engine.Options.ChunkTokens = 10
+	if _, err := engine.Run(context.Background(), files); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Run() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestRunMergesChunks(t *testing.T) {
+	provider := &fakeProvider{
+		review: reviewEach,
+		merge: func(req llm.Request) (*llm.Response, error) {
+			var partials []Review
+			if err := json.Unmarshal([]byte(req.Prompt), &partials); err != nil {
+				return nil, err
+			}
+			var findings []Finding
+			for _, p := range partials {
+				findings = append(findings, p.Findings...)
+			}
+			return reply(Review{Summary: fmt.Sprintf("Merged %d reviews", len(partials)), Findings: findings})
+		},
+	}
+	engine := Engine{Provider: provider, Options: Options{ChunkTokens: oneFilePerChunk(), Workers: 2}}
+
+	result, err := engine.Run(context.Background(), bigFiles(t, 3))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Plan.Chunks) != 3 {
+		t.Errorf("Plan.Chunks = %v, want a chunk per file", result.Plan.Chunks)
+	}
+	if result.Review.Summary != "Merged 3 reviews" {
+		t.Errorf("Summary = %q, want the merged one", result.Review.Summary)
+	}
+	// One finding per file, the shared one kept once
+	if len(result.Review.Findings) != 4 {
+		t.Errorf("Findings = %+v, want 4", result.Review.Findings)
+	}
+	if result.Usage.TotalTokens != 40 || result.Model != "fake-model" {
+		t.Errorf("Usage = %+v, Model = %q, want three reviews and a merge", result.Usage, result.Model)
+	}
+}
+
+func TestReduceInRounds(t *testing.T) {
+	summary := strings.Repeat("x", 400)
+	partial := &Review{Summary: summary, Findings: []Finding{}}
+	encoded, _ := json.Marshal(partial)
+	system := Instructions{}.System() + reduceInstruction
+	provider := &fakeProvider{
+		merge: func(req llm.Request) (*llm.Response, error) {
+			return reply(Review{Summary: summary, Findings: []Finding{}})
+		},
+	}
+	// Room for two partial reviews per call, not three
+	engine := Engine{Provider: provider, Options: Options{ChunkTokens: diff.EstimateTokens(system) + 2*diff.EstimateTokens(string(encoded)) + 1}}
+
+	merged, _, usage, err := engine.reduce(context.Background(), []*Review{partial, partial, partial, partial})
+	if err != nil {
+		t.Fatalf("reduce() error = %v", err)
+	}
+	if merged.Summary != summary {
+		t.Errorf("reduce() summary = %q", merged.Summary)
+	}
+	if n := provider.merges(); n != 3 {
+		t.Errorf("%d merge calls, want 3 for four reviews two at a time", n)
+	}
+	if usage.TotalTokens != 30 {
+		t.Errorf("usage = %+v, want every merge call", usage)
+	}
+	for _, req := range provider.requests {
+		var batch []Review
+		if err := json.Unmarshal([]byte(req.Prompt), &batch); err != nil || len(batch) != 2 {
+			t.Errorf("merge call got %d reviews, want 2", len(batch))
+		}
+	}
+
+	engine.Options.ChunkTokens = diff.EstimateTokens(system) + diff.EstimateTokens(string(encoded))
+	if _, _, _, err := engine.reduce(context.Background(), []*Review{partial, partial}); err == nil {
+		t.Error("reduce() merged reviews that do not fit the budget in pairs")
+	}
+}
+
+func TestRunFallsBackToConcatenating(t *testing.T) {
+	tests := []struct {
+		name  string
+		merge func(req llm.Request) (*llm.Response, error)
+		usage int64
+	}{
+		{"merge fails", func(req llm.Request) (*llm.Response, error) { return nil, errors.New("boom") }, 30},
+		{"merge without summary", func(req llm.Request) (*llm.Response, error) { return reply(Review{}) }, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{review: reviewEach, merge: tt.merge}
+			engine := Engine{Provider: provider, Options: Options{ChunkTokens: oneFilePerChunk(), Workers: 1}}
+
+			result, err := engine.Run(context.Background(), bigFiles(t, 3))
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			want := "Review of file0.go\n\nReview of file1.go\n\nReview of file2.go"
+			if result.Review.Summary != want {
+				t.Errorf("Summary = %q, want %q", result.Review.Summary, want)
+			}
+			if len(result.Review.Findings) != 4 {
+				t.Errorf("Findings = %+v, want 4", result.Review.Findings)
+			}
+			if result.Usage.TotalTokens != tt.usage {
+				t.Errorf("Usage.TotalTokens = %d, want %d", result.Usage.TotalTokens, tt.usage)
+			}
+		})
+	}
+}
+
+func TestRunAllChunksFail(t *testing.T) {
+	provider := &fakeProvider{review: func(req llm.Request) (*llm.Response, error) {
+		return &llm.Response{Usage: llm.Usage{TotalTokens: 10}}, nil
+	}}
+	engine := Engine{Provider: provider, Options: Options{ChunkTokens: oneFilePerChunk(), Workers: 2}}
+
+	result, err := engine.Run(context.Background(), bigFiles(t, 2))
+	if err == nil {
+		t.Fatal("Run() succeeded without a single review")
+	}
+	if result == nil || result.Usage.TotalTokens != 20 || result.Plan.FailedChunks != 2 {
+		t.Errorf("Run() = %+v, want the usage of both failed chunks", result)
+	}
+}
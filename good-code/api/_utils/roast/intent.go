@@ -0,0 +1,88 @@
+package roast
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxLinkedIssues    = 5
+	maxDescriptionSize = 4000
+	maxIssueSize       = 2000
+)
+
+// closingKeyword matches the keywords GitHub uses to link a pull request to
+// the issues it closes, e.g. "Fixes #12".
+var closingKeyword = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+#(\d+)\b`)
+
+type LinkedIssue struct {
+	Number int
+	Title  string
+	Body   string
+}
+
+// Intent is what the pull request says it does: its title, its description
+// and the issues it claims to close.
+type Intent struct {
+	Title  string
+	Body   string
+	Issues []LinkedIssue
+}
+
+// LinkedIssueNumbers lists the issues the description closes, in order of
+// appearance and without duplicates.
+func LinkedIssueNumbers(body string) []int {
+	var numbers []int
+	seen := map[int]bool{}
+	for _, match := range closingKeyword.FindAllStringSubmatch(body, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+		if len(numbers) == maxLinkedIssues {
+			break
+		}
+	}
+	return numbers
+}
+
+// Described reports whether the pull request has a description at all.
+func (i Intent) Described() bool {
+	return strings.TrimSpace(i.Body) != ""
+}
+
+// String renders the intent as the opening section of every chunk prompt.
+func (i Intent) String() string {
+	var b strings.Builder
+	b.WriteString("The pull request is titled \"" + strings.TrimSpace(i.Title) + "\".")
+	if i.Described() {
+		b.WriteString(" Its description reads:\n\n" + clip(strings.TrimSpace(i.Body), maxDescriptionSize))
+	} else {
+		b.WriteString(" It has no description.")
+	}
+	for _, issue := range i.Issues {
+		b.WriteString(fmt.Sprintf("\n\nIt claims to close issue #%d, \"%s\":\n\n%s", issue.Number, strings.TrimSpace(issue.Title), clip(strings.TrimSpace(issue.Body), maxIssueSize)))
+	}
+	return b.String()
+}
+
+// MissingDescription is the finding reported on pull requests without a
+// description, whatever the model thinks of the code.
+func MissingDescription() Finding {
+	return Finding{
+		Severity: "low",
+		Category: "documentation",
+		Message:  "This pull request has no description. Say what it changes and why, and link the issue it fixes, so reviewers do not have to reverse-engineer the intent from the diff.",
+	}
+}
+
+func clip(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return strings.ToValidUTF8(s[:size], "") + "\n\n[truncated]"
+}
@@ -0,0 +1,32 @@
+package roast
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLinkedIssueNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []int
+	}{
+		{"none", "Refactors the parser.", nil},
+		{"fixes", "Fixes #12", []int{12}},
+		{"every keyword", "close #1, closes #2, closed #3, fix #4, fixed #5", []int{1, 2, 3, 4, 5}},
+		{"resolves", "Resolves #7 and resolved #8", []int{7, 8}},
+		{"case and colon", "FIXES: #9", []int{9}},
+		{"order of appearance", "Closes #30\n\nAlso fixes #10", []int{30, 10}},
+		{"duplicates", "Fixes #3, fixes #3 again", []int{3}},
+		{"bare references", "See #4 and #5", nil},
+		{"keyword inside a word", "prefixes #6", nil},
+		{"capped", "fixes #1 fixes #2 fixes #3 fixes #4 fixes #5 fixes #6", []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LinkedIssueNumbers(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("LinkedIssueNumbers(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package roast
+
+import (
+	"strings"
+)
+
+// PromptVersion identifies the shared prompt template. Bump it whenever
+// baseInstruction or formatInstruction changes meaningfully.
+const PromptVersion = 4
+
+const DefaultPersona = "You should be sarcastic and condescending, but still helpful and provide useful feedback that is factually accurate to the best of your knowledge."
+
+var Checks = map[string]string{
+	"bugs":          "correctness bugs and edge cases",
+	"security":      "security vulnerabilities",
+	"performance":   "performance problems",
+	"style":         "readability and code style",
+	"tests":         "missing or inadequate tests",
+	"documentation": "missing or misleading documentation",
+}
+
+const baseInstruction = "You are a code review assistant. You will be given a diff of a pull request. Your task is to review the code and provide feedback."
+
+const formatInstruction = `Respond with a single JSON object containing a "summary" with your overall review in markdown, an "intent" with your assessment in markdown of whether the change actually does what its title, description and linked issues say it does, and a list of "findings". Each finding names the "file" as shown in the diff, the "line" (and "end_line" if it spans several lines) in the new version of the file, a "severity" (critical, high, medium, low or info), a "category" (bug, security, performance, style, tests, documentation or maintainability), a markdown "message" and, when there is an obvious fix, a "suggestion".
+Only reference lines that were added or shown as context in the diff. Put general remarks in the summary instead of the findings.`
+
+type Instructions struct {
+	// Persona is the persona's own instructions on how to voice the review
+	Persona  string
+	Language string
+	Checks   []string
+}
+
+const explainInstruction = `You will be given the diff of a single file from a pull request. Explain in markdown what the change does and why it was likely made, then point out anything that deserves a closer look. Respond with the explanation only, not JSON.`
+
+func (i Instructions) persona() string {
+	if persona := strings.TrimSpace(i.Persona); persona != "" {
+		return persona
+	}
+	return DefaultPersona
+}
+
+func (i Instructions) System() string {
+	parts := []string{baseInstruction + " " + i.persona()}
+	if len(i.Checks) > 0 {
+		focus := make([]string, 0, len(i.Checks))
+		for _, check := range i.Checks {
+			if description, ok := Checks[check]; ok {
+				focus = append(focus, description)
+			}
+		}
+		if len(focus) > 0 {
+			parts = append(parts, "Only comment on the following: "+strings.Join(focus, ", ")+".")
+		}
+	}
+	if i.Language != "" {
+		parts = append(parts, "Write the summary and every comment in "+i.Language+".")
+	}
+	parts = append(parts, formatInstruction)
+
+	return strings.Join(parts, "\n\n")
+}
+
+// Explain is the system prompt for explaining the changes to a single file.
+func (i Instructions) Explain() string {
+	parts := []string{"You are a code review assistant. " + i.persona(), explainInstruction}
+	if i.Language != "" {
+		parts = append(parts, "Write the explanation in "+i.Language+".")
+	}
+	return strings.Join(parts, "\n\n")
+}
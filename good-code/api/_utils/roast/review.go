@@ -0,0 +1,303 @@
+package roast
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+)
+
+// IncrementalPreamble introduces the delta of an incremental review together
+// with what was said about the pull request last time and every finding raised
+// by earlier roasts, so ones that are still open are not reported as new.
+func IncrementalPreamble(previous string, raised []Finding) string {
+	var b strings.Builder
+	b.WriteString("This is an incremental review. The diff below only contains the commits pushed since your last review of this pull request. " +
+		"Focus on the new changes and do not repeat earlier points unless the new commits failed to address them. " +
+		"Your previous review was:\n\n" + previous)
+	if len(raised) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n\nThese findings were raised by earlier reviews of this pull request and have not been marked as fixed. " +
+		"Do not report them again as new findings. If the new commits touch one without fixing it, report it once more with a message starting with \"Still open:\".\n")
+	for _, f := range raised {
+		fmt.Fprintf(&b, "\n- %s:%d [%s] %s", f.File, f.Line, f.Severity, f.Message)
+	}
+	return b.String()
+}
+
+var Severities = []string{"critical", "high", "medium", "low", "info"}
+
+// Conclusions of a roast, named after the GitHub check run conclusions.
+const (
+	ConclusionSuccess = "success"
+	ConclusionNeutral = "neutral"
+	ConclusionFailure = "failure"
+	ConclusionSkipped = "skipped"
+)
+
+// SeverityNever is a threshold that no finding reaches.
+const SeverityNever = "never"
+
+// AtLeast reports whether the severity is as serious as the threshold.
+func AtLeast(severity string, threshold string) bool {
+	i, j := slices.Index(Severities, severity), slices.Index(Severities, threshold)
+	return i >= 0 && j >= 0 && i <= j
+}
+
+// Conclusion grades the review by its most serious finding: failure if any
+// finding reaches failOn, neutral if any reaches neutralOn, success otherwise.
+func Conclusion(review *Review, failOn string, neutralOn string) string {
+	conclusion := ConclusionSuccess
+	for _, f := range review.Findings {
+		if AtLeast(f.Severity, failOn) {
+			return ConclusionFailure
+		}
+		if AtLeast(f.Severity, neutralOn) {
+			conclusion = ConclusionNeutral
+		}
+	}
+	return conclusion
+}
+
+var Categories = []string{"bug", "security", "performance", "style", "tests", "documentation", "maintainability"}
+
+type Finding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	EndLine    int    `json:"end_line,omitempty"`
+	Severity   string `json:"severity"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ReviewSchema constrains the model output to the Review shape.
+var ReviewSchema = &llm.Schema{
+	Type: "object",
+	Properties: map[string]*llm.Schema{
+		"summary": {Type: "string", Description: "Overall review in markdown"},
+		"intent":  {Type: "string", Description: "Whether the change does what its title, description and linked issues say it should, in markdown"},
+		"findings": {
+			Type: "array",
+			Items: &llm.Schema{
+				Type: "object",
+				Properties: map[string]*llm.Schema{
+					"file":       {Type: "string", Description: "Path of the file as shown in the diff"},
+					"line":       {Type: "integer", Description: "First line the finding applies to, in the new version of the file"},
+					"end_line":   {Type: "integer", Description: "Last line the finding applies to, if it spans several lines"},
+					"severity":   {Type: "string", Enum: Severities},
+					"category":   {Type: "string", Enum: Categories},
+					"message":    {Type: "string", Description: "Markdown explanation of the problem"},
+					"suggestion": {Type: "string", Description: "How to fix it, if there is an obvious fix"},
+				},
+				Required: []string{"file", "line", "severity", "category", "message"},
+			},
+		},
+	},
+	Required: []string{"summary", "intent", "findings"},
+}
+
+type Review struct {
+	Summary string `json:"summary"`
+	// Intent assesses the change against what the pull request says it does
+	Intent   string    `json:"intent,omitempty"`
+	Findings []Finding `json:"findings"`
+}
+
+// Overview is the summary followed by the assessment of the intent.
+func (r *Review) Overview() string {
+	summary := strings.TrimSpace(r.Summary)
+	if intent := strings.TrimSpace(r.Intent); intent != "" {
+		return summary + "\n\n**Does it do what it says?** " + intent
+	}
+	return summary
+}
+
+type AnchoredFinding struct {
+	Finding
+	Position int
+}
+
+// ParseReview decodes the model output. Models do not always honour the
+// requested format, so anything that is not valid JSON is kept as a plain
+// summary instead of failing the roast.
+func ParseReview(text string) *Review {
+	trimmed := strings.TrimSpace(text)
+	trimmed = strings.TrimPrefix(trimmed, "```json")
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	trimmed = strings.TrimSuffix(trimmed, "```")
+
+	var review Review
+	if err := json.Unmarshal([]byte(trimmed), &review); err != nil {
+		return &Review{Summary: text}
+	}
+	for i := range review.Findings {
+		review.Findings[i].normalize()
+	}
+	return &review
+}
+
+// normalize keeps whatever the model returned within the allowed values so
+// that filtering on them stays meaningful.
+func (f *Finding) normalize() {
+	f.Severity = strings.ToLower(strings.TrimSpace(f.Severity))
+	if !slices.Contains(Severities, f.Severity) {
+		f.Severity = "info"
+	}
+	f.Category = strings.ToLower(strings.TrimSpace(f.Category))
+	if !slices.Contains(Categories, f.Category) {
+		f.Category = "maintainability"
+	}
+	if f.EndLine < f.Line {
+		f.EndLine = f.Line
+	}
+}
+
+// Body renders the finding as the markdown of a single review comment.
+func (f Finding) Body() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("**%s** · %s\n\n", strings.ToUpper(f.Severity), f.Category))
+	b.WriteString(strings.TrimSpace(f.Message))
+	if s := strings.TrimSpace(f.Suggestion); s != "" {
+		b.WriteString("\n\n**Suggestion:** " + s)
+	}
+	return b.String()
+}
+
+// Anchor maps each finding onto a position in the pull request diff. Findings
+// pointing at files or lines outside the diff are returned separately so they
+// can be folded into the summary.
+func Anchor(review *Review, files []diff.File) ([]AnchoredFinding, []Finding) {
+	byPath := make(map[string]*diff.File, len(files))
+	for i := range files {
+		byPath[files[i].Path()] = &files[i]
+	}
+
+	var anchored []AnchoredFinding
+	var unanchored []Finding
+	for _, finding := range review.Findings {
+		file, ok := byPath[strings.TrimPrefix(finding.File, "b/")]
+		if !ok {
+			unanchored = append(unanchored, finding)
+			continue
+		}
+		position, ok := file.Position(finding.Line)
+		if !ok {
+			unanchored = append(unanchored, finding)
+			continue
+		}
+		anchored = append(anchored, AnchoredFinding{Finding: finding, Position: position})
+	}
+	return anchored, unanchored
+}
+
+// RenderSummary builds the body of the review, listing any findings that
+// could not be attached to a diff line.
+func RenderSummary(summary string, unanchored []Finding) string {
+	return render(summary, "Other findings", unanchored)
+}
+
+// RenderMarkdown renders the whole review as a single markdown document,
+// which is what gets stored on the roast.
+func RenderMarkdown(review *Review) string {
+	return render(review.Overview(), "Findings", review.Findings)
+}
+
+func render(summary string, title string, findings []Finding) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(summary))
+	if len(findings) > 0 {
+		b.WriteString("\n\n### " + title + "\n")
+		for _, f := range findings {
+			b.WriteString(fmt.Sprintf("\n- **%s** · %s · ", strings.ToUpper(f.Severity), f.Category))
+			b.WriteString(findingLocation(f))
+			b.WriteString(strings.TrimSpace(f.Message))
+			if s := strings.TrimSpace(f.Suggestion); s != "" {
+				b.WriteString(" _Suggestion:_ " + s)
+			}
+		}
+	}
+	return b.String()
+}
+
+// RenderSkipped lists the files left out of the review in a collapsed
+// section, so nobody mistakes silence for approval.
+func RenderSkipped(skipped []diff.SkippedFile) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("<details>\n<summary>Skipped %d file(s)</summary>\n\n", len(skipped)))
+	for _, f := range skipped {
+		b.WriteString(fmt.Sprintf("- `%s`: %s\n", f.Path, f.Reason))
+	}
+	b.WriteString("\n</details>")
+	return b.String()
+}
+
+// StickyMarker identifies the comment GoodCode keeps updating on a pull
+// request, so it can be told apart from anything a human wrote.
+const StickyMarker = "<!-- goodcode:roast -->"
+
+// maxCommentLength leaves some headroom below GitHub's 65536 character limit.
+const maxCommentLength = 60000
+
+type HistoryEntry struct {
+	HeadSHA   string
+	CreatedAt time.Time
+	Content   string
+}
+
+// RenderSticky renders the body of the sticky comment: the current roast
+// followed by a collapsed section with the previous roasts, newest first.
+// Older roasts are dropped once the comment would get too long for GitHub.
+func RenderSticky(current string, history []HistoryEntry) string {
+	var b strings.Builder
+	b.WriteString(StickyMarker + "\n")
+	b.WriteString(current)
+
+	var previous []string
+	length := b.Len()
+	for _, entry := range history {
+		section := fmt.Sprintf("#### %s (%s)\n\n%s\n", shortSHA(entry.HeadSHA), entry.CreatedAt.UTC().Format("2006-01-02 15:04 MST"), strings.TrimSpace(entry.Content))
+		if length+len(section) > maxCommentLength {
+			break
+		}
+		length += len(section)
+		previous = append(previous, section)
+	}
+	if len(previous) > 0 {
+		b.WriteString(fmt.Sprintf("\n\n<details>\n<summary>Previous roasts (%d)</summary>\n\n", len(previous)))
+		b.WriteString(strings.Join(previous, "\n"))
+		b.WriteString("\n</details>")
+	}
+	return b.String()
+}
+
+func shortSHA(sha string) string {
+	if sha == "" {
+		return "unknown commit"
+	}
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	return "`" + sha + "`"
+}
+
+func findingLocation(f Finding) string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.EndLine > f.Line:
+		return fmt.Sprintf("`%s:%d-%d`: ", f.File, f.Line, f.EndLine)
+	case f.Line > 0:
+		return fmt.Sprintf("`%s:%d`: ", f.File, f.Line)
+	default:
+		return fmt.Sprintf("`%s`: ", f.File)
+	}
+}
@@ -0,0 +1,186 @@
+package roast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	diff "github.com/chopstickleg/good-code/api/_utils/diff"
+)
+
+func TestIncrementalPreamble(t *testing.T) {
+	preamble := IncrementalPreamble("Too many globals.", nil)
+	if !strings.HasSuffix(preamble, "Your previous review was:\n\nToo many globals.") {
+		t.Errorf("IncrementalPreamble() = %q, want it to end with the previous review", preamble)
+	}
+	if strings.Contains(preamble, "Still open:") {
+		t.Error("IncrementalPreamble() lists raised findings when there are none")
+	}
+
+	preamble = IncrementalPreamble("Too many globals.", []Finding{
+		{File: "main.go", Line: 12, Severity: "high", Message: "Nil dereference"},
+		{File: "util.go", Line: 3, Severity: "low", Message: "Odd name"},
+	})
+	for _, want := range []string{"Too many globals.", "Still open:", "\n- main.go:12 [high] Nil dereference", "\n- util.go:3 [low] Odd name"} {
+		if !strings.Contains(preamble, want) {
+			t.Errorf("IncrementalPreamble() = %q, want it to contain %q", preamble, want)
+		}
+	}
+}
+
+func TestParseReview(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want *Review
+	}{
+		{
+			name: "plain JSON",
+			text: `{"summary": "Fine.", "findings": [{"file": "main.go", "line": 3, "severity": "high", "category": "bug", "message": "Off by one"}]}`,
+			want: &Review{Summary: "Fine.", Findings: []Finding{
+				{File: "main.go", Line: 3, EndLine: 3, Severity: "high", Category: "bug", Message: "Off by one"},
+			}},
+		},
+		{
+			name: "fenced",
+			text: "```json\n{\"summary\": \"Fine.\", \"intent\": \"It does.\", \"findings\": []}\n```",
+			want: &Review{Summary: "Fine.", Intent: "It does.", Findings: []Finding{}},
+		},
+		{
+			name: "values outside the schema",
+			text: `{"summary": "Meh.", "findings": [{"file": "a.go", "line": 9, "end_line": 4, "severity": " HIGH ", "category": "Security"}, {"file": "b.go", "line": 1, "severity": "apocalyptic", "category": "vibes"}]}`,
+			want: &Review{Summary: "Meh.", Findings: []Finding{
+				{File: "a.go", Line: 9, EndLine: 9, Severity: "high", Category: "security"},
+				{File: "b.go", Line: 1, EndLine: 1, Severity: "info", Category: "maintainability"},
+			}},
+		},
+		{
+			name: "not JSON",
+			text: "Your code is bad and you should feel bad.",
+			want: &Review{Summary: "Your code is bad and you should feel bad."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseReview(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseReview() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindingBody(t *testing.T) {
+	f := Finding{Severity: "high", Category: "bug", Message: " Off by one. ", Suggestion: "Use <="}
+	want := "**HIGH** · bug\n\nOff by one.\n\n**Suggestion:** Use <="
+	if got := f.Body(); got != want {
+		t.Errorf("Body() = %q, want %q", got, want)
+	}
+	f.Suggestion = ""
+	if got := f.Body(); strings.Contains(got, "Suggestion") {
+		t.Errorf("Body() = %q, want no suggestion", got)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	review := &Review{
+		Summary: "Needs work.\n",
+		Intent:  "Mostly.",
+		Findings: []Finding{
+			{File: "main.go", Line: 3, EndLine: 5, Severity: "high", Category: "bug", Message: "Off by one", Suggestion: "Use <="},
+			{File: "util.go", Line: 7, EndLine: 7, Severity: "low", Category: "style", Message: "Odd name"},
+			{Severity: "info", Category: "maintainability", Message: "Consider tests"},
+		},
+	}
+	want := "Needs work.\n\n**Does it do what it says?** Mostly.\n\n### Findings\n" +
+		"\n- **HIGH** · bug · `main.go:3-5`: Off by one _Suggestion:_ Use <=" +
+		"\n- **LOW** · style · `util.go:7`: Odd name" +
+		"\n- **INFO** · maintainability · Consider tests"
+	if got := RenderMarkdown(review); got != want {
+		t.Errorf("RenderMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+	if got := RenderMarkdown(&Review{Summary: "Fine."}); got != "Fine." {
+		t.Errorf("RenderMarkdown() without findings = %q", got)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	files, err := diff.Parse("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n package main\n+\n import \"fmt\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := &Review{Findings: []Finding{
+		{File: "b/main.go", Line: 2, Message: "in the diff"},
+		{File: "main.go", Line: 40, Message: "outside the hunks"},
+		{File: "other.go", Line: 1, Message: "not in the diff"},
+	}}
+
+	anchored, unanchored := Anchor(review, files)
+	if len(anchored) != 1 || anchored[0].Message != "in the diff" || anchored[0].Position != 2 {
+		t.Errorf("anchored = %+v, want the first finding at position 2", anchored)
+	}
+	if len(unanchored) != 2 || unanchored[0].Message != "outside the hunks" || unanchored[1].Message != "not in the diff" {
+		t.Errorf("unanchored = %+v, want the other two", unanchored)
+	}
+}
+
+func TestRenderSticky(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	if got := RenderSticky("Current roast", nil); got != StickyMarker+"\nCurrent roast" {
+		t.Errorf("RenderSticky() without history = %q", got)
+	}
+
+	got := RenderSticky("Current roast", []HistoryEntry{
+		{HeadSHA: "abcdef0123456", CreatedAt: at, Content: "Second roast\n"},
+		{CreatedAt: at.Add(-time.Hour), Content: "First roast"},
+	})
+	want := StickyMarker + "\nCurrent roast\n\n<details>\n<summary>Previous roasts (2)</summary>\n\n" +
+		"#### `abcdef0` (2024-05-01 12:30 UTC)\n\nSecond roast\n\n" +
+		"#### unknown commit (2024-05-01 11:30 UTC)\n\nFirst roast\n" +
+		"\n</details>"
+	if got != want {
+		t.Errorf("RenderSticky() =\n%s\nwant\n%s", got, want)
+	}
+
+	long := strings.Repeat("x", maxCommentLength/3)
+	got = RenderSticky("Current roast", []HistoryEntry{
+		{HeadSHA: "3333333", Content: long},
+		{HeadSHA: "2222222", Content: long},
+		{HeadSHA: "1111111", Content: long},
+	})
+	if len(got) > maxCommentLength {
+		t.Errorf("RenderSticky() is %d characters long, over %d", len(got), maxCommentLength)
+	}
+	if !strings.Contains(got, "Previous roasts (2)") || strings.Contains(got, "1111111") {
+		t.Errorf("RenderSticky() did not drop the oldest roast that does not fit")
+	}
+}
+
+func TestConclusion(t *testing.T) {
+	tests := []struct {
+		name       string
+		severities []string
+		failOn     string
+		neutralOn  string
+		want       string
+	}{
+		{"no findings", nil, "critical", "high", ConclusionSuccess},
+		{"below both", []string{"low", "medium"}, "critical", "high", ConclusionSuccess},
+		{"neutral", []string{"low", "high"}, "critical", "high", ConclusionNeutral},
+		{"failure", []string{"high", "critical"}, "critical", "high", ConclusionFailure},
+		{"lower thresholds", []string{"medium"}, "medium", "low", ConclusionFailure},
+		{"never fails", []string{"critical"}, SeverityNever, "high", ConclusionNeutral},
+		{"never anything", []string{"critical"}, SeverityNever, SeverityNever, ConclusionSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review := &Review{}
+			for _, severity := range tt.severities {
+				review.Findings = append(review.Findings, Finding{Severity: severity})
+			}
+			if got := Conclusion(review, tt.failOn, tt.neutralOn); got != tt.want {
+				t.Errorf("Conclusion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+
+	"gorm.io/gorm"
+)
+
+const (
+	GroupByMonth        = "month"
+	GroupByRepository   = "repository"
+	GroupByInstallation = "installation"
+)
+
+var groupColumns = map[string]struct {
+	selected string
+	grouped  string
+}{
+	GroupByMonth:        {"to_char(date_trunc('month', created_at), 'YYYY-MM') AS month", "month"},
+	GroupByRepository:   {"repo_id", "repo_id"},
+	GroupByInstallation: {"installation_id", "installation_id"},
+}
+
+// source puts roasts and the other model calls side by side, so filters on
+// repo_id, installation_id and created_at apply to both.
+const source = `(SELECT repo_id, installation_id, created_at, 1 AS roasts, 0 AS calls, prompt_tokens, candidate_tokens, total_tokens, estimated_cost_usd FROM ai_roasts
+	UNION ALL
+	SELECT repo_id, installation_id, created_at, 0 AS roasts, 1 AS calls, prompt_tokens, candidate_tokens, total_tokens, estimated_cost_usd FROM model_calls) AS spend`
+
+// Bucket is the spend on roasts and other model calls sharing a month,
+// repository or installation, depending on how they were grouped.
+type Bucket struct {
+	Month          string `json:"month,omitempty"`
+	RepoID         int64  `json:"repo_id,omitempty"`
+	InstallationID int64  `json:"installation_id,omitempty"`
+
+	Roasts int64 `json:"roasts"`
+	// Calls counts the model calls made outside roasts, such as answers
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CandidateTokens  int64   `json:"candidate_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	EstimatedCostUSD float64 `gorm:"column:estimated_cost_usd" json:"estimated_cost_usd"`
+}
+
+// ParseGroupBy turns a comma-separated list such as "repository,month" into
+// groupings Aggregate understands.
+func ParseGroupBy(value string) ([]string, error) {
+	var groupBy []string
+	for _, group := range strings.Split(value, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		if _, ok := groupColumns[group]; !ok {
+			return nil, fmt.Errorf("group_by must be made of %q, %q and %q", GroupByMonth, GroupByRepository, GroupByInstallation)
+		}
+		groupBy = append(groupBy, group)
+	}
+	return groupBy, nil
+}
+
+// Aggregate sums up the usage of the roasts and model calls matched by query,
+// one bucket per distinct combination of the groupings, or a single total
+// without any. Conditions on query must use unqualified column names.
+func Aggregate(query *gorm.DB, groupBy ...string) ([]Bucket, error) {
+	var selected, grouped []string
+	for _, group := range groupBy {
+		column, ok := groupColumns[group]
+		if !ok {
+			return nil, fmt.Errorf("unknown grouping %q", group)
+		}
+		selected = append(selected, column.selected)
+		grouped = append(grouped, column.grouped)
+	}
+	selected = append(selected,
+		"COALESCE(SUM(roasts), 0) AS roasts",
+		"COALESCE(SUM(calls), 0) AS calls",
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+		"COALESCE(SUM(candidate_tokens), 0) AS candidate_tokens",
+		"COALESCE(SUM(total_tokens), 0) AS total_tokens",
+		"COALESCE(SUM(estimated_cost_usd), 0) AS estimated_cost_usd",
+	)
+
+	query = query.Table(source).Select(strings.Join(selected, ", "))
+	if len(grouped) > 0 {
+		query = query.Group(strings.Join(grouped, ", ")).Order(strings.Join(grouped, ", "))
+	}
+	var buckets []Bucket
+	if err := query.Scan(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	return buckets, nil
+}
+
+// RecordCall stores what a model call outside a roast used and what it cost
+// going by the pricing table, only logging failures since the call itself
+// already went through.
+func RecordCall(conn *gorm.DB, call db.ModelCall, resp *llm.Response) {
+	call.Model = resp.Model
+	call.PromptTokens = resp.Usage.PromptTokens
+	call.CandidateTokens = resp.Usage.CandidateTokens
+	call.TotalTokens = resp.Usage.TotalTokens
+	call.LatencyMs = resp.Usage.Latency.Milliseconds()
+	if cost, ok := llm.PricingFromEnv().Cost(resp.Model, resp.Usage); ok {
+		call.EstimatedCostUSD = cost
+	}
+	if err := conn.Create(&call).Error; err != nil {
+		log.Printf("Failed to record %s call to %s in repo %d: %v", call.Kind, call.Model, call.RepoID, err)
+	}
+}
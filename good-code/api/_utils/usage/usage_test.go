@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"slices"
+	"testing"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	groupBy, err := ParseGroupBy(" repository, month,")
+	if err != nil || !slices.Equal(groupBy, []string{GroupByRepository, GroupByMonth}) {
+		t.Errorf("ParseGroupBy() = %q, %v", groupBy, err)
+	}
+	if groupBy, err := ParseGroupBy(""); err != nil || len(groupBy) != 0 {
+		t.Errorf("ParseGroupBy(\"\") = %q, %v, want nothing", groupBy, err)
+	}
+	if _, err := ParseGroupBy("repository,day"); err == nil {
+		t.Error("ParseGroupBy() accepted an unknown grouping")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	t.Setenv("AI_PRICING", "")
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&db.AiRoast{}, &db.ModelCall{}); err != nil {
+		t.Fatal(err)
+	}
+	conn.Create(&db.AiRoast{RepoID: 1, InstallationID: 9, PromptTokens: 100, CandidateTokens: 20, TotalTokens: 120, EstimatedCostUSD: 0.5})
+	conn.Create(&db.AiRoast{RepoID: 2, InstallationID: 9, PromptTokens: 200, CandidateTokens: 40, TotalTokens: 240, EstimatedCostUSD: 1})
+	RecordCall(conn, db.ModelCall{Kind: "answer", RepoID: 1, InstallationID: 9}, &llm.Response{
+		Model: "gpt-4o",
+		Usage: llm.Usage{PromptTokens: 1_000_000, CandidateTokens: 100_000, TotalTokens: 1_100_000},
+	})
+
+	var call db.ModelCall
+	conn.First(&call)
+	if call.Model != "gpt-4o" || call.TotalTokens != 1_100_000 || call.EstimatedCostUSD != 3.50 {
+		t.Errorf("RecordCall() saved %+v", call)
+	}
+
+	total, err := Aggregate(conn)
+	if err != nil {
+		t.Fatalf("Aggregate() error = %v", err)
+	}
+	want := Bucket{Roasts: 2, Calls: 1, PromptTokens: 1_000_300, CandidateTokens: 100_060, TotalTokens: 1_100_360, EstimatedCostUSD: 5}
+	if len(total) != 1 || total[0] != want {
+		t.Errorf("Aggregate() = %+v, want %+v", total, want)
+	}
+
+	byRepo, err := Aggregate(conn.Where("installation_id = ?", 9), GroupByRepository)
+	if err != nil {
+		t.Fatalf("Aggregate() error = %v", err)
+	}
+	if len(byRepo) != 2 || byRepo[0].RepoID != 1 || byRepo[0].Roasts != 1 || byRepo[0].Calls != 1 || byRepo[1].RepoID != 2 || byRepo[1].TotalTokens != 240 {
+		t.Errorf("Aggregate() by repository = %+v", byRepo)
+	}
+
+	if _, err := Aggregate(conn, "day"); err == nil {
+		t.Error("Aggregate() accepted an unknown grouping")
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// ForgotPasswordHandler emails a password reset link. Like resending the
+// verification email, it answers the same whether or not the account exists.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("POST")(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{Email: req.Email}).First(&user).Error
+		if err == nil && user.Enabled {
+			last, err := authentication.LastIssued(conn, user.ID, db.TokenResetPassword)
+			if err != nil {
+				log.Printf("Error checking last password reset email of user %d: %v", user.ID, err)
+			} else if time.Since(last) < authentication.ResendInterval {
+				log.Printf("Not resending password reset email to user %d, last one was sent at %s", user.ID, last)
+			} else if err := authentication.SendPasswordReset(r.Context(), conn, user); err != nil {
+				log.Printf("Error sending password reset email to user %d: %v", user.ID, err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
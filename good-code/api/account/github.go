@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// GitHubAccountHandler reports whether the account is linked to GitHub and
+// unlinks it on DELETE. Linking goes through /api/auth/github/login?link=true.
+func GitHubAccountHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("GET", "DELETE")(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+		userId, err := middleware.Authenticate(w, r, cookie.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		if err := conn.Where(&db.UserLogin{ID: userId}).First(&user).Error; err != nil {
+			log.Printf("Error loading user %d: %v", userId, err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			err = authentication.UnlinkGitHub(conn, &user)
+			switch {
+			case errors.Is(err, authentication.ErrNoPassword):
+				http.Error(w, "Set a password before unlinking GitHub, it is the only way to sign in to this account", http.StatusConflict)
+				return
+			case err != nil:
+				log.Printf("Error unlinking GitHub from user %d: %v", userId, err)
+				http.Error(w, "Failed to unlink GitHub account", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]any{
+			"linked":       user.GithubID != nil,
+			"github_id":    user.GithubID,
+			"has_password": len(user.Password) > 0,
+		})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -3,12 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
-	"os"
-	"time"
 
 	db "github.com/chopstickleg/good-code/api/_db"
 	middleware "github.com/chopstickleg/good-code/api/_middleware"
-	"github.com/dgrijalva/jwt-go"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
 	"gorm.io/gorm"
 
 	"golang.org/x/crypto/bcrypt"
@@ -56,38 +54,18 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		secretKey := os.Getenv("JWT_SECRET_KEY")
-		if secretKey == "" {
-			http.Error(w, "JWT_SECRET_KEY environment variable not set", http.StatusInternalServerError)
+		// Only after the password matched, so it does not reveal which emails
+		// have accounts
+		if user.EmailVerifiedAt == nil {
+			http.Error(w, "Email not verified", http.StatusForbidden)
 			return
 		}
-		token := jwt.New(jwt.SigningMethodHS256)
-		claims := token.Claims.(jwt.MapClaims)
-		claims["iss"] = "www.good-code.net"
-		claims["id"] = user.ID
-		claims["email"] = req.Email
-		claims["name"] = user.Name
-		claims["iat"] = time.Now().Unix()
-		claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
 
-		signedToken, err := token.SignedString([]byte(secretKey))
-		if err != nil {
-			http.Error(w, "Failed to generate JWT token: "+err.Error(), http.StatusInternalServerError)
+		if _, err := authentication.StartSession(w, r, conn, user); err != nil {
+			http.Error(w, "Failed to issue session: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		//oven
-		cookie := &http.Cookie{
-			Name:     "auth",
-			Value:    signedToken,
-			Path:     "/",
-			Expires:  time.Now().Add(time.Hour * 24),
-			Secure:   true,
-			HttpOnly: true,
-			SameSite: http.SameSiteLaxMode,
-		}
-		http.SetCookie(w, cookie)
-
 		w.Header().Set("Content-Type", "application/json")
 		response := json.NewEncoder(w)
 
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// LogoutHandler revokes the session of this browser and clears its cookie.
+// It succeeds even when the cookie is missing or no longer valid.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("POST")(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("auth"); err == nil {
+			session, err := middleware.GetSessionFromJWT(cookie.Value)
+			if err == nil {
+				conn, err := db.GetDB()
+				if err != nil {
+					http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+					return
+				}
+				if _, err := authentication.RevokeSession(conn, session.UserLoginID, session.ID); err != nil {
+					log.Printf("Error revoking session of user %d: %v", session.UserLoginID, err)
+					http.Error(w, "Failed to log out", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		authentication.ClearAuthCookie(w)
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePasswordHandler changes the password of the signed in user given
+// their current one. Every session is signed out and this browser gets a new
+// one.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("PUT")(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+		userId, err := middleware.Authenticate(w, r, cookie.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if err := authentication.ValidatePassword(req.NewPassword); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		if err := conn.Where(&db.UserLogin{ID: userId}).First(&user).Error; err != nil {
+			log.Printf("Error loading user %d: %v", userId, err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+		if len(user.Password) == 0 {
+			http.Error(w, "This account has no password yet. Use forgot password to set one.", http.StatusConflict)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword(user.Password, []byte(req.CurrentPassword)); err != nil {
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+
+		if err := authentication.SetPassword(conn, &user, req.NewPassword); err != nil {
+			log.Printf("Error changing password of user %d: %v", userId, err)
+			http.Error(w, "Failed to change password", http.StatusInternalServerError)
+			return
+		}
+		if _, err := authentication.StartSession(w, r, conn, user); err != nil {
+			http.Error(w, "Failed to issue session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
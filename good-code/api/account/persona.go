@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+)
+
+func SetPersonaHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("PUT")(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+		userId, err := middleware.Authenticate(w, r, cookie.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			PersonaID *int64 `json:"persona_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		if req.PersonaID != nil {
+			var selected db.Persona
+			err = conn.Where(&db.Persona{ID: *req.PersonaID}).First(&selected).Error
+			if err != nil || !persona.Visible(selected, userId) {
+				http.Error(w, "Persona not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		err = conn.Model(&db.UserLogin{}).
+			Where(&db.UserLogin{ID: userId}).
+			Update("persona_id", req.PersonaID).
+			Error
+		if err != nil {
+			http.Error(w, "Failed to update persona: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := json.NewEncoder(w)
+
+		err = response.Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
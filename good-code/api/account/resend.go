@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// ResendVerificationHandler emails a new verification link. It answers the
+// same whether or not the email belongs to an unverified account, so it
+// cannot be used to find out who has signed up.
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("POST")(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{Email: req.Email}).First(&user).Error
+		if err == nil && user.Enabled && user.EmailVerifiedAt == nil {
+			last, err := authentication.LastIssued(conn, user.ID, db.TokenVerifyEmail)
+			if err != nil {
+				log.Printf("Error checking last verification email of user %d: %v", user.ID, err)
+			} else if time.Since(last) < authentication.ResendInterval {
+				log.Printf("Not resending verification email to user %d, last one was sent at %s", user.ID, last)
+			} else if err := authentication.SendVerification(r.Context(), conn, user); err != nil {
+				log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// ResetPasswordHandler sets a new password with the token from a password
+// reset email. The user then logs in with it.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("POST")(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if err := authentication.ValidatePassword(req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := authentication.ResetPassword(conn, req.Token, req.Password)
+		if err != nil {
+			if errors.Is(err, authentication.ErrInvalidToken) {
+				http.Error(w, "This link is invalid or has expired. Ask for a new one.", http.StatusBadRequest)
+				return
+			}
+			log.Printf("Error resetting password: %v", err)
+			http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Reset password of user %d", user.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
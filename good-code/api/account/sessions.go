@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+type sessionResponse struct {
+	db.Session
+	Current bool `json:"current"`
+}
+
+// ListSessionsHandler lists the browsers the user is signed in on. DELETE
+// signs out every one of them but this one.
+func ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("GET", "DELETE")(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+		current, err := middleware.AuthenticateSession(w, r, cookie.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			if err := authentication.RevokeSessions(conn, current.UserLoginID, current.ID); err != nil {
+				log.Printf("Error revoking sessions: %v", err)
+				http.Error(w, "Failed to sign out other sessions", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var sessions []db.Session
+		err = conn.Where(&db.Session{UserLoginID: current.UserLoginID}).
+			Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+			Order("last_seen_at DESC").
+			Find(&sessions).
+			Error
+		if err != nil {
+			log.Printf("Error listing sessions of user %d: %v", current.UserLoginID, err)
+			http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+			return
+		}
+		response := make([]sessionResponse, len(sessions))
+		for i, session := range sessions {
+			response[i] = sessionResponse{Session: session, Current: session.ID == current.ID}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
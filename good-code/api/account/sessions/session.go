@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// SessionHandler revokes one of the user's sessions, signing that browser out.
+// Revoking the current session also clears its cookie.
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("DELETE")(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+		current, err := middleware.AuthenticateSession(w, r, cookie.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		sessionID := r.URL.Query().Get("sessionId")
+		if sessionID == "" {
+			http.Error(w, "Missing session ID", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		revoked, err := authentication.RevokeSession(conn, current.UserLoginID, sessionID)
+		if err != nil {
+			log.Printf("Error revoking session of user %d: %v", current.UserLoginID, err)
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		if !revoked {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if sessionID == current.ID {
+			authentication.ClearAuthCookie(w)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	db "github.com/chopstickleg/good-code/api/_db"
 	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -37,7 +39,9 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err == nil && user.Enabled {
+		// Disabled and unverified accounts keep their email too, otherwise
+		// signing up again would take them over
+		if err == nil {
 			http.Error(w, "User already exists", http.StatusUnauthorized)
 			return
 		}
@@ -64,10 +68,16 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// The account exists either way, so a failed email only means asking
+		// for another one
+		if err := authentication.SendVerification(r.Context(), conn, user); err != nil {
+			log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		response := json.NewEncoder(w)
 
-		err = response.Encode(map[string]bool{"success": true})
+		err = response.Encode(map[string]bool{"success": true, "verification_required": true})
 		if err != nil {
 			http.Error(w, "Failed to send response: "+err.Error(), http.StatusInternalServerError)
 			return
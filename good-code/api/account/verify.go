@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// VerifyEmailHandler is the link emailed at signup. It verifies the address
+// and sends the browser on to the login page.
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("GET")(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := authentication.VerifyEmail(conn, r.URL.Query().Get("token"))
+		if err != nil {
+			if errors.Is(err, authentication.ErrInvalidToken) {
+				http.Error(w, "This link is invalid or has expired. Log in to have a new one sent.", http.StatusBadRequest)
+				return
+			}
+			log.Printf("Error verifying email: %v", err)
+			http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Verified email of user %d", user.ID)
+
+		http.Redirect(w, r, "/login?verified=true", http.StatusFound)
+	})(w, r)
+}
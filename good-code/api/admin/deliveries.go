@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+)
+
+const maxDeliveriesListed = 200
+
+func ListDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Not authorized", http.StatusForbidden)
+			return
+		}
+
+		limit := maxDeliveriesListed
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(parsed, maxDeliveriesListed)
+		}
+
+		query := conn.Model(&db.WebhookDelivery{}).Omit("payload")
+		if status := r.URL.Query().Get("status"); status != "" {
+			switch status {
+			case db.DeliveryReceived, db.DeliveryProcessed, db.DeliveryFailed:
+				query = query.Where(&db.WebhookDelivery{Status: status})
+			default:
+				http.Error(w, "Invalid status", http.StatusBadRequest)
+				return
+			}
+		}
+		if event := r.URL.Query().Get("event"); event != "" {
+			query = query.Where(&db.WebhookDelivery{Event: event})
+		}
+		if v := r.URL.Query().Get("installation_id"); v != "" {
+			installationId, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid installation ID", http.StatusBadRequest)
+				return
+			}
+			query = query.Where(&db.WebhookDelivery{InstallationID: installationId})
+		}
+		if deliveryId := r.URL.Query().Get("delivery_id"); deliveryId != "" {
+			query = query.Where(&db.WebhookDelivery{DeliveryID: deliveryId})
+		}
+
+		var deliveries []db.WebhookDelivery
+		err = query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+		if err != nil {
+			log.Printf("Error listing webhook deliveries: %v", err)
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(deliveries)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
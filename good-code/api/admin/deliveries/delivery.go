@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	handlers "github.com/chopstickleg/good-code/api/_utils/handlers"
+	"gorm.io/gorm"
+)
+
+// DeliveryHandler shows a stored webhook delivery with its payload. POST
+// replays it through the webhook dispatcher and records the new outcome.
+func DeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet, http.MethodPost)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		deliveryId, err := strconv.ParseInt(r.URL.Query().Get("deliveryId"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Not authorized", http.StatusForbidden)
+			return
+		}
+
+		var delivery db.WebhookDelivery
+		err = conn.Where(&db.WebhookDelivery{ID: deliveryId}).First(&delivery).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				http.Error(w, "Delivery not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			log.Printf("User %d is replaying delivery %s of %s event", userId, delivery.DeliveryID, delivery.Event)
+			handlers.ProcessDelivery(conn, &delivery)
+			if err := conn.Where(&db.WebhookDelivery{ID: deliveryId}).First(&delivery).Error; err != nil {
+				http.Error(w, "Error querying DB", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(delivery)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
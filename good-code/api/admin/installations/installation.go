@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+)
+
+// InstallationHandler shows an installation's quota. PUT moves it to another
+// plan and sets or clears its own limits, which take precedence over the plan's.
+func InstallationHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet, http.MethodPut)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		installationId, err := strconv.ParseInt(r.URL.Query().Get("installationId"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid installation ID", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Not authorized", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var req struct {
+				Plan              string `json:"plan"`
+				RoastsPerMonth    *int   `json:"roasts_per_month"`
+				MaxDiffBytes      *int   `json:"max_diff_bytes"`
+				TokensPerMonth    *int64 `json:"tokens_per_month"`
+				MaxTokensPerRoast *int64 `json:"max_tokens_per_roast"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			if _, ok := quota.PlansFromEnv()[req.Plan]; !ok {
+				http.Error(w, "Unknown plan", http.StatusBadRequest)
+				return
+			}
+
+			if _, _, err := quota.LimitsFor(conn, installationId, ""); err != nil {
+				log.Printf("Error loading installation %d: %v", installationId, err)
+				http.Error(w, "Error querying DB", http.StatusInternalServerError)
+				return
+			}
+			err = conn.Model(&db.Installation{}).
+				Where(&db.Installation{ID: installationId}).
+				Updates(map[string]any{
+					"plan":                 req.Plan,
+					"roasts_per_month":     req.RoastsPerMonth,
+					"max_diff_bytes":       req.MaxDiffBytes,
+					"tokens_per_month":     req.TokensPerMonth,
+					"max_tokens_per_roast": req.MaxTokensPerRoast,
+				}).
+				Error
+			if err != nil {
+				log.Printf("Error updating installation %d: %v", installationId, err)
+				http.Error(w, "Failed to update installation", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("User %d moved installation %d to the %s plan", userId, installationId, req.Plan)
+		}
+
+		status, err := quota.GetStatus(conn, installationId)
+		if err != nil {
+			log.Printf("Error loading quota of installation %d: %v", installationId, err)
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+)
+
+const maxJobsListed = 200
+
+func ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Not authorized", http.StatusForbidden)
+			return
+		}
+
+		limit := maxJobsListed
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(parsed, maxJobsListed)
+		}
+
+		query := conn.Model(&db.Job{}).Omit("payload")
+		if status := r.URL.Query().Get("status"); status != "" {
+			switch status {
+			case db.JobQueued, db.JobRunning, db.JobSucceeded, db.JobDead:
+				query = query.Where(&db.Job{Status: status})
+			default:
+				http.Error(w, "Invalid status", http.StatusBadRequest)
+				return
+			}
+		}
+		if kind := r.URL.Query().Get("kind"); kind != "" {
+			query = query.Where(&db.Job{Kind: kind})
+		}
+
+		var jobs []db.Job
+		err = query.Order("created_at DESC").Limit(limit).Find(&jobs).Error
+		if err != nil {
+			log.Printf("Error listing jobs: %v", err)
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(jobs)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
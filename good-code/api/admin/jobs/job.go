@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	jobs "github.com/chopstickleg/good-code/api/_utils/jobs"
+	"gorm.io/gorm"
+)
+
+// JobHandler shows a single job with its payload. POST puts it back in the
+// queue with a fresh set of attempts, DELETE drops it.
+func JobHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet, http.MethodPost, http.MethodDelete)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		jobId, err := strconv.ParseInt(r.URL.Query().Get("jobId"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid job ID", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+		if !user.IsAdmin {
+			http.Error(w, "Not authorized", http.StatusForbidden)
+			return
+		}
+
+		var job db.Job
+		err = conn.Where(&db.Job{ID: jobId}).First(&job).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				http.Error(w, "Job not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodDelete:
+			if job.Status == db.JobRunning {
+				http.Error(w, "Job is running", http.StatusConflict)
+				return
+			}
+			if err := conn.Delete(&job).Error; err != nil {
+				log.Printf("Error deleting job %d: %v", jobId, err)
+				http.Error(w, "Failed to delete job", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPost:
+			if job.Status == db.JobRunning {
+				http.Error(w, "Job is running", http.StatusConflict)
+				return
+			}
+			if err := jobs.Retry(conn, jobId); err != nil {
+				log.Printf("Error retrying job %d: %v", jobId, err)
+				http.Error(w, "Failed to retry job", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("User %d put job %d (%s) back in the queue", userId, jobId, job.Kind)
+			if err := conn.Where(&db.Job{ID: jobId}).First(&job).Error; err != nil {
+				http.Error(w, "Error querying DB", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(job)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+	"gorm.io/gorm"
+)
+
+// GitHubCallbackHandler is where GitHub sends the browser back to. It signs
+// the GitHub user in, creating their account the first time, or links them
+// to the signed in account when the flow was started for linking.
+func GitHubCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("GET")(func(w http.ResponseWriter, r *http.Request) {
+		mode, err := authentication.FinishGitHubOAuth(w, r)
+		if err != nil {
+			log.Printf("Error finishing GitHub OAuth: %v", err)
+			http.Error(w, "Invalid or expired sign in attempt, please try again", http.StatusBadRequest)
+			return
+		}
+		if reason := r.URL.Query().Get("error"); reason != "" {
+			log.Printf("GitHub OAuth denied: %s", reason)
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			http.Error(w, "Missing code", http.StatusBadRequest)
+			return
+		}
+
+		token, err := authentication.ExchangeGitHubCode(r.Context(), code)
+		if err != nil {
+			log.Printf("Error exchanging GitHub OAuth code: %v", err)
+			http.Error(w, "Failed to sign in with GitHub", http.StatusBadGateway)
+			return
+		}
+		ghUser, err := authentication.GitHubUser(r.Context(), token)
+		if err != nil {
+			log.Printf("Error getting GitHub user: %v", err)
+			http.Error(w, "Failed to sign in with GitHub", http.StatusBadGateway)
+			return
+		}
+		githubID := ghUser.GetID()
+
+		conn, err := db.GetDB()
+		if err != nil {
+			log.Printf("Error connecting to database: %v", err)
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		if mode == authentication.OAuthLink {
+			cookie, err := r.Cookie("auth")
+			if err != nil {
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			}
+			userid, err := middleware.GetUserIDFromJWT(cookie.Value)
+			if err != nil {
+				log.Printf("Error verifying JWT: %v", err)
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			}
+			if err := conn.Where(&db.UserLogin{ID: userid}).First(&user).Error; err != nil {
+				log.Printf("Error loading user %d: %v", userid, err)
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			}
+			err = authentication.LinkGitHub(conn, &user, ghUser.GetID())
+			switch {
+			case errors.Is(err, authentication.ErrGitHubTaken):
+				http.Error(w, "This GitHub account is already linked to another Good Code account", http.StatusConflict)
+				return
+			case errors.Is(err, authentication.ErrGitHubLinked):
+				http.Error(w, "Unlink your current GitHub account first", http.StatusConflict)
+				return
+			case err != nil:
+				log.Printf("Error linking GitHub user %d to user %d: %v", ghUser.GetID(), user.ID, err)
+				http.Error(w, "Failed to link GitHub account", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		err = conn.Where(&db.UserLogin{GithubID: &githubID}).First(&user).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			// An account that signed up with the same email has to link GitHub
+			// itself, otherwise anyone controlling a GitHub account with that
+			// email would get into it
+			if ghUser.GetEmail() != "" {
+				var count int64
+				err := conn.Model(&db.UserLogin{}).
+					Where(&db.UserLogin{Email: ghUser.GetEmail()}).
+					Count(&count).
+					Error
+				if err != nil {
+					log.Printf("Error checking email of GitHub user %d: %v", ghUser.GetID(), err)
+					http.Error(w, "Failed to sign in with GitHub", http.StatusInternalServerError)
+					return
+				}
+				if count > 0 {
+					http.Error(w, "An account with this email already exists. Sign in with your password and link GitHub from your account.", http.StatusConflict)
+					return
+				}
+			}
+			name := ghUser.GetName()
+			if name == "" {
+				name = ghUser.GetLogin()
+			}
+			user = db.UserLogin{
+				Email:    ghUser.GetEmail(),
+				Name:     name,
+				GithubID: &githubID,
+				Enabled:  true,
+			}
+			// GitHub only hands out addresses its users verified
+			if user.Email != "" {
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+			}
+			if err := conn.Create(&user).Error; err != nil {
+				log.Printf("Error creating user for GitHub user %d: %v", ghUser.GetID(), err)
+				http.Error(w, "Failed to create user", http.StatusInternalServerError)
+				return
+			}
+			if err := authentication.LinkGitHub(conn, &user, ghUser.GetID()); err != nil {
+				log.Printf("Error attaching collaborators of GitHub user %d: %v", ghUser.GetID(), err)
+			}
+		case err != nil:
+			log.Printf("Error loading GitHub user %d: %v", ghUser.GetID(), err)
+			http.Error(w, "Failed to sign in with GitHub", http.StatusInternalServerError)
+			return
+		case !user.Enabled:
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		if _, err := authentication.StartSession(w, r, conn, user); err != nil {
+			log.Printf("Error issuing session: %v", err)
+			http.Error(w, "Failed to issue session", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+	})(w, r)
+}
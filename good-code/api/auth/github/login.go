@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
+)
+
+// GitHubLoginHandler sends the browser to GitHub to sign in, or with
+// ?link=true to link GitHub to the account that is signed in.
+func GitHubLoginHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods("GET")(func(w http.ResponseWriter, r *http.Request) {
+		mode := authentication.OAuthLogin
+		if r.URL.Query().Get("link") == "true" {
+			cookie, err := r.Cookie("auth")
+			if err != nil {
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			}
+			if _, err := middleware.GetUserIDFromJWT(cookie.Value); err != nil {
+				log.Printf("Error verifying JWT: %v", err)
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			}
+			mode = authentication.OAuthLink
+		}
+
+		target, err := authentication.StartGitHubOAuth(w, mode)
+		if err != nil {
+			log.Printf("Error starting GitHub OAuth: %v", err)
+			http.Error(w, "Failed to start GitHub sign in", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	})(w, r)
+}
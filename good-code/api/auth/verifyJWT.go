@@ -3,13 +3,14 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
 
 	middleware "github.com/chopstickleg/good-code/api/_middleware"
-	authentication "github.com/chopstickleg/good-code/api/_utils/authentication"
 )
 
+// VerifyJWTHandler reports whether the browser is signed in. The frontend
+// calls it on every page, so it also keeps active sessions from expiring.
 func VerifyJWTHandler(w http.ResponseWriter, r *http.Request) {
 	middleware.AllowMethods("GET")(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("auth")
@@ -23,17 +24,12 @@ func VerifyJWTHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
-		err = authentication.VerifyToken(cookie.Value)
-		if err != nil {
-			switch {
-			case errors.Is(err, fmt.Errorf("invalid token")):
-				http.Error(w, "Not authorized", http.StatusUnauthorized)
-				return
-			default:
-				http.Error(w, "Internal server error when verifying token: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
+		if _, err := middleware.Authenticate(w, r, cookie.Value); err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
 		}
+
 		w.Header().Set("Content-Type", "application/json")
 		response := json.NewEncoder(w)
 		err = response.Encode(map[string]bool{"loggedIn": true})
@@ -29,7 +29,7 @@ func HandleInstallationEvent(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
-		userid, err := middleware.GetUserIDFromJWT(cookie.Value)
+		userid, err := middleware.Authenticate(w, r, cookie.Value)
 		if err != nil {
 			log.Printf("Error verifying JWT: %v", err)
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
@@ -67,12 +67,12 @@ func HandleInstallationEvent(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// The installation's account is often an organization rather than the
+		// user installing it, so their GitHub identity only comes from signing
+		// in with GitHub
 		err = conn.Model(&db.UserLogin{}).
 			Where(&db.UserLogin{ID: userid}).
-			Updates(db.UserLogin{
-				GithubID:       installation.GetAccount().GetID(),
-				InstallationID: installation.GetID(),
-			}).
+			Update("installation_id", installation.GetID()).
 			Error
 		if err != nil {
 			http.Error(w, "Failed to update user login", http.StatusInternalServerError)
@@ -90,7 +90,7 @@ func HandleInstallationEvent(w http.ResponseWriter, r *http.Request) {
 
 		repositories, _, err := ghClient.Apps.ListRepos(context.Background(), &github.ListOptions{})
 
-		err = handlers.HandleAppCreated(conn, installation, repositories.Repositories)
+		err = handlers.HandleAppCreated(r.Context(), conn, installation, repositories.Repositories)
 		if err != nil {
 			http.Error(w, "Failed to handle app creation", http.StatusInternalServerError)
 			log.Printf("Error handling app creation: %v", err)
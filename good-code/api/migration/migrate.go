@@ -5,6 +5,8 @@ import (
 	"os"
 
 	db "github.com/chopstickleg/good-code/api/_db"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+	"gorm.io/gorm"
 )
 
 func MigrateHandler(w http.ResponseWriter, r *http.Request) {
@@ -31,17 +33,86 @@ func MigrateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Accounts created before email verification existed are grandfathered in
+	backfillVerified := !conn.Migrator().HasColumn(&db.UserLogin{}, "EmailVerifiedAt")
+	// Repositories used to be tied to their installation through the GitHub ID
+	// installing the app stamped on the user, whatever account it belonged to
+	backfillInstallations := !conn.Migrator().HasColumn(&db.Repository{}, "InstallationID")
+
 	err = conn.AutoMigrate(
 		&db.UserLogin{},
 		&db.Repository{},
 		&db.UserRepositoryCollaborator{},
 		&db.AiRoast{},
+		&db.Persona{},
+		&db.RoastFinding{},
+		&db.PullRequest{},
+		&db.ConversationTurn{},
+		&db.ModelCall{},
+		&db.Job{},
+		&db.WebhookDelivery{},
+		&db.Installation{},
+		&db.QuotaUsage{},
+		&db.QuotaReservation{},
+		&db.AccountToken{},
+		&db.Session{},
 	)
 	if err != nil {
 		http.Error(w, "Migration failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Accounts without GitHub used to be created with GitHub ID 0, which the
+	// unique index only lets one of them have
+	err = conn.Model(&db.UserLogin{}).
+		Where("github_id = 0").
+		Update("github_id", gorm.Expr("NULL")).
+		Error
+	if err != nil {
+		http.Error(w, "Clearing zero GitHub IDs failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if backfillVerified {
+		err = conn.Model(&db.UserLogin{}).
+			Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).
+			Error
+		if err != nil {
+			http.Error(w, "Backfilling email verification failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if backfillInstallations {
+		err = conn.Exec(`UPDATE repositories SET installation_id = user_logins.installation_id
+			FROM user_logins
+			WHERE user_logins.github_id = repositories.owner_id AND user_logins.installation_id <> 0`).
+			Error
+		if err != nil {
+			http.Error(w, "Backfilling repository installations failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// The stamped ID is the installation's account, often an organization
+		// rather than the user. Accounts without a password signed in with
+		// GitHub and hold their own ID; the others link GitHub again if it was
+		// theirs after all.
+		err = conn.Exec(`UPDATE user_logins SET github_id = NULL
+			WHERE installation_id <> 0 AND octet_length(password) > 0
+			AND github_id IN (SELECT owner_id FROM repositories WHERE repositories.installation_id = user_logins.installation_id)`).
+			Error
+		if err != nil {
+			http.Error(w, "Clearing GitHub IDs stamped on install failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = persona.Seed(conn)
+	if err != nil {
+		http.Error(w, "Seeding personas failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Migration completed successfully"))
 }
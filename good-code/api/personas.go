@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+)
+
+func PersonasHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet, http.MethodPost)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req struct {
+				Name         string `json:"name"`
+				Description  string `json:"description"`
+				Instructions string `json:"instructions"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			req.Name = strings.TrimSpace(req.Name)
+			if req.Name == "" || strings.TrimSpace(req.Instructions) == "" {
+				http.Error(w, "Name and instructions are required", http.StatusBadRequest)
+				return
+			}
+
+			var count int64
+			err = conn.Model(&db.Persona{}).
+				Where("name = ? AND owner_id = ?", req.Name, userId).
+				Count(&count).
+				Error
+			if err != nil {
+				log.Printf("Error checking personas for user %d: %v", userId, err)
+				http.Error(w, "Error querying DB", http.StatusInternalServerError)
+				return
+			}
+			if count > 0 {
+				http.Error(w, "You already have a persona with that name", http.StatusConflict)
+				return
+			}
+
+			persona := db.Persona{
+				Name:         req.Name,
+				Description:  req.Description,
+				Instructions: req.Instructions,
+				Version:      1,
+				OwnerID:      &userId,
+			}
+			if err := conn.Create(&persona).Error; err != nil {
+				log.Printf("Error creating persona for user %d: %v", userId, err)
+				http.Error(w, "Failed to create persona", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			if err := json.NewEncoder(w).Encode(persona); err != nil {
+				http.Error(w, "Error sending response", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		var personas []db.Persona
+		err = conn.
+			Where("built_in = ? OR owner_id = ?", true, userId).
+			Order("built_in DESC, name ASC").
+			Find(&personas).
+			Error
+		if err != nil {
+			log.Printf("Error retrieving personas for user %d: %v", userId, err)
+			http.Error(w, "Error retrieving data from database", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(personas)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	"gorm.io/gorm"
+)
+
+func PersonaHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodPut, http.MethodDelete)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		personaId, err := strconv.ParseInt(r.URL.Query().Get("personaId"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid persona ID", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var persona db.Persona
+		err = conn.Where(&db.Persona{ID: personaId}).First(&persona).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				http.Error(w, "Persona not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+		if persona.BuiltIn || persona.OwnerID == nil || *persona.OwnerID != userId {
+			http.Error(w, "Not authorized to modify this persona", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			err = conn.Transaction(func(tx *gorm.DB) error {
+				// Anything still pointing at the persona falls back to the default
+				if err := tx.Model(&db.Repository{}).Where("persona_id = ?", personaId).Update("persona_id", nil).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&db.UserLogin{}).Where("persona_id = ?", personaId).Update("persona_id", nil).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&persona).Error
+			})
+			if err != nil {
+				log.Printf("Error deleting persona %d: %v", personaId, err)
+				http.Error(w, "Failed to delete persona", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var req struct {
+			Name         *string `json:"name"`
+			Description  *string `json:"description"`
+			Instructions *string `json:"instructions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		updates := map[string]any{}
+		if req.Name != nil {
+			if strings.TrimSpace(*req.Name) == "" {
+				http.Error(w, "Name cannot be empty", http.StatusBadRequest)
+				return
+			}
+			updates["name"] = strings.TrimSpace(*req.Name)
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.Instructions != nil && *req.Instructions != persona.Instructions {
+			if strings.TrimSpace(*req.Instructions) == "" {
+				http.Error(w, "Instructions cannot be empty", http.StatusBadRequest)
+				return
+			}
+			updates["instructions"] = *req.Instructions
+			updates["version"] = persona.Version + 1
+		}
+
+		if len(updates) > 0 {
+			err = conn.Model(&persona).Updates(updates).Error
+			if err != nil {
+				log.Printf("Error updating persona %d: %v", personaId, err)
+				http.Error(w, "Failed to update persona", http.StatusInternalServerError)
+				return
+			}
+			err = conn.Where(&db.Persona{ID: personaId}).First(&persona).Error
+			if err != nil {
+				http.Error(w, "Error querying DB", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(persona)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	quota "github.com/chopstickleg/good-code/api/_utils/quota"
+)
+
+// GetQuotaHandler shows the plan of the user's GitHub App installation and
+// what is left of its quota this month. Admins may ask about any installation.
+func GetQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+
+		installationId := user.InstallationID
+		if v := r.URL.Query().Get("installation_id"); v != "" {
+			installationId, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid installation ID", http.StatusBadRequest)
+				return
+			}
+			if installationId != user.InstallationID && !user.IsAdmin {
+				http.Error(w, "Not authorized", http.StatusForbidden)
+				return
+			}
+		}
+		if installationId == 0 {
+			http.Error(w, "No GitHub App installation", http.StatusNotFound)
+			return
+		}
+
+		status, err := quota.GetStatus(conn, installationId)
+		if err != nil {
+			log.Printf("Error loading quota of installation %d: %v", installationId, err)
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
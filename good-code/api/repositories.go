@@ -24,7 +24,7 @@ func GetRepositoriesHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		userId, err := middleware.GetUserIDFromJWT(token.Value)
+		userId, err := middleware.Authenticate(w, r, token.Value)
 		if err != nil {
 			log.Printf("Error verifying JWT: %v", err)
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
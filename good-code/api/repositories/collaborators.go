@@ -29,7 +29,7 @@ func GetCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		userId, err := middleware.GetUserIDFromJWT(token.Value)
+		userId, err := middleware.Authenticate(w, r, token.Value)
 		if err != nil {
 			log.Printf("Error verifying JWT: %v", err)
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
@@ -81,14 +81,7 @@ func GetCollaboratorsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var installationId int64
-		err = conn.Model(&db.UserLogin{}).
-			Select("user_logins.installation_id").
-			Joins("JOIN repositories ON repositories.owner_id = user_logins.github_id").
-			Where("repositories.id = ?", repoId).
-			Scan(&installationId).
-			Error
-
+		installationId, err := repository.GetInstallationID(repoId, conn)
 		if err != nil {
 			log.Printf("Error retrieving installation ID for repo %d: %v", repoId, err)
 			http.Error(w, "Error retrieving installation ID", http.StatusInternalServerError)
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	utils "github.com/chopstickleg/good-code/api/_utils"
+	config "github.com/chopstickleg/good-code/api/_utils/config"
+	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+	"github.com/google/go-github/v72/github"
+)
+
+func GetRepoConfigHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		repoId, err := repository.GetRepoId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+
+		hasAccess, err := repository.GetRepoAccess(repoId, user, conn)
+		if err != nil {
+			log.Printf("Error checking repository access for user %d and repo %d: %v", userId, repoId, err)
+			http.Error(w, "Error checking repository access", http.StatusInternalServerError)
+			return
+		}
+
+		if !hasAccess {
+			http.Error(w, "Not authorized to access this repository", http.StatusForbidden)
+			return
+		}
+
+		var repo db.Repository
+		err = conn.Where(&db.Repository{ID: repoId}).First(&repo).Error
+		if err != nil {
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+
+		installationId, err := repository.GetInstallationID(repoId, conn)
+		if err != nil {
+			log.Printf("Error retrieving installation ID for repo %d: %v", repoId, err)
+			http.Error(w, "Error retrieving installation ID", http.StatusInternalServerError)
+			return
+		}
+
+		installationToken, err := utils.GetGitHubInstallationToken(installationId)
+		if err != nil {
+			log.Printf("Failed to get GitHub installation token: %v", err)
+			http.Error(w, "Unable to get GitHub installation token", http.StatusInternalServerError)
+			return
+		}
+		authedGHClient := github.NewClient(nil).WithAuthToken(installationToken)
+
+		ref := r.URL.Query().Get("ref")
+		if ref == "" {
+			ghRepo, _, err := authedGHClient.Repositories.Get(context.Background(), repo.Owner, repo.Name)
+			if err != nil {
+				log.Printf("Failed to get repository %s/%s: %v", repo.Owner, repo.Name, err)
+				http.Error(w, "Unable to get repository from GitHub", http.StatusBadGateway)
+				return
+			}
+			ref = ghRepo.GetDefaultBranch()
+		}
+
+		resolved, err := config.Fetch(context.Background(), authedGHClient, repo.Owner, repo.Name, ref)
+		if err != nil {
+			log.Printf("Failed to fetch config for repo %d: %v", repoId, err)
+			http.Error(w, "Unable to fetch repository configuration", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(resolved)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
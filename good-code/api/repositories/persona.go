@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	persona "github.com/chopstickleg/good-code/api/_utils/persona"
+	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+)
+
+func SetRepoPersonaHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodPut)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		repoId, err := repository.GetRepoId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			PersonaID *int64 `json:"persona_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+
+		canManage, err := repository.GetRepoManageAccess(repoId, user, conn)
+		if err != nil {
+			log.Printf("Error checking repository access for user %d and repo %d: %v", userId, repoId, err)
+			http.Error(w, "Error checking repository access", http.StatusInternalServerError)
+			return
+		}
+		if !canManage {
+			http.Error(w, "Not authorized to manage this repository", http.StatusForbidden)
+			return
+		}
+
+		if req.PersonaID != nil {
+			var selected db.Persona
+			err = conn.Where(&db.Persona{ID: *req.PersonaID}).First(&selected).Error
+			if err != nil || !persona.Visible(selected, userId) {
+				http.Error(w, "Persona not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		err = conn.Model(&db.Repository{}).
+			Where(&db.Repository{ID: repoId}).
+			Update("persona_id", req.PersonaID).
+			Error
+		if err != nil {
+			log.Printf("Error updating persona for repo %d: %v", repoId, err)
+			http.Error(w, "Failed to update repository persona", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -8,6 +8,7 @@ import (
 	db "github.com/chopstickleg/good-code/api/_db"
 	middleware "github.com/chopstickleg/good-code/api/_middleware"
 	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+	"gorm.io/gorm"
 )
 
 func GetRepoHandler(w http.ResponseWriter, r *http.Request) {
@@ -25,7 +26,7 @@ func GetRepoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var user db.UserLogin
-		userId, err := middleware.GetUserIDFromJWT(token.Value)
+		userId, err := middleware.Authenticate(w, r, token.Value)
 		if err != nil {
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
 			return
@@ -64,6 +65,10 @@ func GetRepoHandler(w http.ResponseWriter, r *http.Request) {
 			Preload("OwnerUser").
 			Preload("Collaborators").
 			Preload("AiRoasts").
+			Preload("AiRoasts.PullRequest").
+			Preload("PullRequests", func(tx *gorm.DB) *gorm.DB {
+				return tx.Order("updated_at DESC")
+			}).
 			Where(&db.Repository{ID: repoId}).
 			First(&repo).
 			Error
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	db "github.com/chopstickleg/good-code/api/_db"
 	middleware "github.com/chopstickleg/good-code/api/_middleware"
 	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+	"gorm.io/gorm"
 )
 
 func GetRoastsHandler(w http.ResponseWriter, r *http.Request) {
@@ -25,7 +27,7 @@ func GetRoastsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		userId, err := middleware.GetUserIDFromJWT(token.Value)
+		userId, err := middleware.Authenticate(w, r, token.Value)
 		if err != nil {
 			log.Printf("Error verifying JWT: %v", err)
 			http.Error(w, "Not authorized", http.StatusUnauthorized)
@@ -64,12 +66,36 @@ func GetRoastsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var roasts []db.AiRoast
-		err = conn.
+		severities := splitFilter(r.URL.Query().Get("severity"))
+		categories := splitFilter(r.URL.Query().Get("category"))
+		filterFindings := func(tx *gorm.DB) *gorm.DB {
+			if len(severities) > 0 {
+				tx = tx.Where("severity IN ?", severities)
+			}
+			if len(categories) > 0 {
+				tx = tx.Where("category IN ?", categories)
+			}
+			return tx
+		}
+
+		query := conn.
 			Omit("Repository").
-			Where(&db.AiRoast{RepoID: repoId}).
-			Find(&roasts).
-			Error
+			Preload("PullRequest").
+			Preload("Conversation", func(tx *gorm.DB) *gorm.DB {
+				return tx.Order("created_at ASC")
+			}).
+			Preload("Findings", filterFindings).
+			Where(&db.AiRoast{RepoID: repoId})
+		if states := splitFilter(r.URL.Query().Get("state")); len(states) > 0 {
+			query = query.Where("pull_request_id IN (?)", conn.Model(&db.PullRequest{}).Select("id").Where("state IN ?", states))
+		}
+		if len(severities) > 0 || len(categories) > 0 {
+			// Only roasts with at least one matching finding are of interest
+			query = query.Where("id IN (?)", filterFindings(conn.Model(&db.RoastFinding{}).Select("roast_id")))
+		}
+
+		var roasts []db.AiRoast
+		err = query.Find(&roasts).Error
 		if err != nil {
 			log.Printf("Error retrieving AI roasts for repo %d: %v", repoId, err)
 			http.Error(w, "Error retrieving data from database", http.StatusInternalServerError)
@@ -84,3 +110,13 @@ func GetRoastsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	})(w, r)
 }
+
+func splitFilter(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	llm "github.com/chopstickleg/good-code/api/_utils/llm"
+	repository "github.com/chopstickleg/good-code/api/_utils/repository"
+)
+
+const maxModelLength = 100
+
+func UpdateRepoSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodPut)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		repoId, err := repository.GetRepoId(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			CommentMode *string `json:"comment_mode"`
+			AiProvider  *string `json:"ai_provider"`
+			AiModel     *string `json:"ai_model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		updates := map[string]any{}
+		if req.CommentMode != nil {
+			if *req.CommentMode != db.CommentModeSticky && *req.CommentMode != db.CommentModeNew {
+				http.Error(w, "comment_mode must be \""+db.CommentModeSticky+"\" or \""+db.CommentModeNew+"\"", http.StatusBadRequest)
+				return
+			}
+			updates["comment_mode"] = *req.CommentMode
+		}
+		if req.AiProvider != nil {
+			// An empty provider clears the override and falls back to the deployment default
+			if *req.AiProvider != "" && !llm.ValidProvider(*req.AiProvider) {
+				http.Error(w, "Unknown ai_provider "+strconv.Quote(*req.AiProvider), http.StatusBadRequest)
+				return
+			}
+			updates["ai_provider"] = *req.AiProvider
+			if req.AiModel == nil {
+				// The old model name belonged to the old provider
+				updates["ai_model"] = ""
+			}
+		}
+		if req.AiModel != nil {
+			model := strings.TrimSpace(*req.AiModel)
+			if len(model) > maxModelLength {
+				http.Error(w, "ai_model is too long", http.StatusBadRequest)
+				return
+			}
+			updates["ai_model"] = model
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+
+		canManage, err := repository.GetRepoManageAccess(repoId, user, conn)
+		if err != nil {
+			log.Printf("Error checking repository access for user %d and repo %d: %v", userId, repoId, err)
+			http.Error(w, "Error checking repository access", http.StatusInternalServerError)
+			return
+		}
+		if !canManage {
+			http.Error(w, "Not authorized to manage this repository", http.StatusForbidden)
+			return
+		}
+
+		var repo db.Repository
+		if len(updates) > 0 {
+			err = conn.Model(&db.Repository{}).
+				Where(&db.Repository{ID: repoId}).
+				Updates(updates).
+				Error
+			if err != nil {
+				log.Printf("Error updating settings for repo %d: %v", repoId, err)
+				http.Error(w, "Failed to update repository settings", http.StatusInternalServerError)
+				return
+			}
+		}
+		err = conn.Where(&db.Repository{ID: repoId}).First(&repo).Error
+		if err != nil {
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(repo)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	usage "github.com/chopstickleg/good-code/api/_utils/usage"
+)
+
+// GetUsageHandler reports roast spend across the repositories the user owns
+// and their GitHub App installation, grouped by month, repository and/or
+// installation. Admins see every installation.
+func GetUsageHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet)(func(w http.ResponseWriter, r *http.Request) {
+		token, err := r.Cookie("auth")
+		if err != nil {
+			switch {
+			case errors.Is(err, http.ErrNoCookie):
+				http.Error(w, "Not authorized", http.StatusUnauthorized)
+				return
+			default:
+				http.Error(w, "Internal server error loading cookie", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		userId, err := middleware.Authenticate(w, r, token.Value)
+		if err != nil {
+			log.Printf("Error verifying JWT: %v", err)
+			http.Error(w, "Not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		groupBy := []string{usage.GroupByMonth}
+		if v := r.URL.Query().Get("group_by"); v != "" {
+			groupBy, err = usage.ParseGroupBy(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		var user db.UserLogin
+		err = conn.Where(&db.UserLogin{ID: userId}).First(&user).Error
+		if err != nil {
+			log.Printf("Error retrieving user with ID %d: %v", userId, err)
+			http.Error(w, "Error retrieving user from database", http.StatusInternalServerError)
+			return
+		}
+
+		query := conn
+		if !user.IsAdmin {
+			// A user without GitHub owns nothing, and owner_id = NULL matches nothing
+			owned := conn.Model(&db.Repository{}).Select("id").Where("owner_id = ?", user.GithubID)
+			if user.InstallationID != 0 {
+				query = query.Where("(repo_id IN (?) OR installation_id = ?)", owned, user.InstallationID)
+			} else {
+				query = query.Where("repo_id IN (?)", owned)
+			}
+		}
+		if v := r.URL.Query().Get("installation_id"); v != "" {
+			installationId, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid installation ID", http.StatusBadRequest)
+				return
+			}
+			query = query.Where("installation_id = ?", installationId)
+		}
+		for param, op := range map[string]string{"from": ">=", "to": "<"} {
+			v := r.URL.Query().Get(param)
+			if v == "" {
+				continue
+			}
+			month, err := time.Parse("2006-01", v)
+			if err != nil {
+				http.Error(w, param+" must be a month such as 2025-01", http.StatusBadRequest)
+				return
+			}
+			if param == "to" {
+				// to is inclusive of the whole month
+				month = month.AddDate(0, 1, 0)
+			}
+			query = query.Where("created_at "+op+" ?", month)
+		}
+
+		buckets, err := usage.Aggregate(query, groupBy...)
+		if err != nil {
+			log.Printf("Error aggregating usage for user %d: %v", userId, err)
+			http.Error(w, "Error querying DB", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(buckets)
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
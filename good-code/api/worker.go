@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	middleware "github.com/chopstickleg/good-code/api/_middleware"
+	_ "github.com/chopstickleg/good-code/api/_utils/handlers"
+	jobs "github.com/chopstickleg/good-code/api/_utils/jobs"
+)
+
+const (
+	// maxDuration mirrors functions["api/worker.go"].maxDuration in vercel.json
+	maxDuration = 300 * time.Second
+	// shutdownMargin is kept back at the end so a job cut short by the
+	// deadline can still record its outcome before Vercel kills the function
+	shutdownMargin = 10 * time.Second
+	// defaultWorkerBudget is how long the worker keeps claiming new jobs. The
+	// rest of maxDuration is headroom for the last job claimed, so it should
+	// stay well above the worst-case runtime of a single roast.
+	defaultWorkerBudget = 50 * time.Second
+)
+
+// WorkerHandler is invoked by the Vercel cron to work through the job queue
+// for as long as the function is allowed to run. The every-minute schedule in
+// vercel.json needs a Vercel Pro plan; Hobby plans only run crons once a day,
+// so deployments on one should call this endpoint from an external scheduler
+// with the CRON_SECRET bearer token instead.
+func WorkerHandler(w http.ResponseWriter, r *http.Request) {
+	middleware.AllowMethods(http.MethodGet, http.MethodPost)(func(w http.ResponseWriter, r *http.Request) {
+		secret := os.Getenv("CRON_SECRET")
+		if secret == "" {
+			http.Error(w, "Bad secret", http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+secret {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := db.GetDB()
+		if err != nil {
+			http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+			return
+		}
+
+		budget := defaultWorkerBudget
+		if v := os.Getenv("WORKER_TIME_BUDGET_SECONDS"); v != "" {
+			if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+				budget = time.Duration(seconds) * time.Second
+			}
+		}
+		started := time.Now()
+		ctx, cancel := context.WithDeadline(r.Context(), started.Add(maxDuration-shutdownMargin))
+		defer cancel()
+		ran, err := jobs.Work(ctx, conn, jobs.WorkerID(), started.Add(min(budget, maxDuration-shutdownMargin)))
+		if err != nil {
+			log.Printf("Worker stopped after %d jobs: %v", ran, err)
+			http.Error(w, "Failed to work through the queue", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(map[string]int{"ran": ran})
+		if err != nil {
+			http.Error(w, "Error sending response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
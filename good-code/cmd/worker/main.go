@@ -0,0 +1,45 @@
+// Command worker works through the job queue continuously, as an alternative
+// to the cron-invoked /api/worker endpoint.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	db "github.com/chopstickleg/good-code/api/_db"
+	_ "github.com/chopstickleg/good-code/api/_utils/handlers"
+	jobs "github.com/chopstickleg/good-code/api/_utils/jobs"
+)
+
+const pollInterval = 5 * time.Second
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := db.GetDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	worker := jobs.WorkerID()
+	log.Printf("Worker %s started", worker)
+
+	for {
+		ran, err := jobs.Work(ctx, conn, worker, time.Now().Add(time.Minute))
+		if err != nil {
+			log.Printf("Worker error after %d jobs: %v", ran, err)
+		}
+		if ran > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("Worker %s stopping", worker)
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -8,6 +8,7 @@ import RepositoryDetails from "./pages/RepositoryDetails";
 import PrivateRoute from "./utils/auth/VerifyJWT";
 import Layout from "./components/Layout";
 import GitHubInstall from "./pages/GitHubInstall";
+import ResetPassword from "./pages/ResetPassword";
 
 function App() {
   return (
@@ -15,6 +16,7 @@ function App() {
       <Routes>
         <Route path="/login" element={<Login />} />
         <Route path="/signup" element={<Signup />} />
+        <Route path="/reset-password" element={<ResetPassword />} />
         <Route
           path="/"
           element={
@@ -7,6 +7,8 @@ import {
   signupUser,
   fetchRepositories,
   postGitHubAppInstall,
+  forgotPassword,
+  resetPassword,
 } from "../utils/api";
 import {
   UserLogin,
@@ -18,6 +20,9 @@ import {
   SignupResponse,
   GitHubAppSetup,
   GitHubAppInstallResponse,
+  ForgotPasswordRequest,
+  ResetPasswordRequest,
+  SuccessResponse,
 } from "../types";
 
 export const useDashboardData = () => {
@@ -88,13 +93,32 @@ export const useSignup = () => {
   });
 };
 
+export const useForgotPassword = () => {
+  return useMutation<SuccessResponse, Error, ForgotPasswordRequest>({
+    mutationFn: forgotPassword,
+  });
+};
+
+export const useResetPassword = () => {
+  const navigate = useNavigate();
+
+  return useMutation<SuccessResponse, Error, ResetPasswordRequest>({
+    mutationFn: resetPassword,
+    onSuccess: (response) => {
+      if (response.success) {
+        navigate("/login");
+      }
+    },
+  });
+};
+
 export const useLogout = () => {
   const navigate = useNavigate();
   const queryClient = useQueryClient();
 
   return useMutation<void, Error, void>({
     mutationFn: async () => {
-      await fetch("/api/auth/logout", {
+      await fetch("/api/account/logout", {
         method: "POST",
         credentials: "include",
       });
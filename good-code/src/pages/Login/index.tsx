@@ -99,6 +99,23 @@ const Login: React.FC = () => {
             </button>
           </form>
 
+          <div className="mt-4 text-right">
+            <button
+              type="button"
+              onClick={() => navigate("/reset-password")}
+              className="text-sm font-semibold text-blue-600 dark:text-blue-200 hover:text-blue-700 dark:hover:text-blue-100 transition-colors duration-200"
+            >
+              Forgot your password?
+            </button>
+          </div>
+
+          <a
+            href="/api/auth/github/login"
+            className="mt-4 w-full flex items-center justify-center bg-gray-900 hover:bg-gray-800 dark:bg-gray-700 dark:hover:bg-gray-600 text-white font-semibold py-3 px-6 rounded-xl transition-all duration-300 shadow-lg hover:shadow-xl"
+          >
+            Sign in with GitHub
+          </a>
+
           <div className="mt-8 text-center">
             <p className="text-gray-600 dark:text-gray-400">
               Don't have an account?{" "}
@@ -0,0 +1,126 @@
+import React, { useState } from "react";
+import { useSearchParams } from "react-router-dom";
+import { Helmet } from "react-helmet";
+import { useForgotPassword, useResetPassword } from "../../hooks";
+import { LoadingSpinner, ErrorMessage } from "../../components/Common";
+
+const inputClassName =
+  "w-full px-4 py-3 bg-white dark:bg-gray-700 border border-gray-300 dark:border-gray-600 rounded-xl text-gray-900 dark:text-gray-100 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:border-transparent transition-all duration-200";
+
+const buttonClassName =
+  "w-full bg-gradient-to-r from-blue-600 to-blue-700 hover:from-blue-700 hover:to-blue-800 disabled:from-gray-400 disabled:to-gray-500 text-white font-semibold py-3 px-6 rounded-xl transition-all duration-300 transform hover:scale-[1.02] disabled:scale-100 disabled:cursor-not-allowed shadow-lg hover:shadow-xl";
+
+const ResetPassword: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get("token");
+
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [confirmPassword, setConfirmPassword] = useState("");
+  const [error, setError] = useState("");
+  const [sent, setSent] = useState(false);
+
+  const { mutate: forgotPassword, isPending: isSending } = useForgotPassword();
+  const { mutate: resetPassword, isPending: isResetting } = useResetPassword();
+
+  const handleForgot = (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError("");
+
+    forgotPassword(
+      { email },
+      {
+        onSuccess: () => setSent(true),
+        onError: (error) => setError(error.message),
+      }
+    );
+  };
+
+  const handleReset = (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setError("");
+
+    if (password !== confirmPassword) {
+      setError("Passwords do not match");
+      return;
+    }
+
+    resetPassword(
+      { token: token ?? "", password },
+      {
+        onError: (error) => setError(error.message),
+      }
+    );
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-blue-50 via-slate-50 to-amber-50 dark:from-gray-900 dark:via-gray-800 dark:to-blue-900 flex items-center justify-center p-4">
+      <Helmet>
+        <title>Reset Password - Good Code</title>
+        <meta name="description" content="Reset your Good Code password" />
+      </Helmet>
+
+      <div className="w-full max-w-md">
+        <div className="bg-white/90 dark:bg-gray-800/90 backdrop-blur-lg p-8 rounded-2xl shadow-xl border border-gray-200 dark:border-gray-700 animate-slide-up">
+          <div className="text-center mb-8">
+            <h1 className="text-4xl font-bold bg-gradient-to-r from-blue-600 to-amber-600 dark:from-blue-400 dark:to-amber-400 bg-clip-text text-transparent mb-2">
+              Reset Password
+            </h1>
+            <p className="text-gray-600 dark:text-gray-400">
+              {token
+                ? "Choose a new password"
+                : "We'll email you a link to choose a new password"}
+            </p>
+          </div>
+
+          {error && <ErrorMessage message={error} className="mb-6" />}
+
+          {token ? (
+            <form onSubmit={handleReset} className="space-y-6">
+              <input
+                type="password"
+                placeholder="New password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                className={inputClassName}
+                minLength={8}
+                required
+              />
+              <input
+                type="password"
+                placeholder="Confirm new password"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                className={inputClassName}
+                required
+              />
+              <button type="submit" disabled={isResetting} className={buttonClassName}>
+                {isResetting ? <LoadingSpinner size="small" /> : "Set Password"}
+              </button>
+            </form>
+          ) : sent ? (
+            <p className="text-center text-gray-600 dark:text-gray-400">
+              If an account exists for {email}, a reset link is on its way.
+            </p>
+          ) : (
+            <form onSubmit={handleForgot} className="space-y-6">
+              <input
+                type="email"
+                placeholder="Enter your email"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                className={inputClassName}
+                required
+              />
+              <button type="submit" disabled={isSending} className={buttonClassName}>
+                {isSending ? <LoadingSpinner size="small" /> : "Send Reset Link"}
+              </button>
+            </form>
+          )}
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ResetPassword;
@@ -2,9 +2,11 @@ export interface UserLogin {
   id: bigint;
   email: string;
   name: string;
-  github_id: bigint;
+  github_id: bigint | null;
   installation_id: bigint;
   enabled: boolean;
+  is_admin: boolean;
+  email_verified_at?: string;
   created_at: string;
   updated_at: string;
   owned_repositories: Repository[];
@@ -19,6 +21,33 @@ export interface Repository {
   created_at: string;
   updated_at: string;
   ai_roasts: AIRoast[];
+  pull_requests: PullRequest[];
+}
+
+export type PullRequestState = "open" | "closed" | "merged";
+
+export interface PullRequest {
+  id: bigint;
+  repo_id: bigint;
+  number: number;
+  title: string;
+  author_github_id: bigint;
+  author_login: string;
+  base_ref: string;
+  base_sha: string;
+  head_ref: string;
+  head_sha: string;
+  draft: boolean;
+  state: PullRequestState;
+  opened_at: string;
+  closed_at?: string;
+  merged_at?: string;
+  ignored: boolean;
+  persona_id?: bigint;
+  skip_reason?: string;
+  skipped_at?: string;
+  created_at: string;
+  updated_at: string;
 }
 
 export interface AIRoast {
@@ -26,12 +55,68 @@ export interface AIRoast {
   repo_id: bigint;
   pull_request_number: number;
   content: string;
-  is_open: boolean;
+  pull_request_id?: bigint;
+  pull_request?: PullRequest;
+  findings: RoastFinding[];
+  conversation: ConversationTurn[];
+  installation_id: bigint;
+  prompt_tokens: number;
+  candidate_tokens: number;
+  total_tokens: number;
+  latency_ms: number;
+  estimated_cost_usd: number;
   created_at: string;
   updated_at: string;
   repository: Repository;
 }
 
+export interface UsageBucket {
+  month?: string;
+  repo_id?: bigint;
+  installation_id?: bigint;
+  roasts: number;
+  calls: number;
+  prompt_tokens: number;
+  candidate_tokens: number;
+  total_tokens: number;
+  estimated_cost_usd: number;
+}
+
+export interface RepositoryUsage {
+  repo_id: bigint;
+  total: UsageBucket;
+  months: UsageBucket[];
+}
+
+export interface RoastFinding {
+  id: bigint;
+  roast_id: bigint;
+  file: string;
+  start_line: number;
+  end_line: number;
+  severity: string;
+  category: string;
+  message: string;
+  suggestion: string;
+  github_comment_id: bigint;
+  created_at: string;
+}
+
+export interface ConversationTurn {
+  id: bigint;
+  roast_id: bigint;
+  repo_id: bigint;
+  pull_request_number: number;
+  finding_id?: bigint;
+  thread_id: bigint;
+  github_comment_id: bigint;
+  role: "user" | "assistant";
+  author_login: string;
+  body: string;
+  model?: string;
+  created_at: string;
+}
+
 export interface UserRepositoryCollaborator {
   id: bigint;
   repository_id: bigint;
@@ -43,6 +128,66 @@ export interface UserRepositoryCollaborator {
   github_avatar_url?: string;
 }
 
+export type JobStatus = "queued" | "running" | "succeeded" | "dead";
+
+export interface Job {
+  id: bigint;
+  kind: string;
+  payload: unknown;
+  status: JobStatus;
+  attempts: number;
+  max_attempts: number;
+  last_error: string;
+  run_at: string;
+  locked_at?: string;
+  locked_by: string;
+  completed_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export type WebhookDeliveryStatus = "received" | "processed" | "failed";
+
+export interface WebhookDelivery {
+  id: bigint;
+  delivery_id: string;
+  event: string;
+  action: string;
+  installation_id: bigint;
+  payload: unknown;
+  status: WebhookDeliveryStatus;
+  response_code: number;
+  error: string;
+  attempts: number;
+  duplicates: number;
+  processed_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface QuotaLimits {
+  roasts_per_month: number;
+  max_diff_bytes: number;
+  tokens_per_month: number;
+  max_tokens_per_roast: number;
+}
+
+export interface QuotaStatus {
+  installation_id: bigint;
+  plan: string;
+  period: string;
+  resets_at: string;
+  limits: QuotaLimits;
+  used: {
+    roasts: number;
+    tokens: number;
+  };
+  remaining: {
+    roasts: number | null;
+    tokens: number | null;
+  };
+}
+
 export interface RepositoryDetails {
   repo: Repository;
   collaborators: UserRepositoryCollaborator[];
@@ -71,9 +216,40 @@ export interface LoginResponse {
 
 export interface SignupResponse {
   success: boolean;
+  verification_required?: boolean;
   message?: string;
 }
 
+export interface ForgotPasswordRequest {
+  email: string;
+}
+
+export interface ResetPasswordRequest {
+  token: string;
+  password: string;
+}
+
+export interface SuccessResponse {
+  success: boolean;
+}
+
+export interface Session {
+  id: string;
+  user_login_id: bigint;
+  user_agent: string;
+  ip: string;
+  created_at: string;
+  last_seen_at: string;
+  expires_at: string;
+  current: boolean;
+}
+
+export interface GitHubAccount {
+  linked: boolean;
+  github_id: bigint | null;
+  has_password: boolean;
+}
+
 export interface GitHubAppSetup {
   installation_id: number;
   setup_action: string;
@@ -11,6 +11,9 @@ import {
   UserLogin,
   GitHubAppInstallResponse,
   GitHubAppSetup,
+  ForgotPasswordRequest,
+  ResetPasswordRequest,
+  SuccessResponse,
 } from "../types";
 
 export class APIError extends Error {
@@ -98,6 +101,24 @@ export const signupUser = async (
   });
 };
 
+export const forgotPassword = async (
+  data: ForgotPasswordRequest
+): Promise<SuccessResponse> => {
+  return apiFetch<SuccessResponse>("/api/account/forgot", {
+    method: "POST",
+    body: JSON.stringify(data),
+  });
+};
+
+export const resetPassword = async (
+  data: ResetPasswordRequest
+): Promise<SuccessResponse> => {
+  return apiFetch<SuccessResponse>("/api/account/reset", {
+    method: "POST",
+    body: JSON.stringify(data),
+  });
+};
+
 export const fetchRepositories = async (): Promise<UserLogin> => {
   const result = await apiFetch<UserLogin>("/api/repositories");
   console.table(result);